@@ -4,8 +4,11 @@ import (
 	"bufio"
 	"encoding/csv"
 	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	log "github.com/inconshreveable/log15"
 
@@ -36,7 +39,7 @@ var credentialAddCmd = &cobra.Command{
 			Password:     args[1],
 			ScanInterval: scanIntervalDays,
 		}
-		l := log.New("user", cred.User, "password", cred.Password, "interval", scanIntervalDays)
+		l := log.New("user", cred.User, "password", sshauditor.RedactSecret(cred.Password), "interval", scanIntervalDays)
 		added, err := store.AddCredential(cred)
 		if err != nil {
 			log.Error(err.Error())
@@ -59,12 +62,22 @@ var credentialListCmd = &cobra.Command{
 			log.Error(err.Error())
 			os.Exit(1)
 		}
+		keys, err := store.GetAllKeyCreds()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
 		w := json.NewEncoder(os.Stdout)
 		for _, c := range creds {
 			if err := w.Encode(c); err != nil {
 				panic(err)
 			}
 		}
+		for _, k := range keys {
+			if err := w.Encode(k); err != nil {
+				panic(err)
+			}
+		}
 	},
 }
 
@@ -81,9 +94,22 @@ var credentialResetCmd = &cobra.Command{
 	},
 }
 
+var credentialEncryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "encrypt credentials stored before a secret key was configured",
+	Run: func(cmd *cobra.Command, args []string) {
+		updated, err := store.EncryptSecrets()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		log.Info("encrypted credentials", "rows", updated)
+	},
+}
+
 var credentialImportCmd = &cobra.Command{
 	Use:   "import",
-	Short: "load credentials from TSV or JSON",
+	Short: "load credentials from TSV, JSON, or a private key file",
 }
 
 var credentialImportTSVCmd = &cobra.Command{
@@ -132,7 +158,7 @@ test	test
 				Password:     r[1],
 				ScanInterval: si,
 			}
-			l := log.New("user", cred.User, "password", cred.Password, "interval", si)
+			l := log.New("user", cred.User, "password", sshauditor.RedactSecret(cred.Password), "interval", si)
 			added, err := store.AddCredential(cred)
 			if err != nil {
 				log.Error(err.Error())
@@ -163,7 +189,7 @@ var credentialImportJSONCmd = &cobra.Command{
 			if cred.ScanInterval == 0 {
 				cred.ScanInterval = scanIntervalDays
 			}
-			l := log.New("user", cred.User, "password", cred.Password, "interval", cred.ScanInterval)
+			l := log.New("user", cred.User, "password", sshauditor.RedactSecret(cred.Password), "interval", cred.ScanInterval)
 			added, err := store.AddCredential(cred)
 			if err != nil {
 				log.Error(err.Error())
@@ -181,6 +207,86 @@ var credentialImportJSONCmd = &cobra.Command{
 	},
 }
 
+var keyUsers []string
+var keyPassphrase string
+var keyCertificate string
+var keyComment string
+
+//readKeyComment returns the comment from the .pub file next to a private key,
+//or the key file name if there isn't one
+func readKeyComment(keyFile string) string {
+	pub, err := ioutil.ReadFile(keyFile + ".pub")
+	if err == nil {
+		fields := strings.Fields(string(pub))
+		if len(fields) > 2 {
+			return strings.Join(fields[2:], " ")
+		}
+	}
+	return filepath.Base(keyFile)
+}
+
+var credentialImportKeyCmd = &cobra.Command{
+	Use:     "key",
+	Short:   "load a private key",
+	Example: "key --user root --user ubuntu --passphrase hunter2 id_rsa",
+	Long: `Load a private key file to be tried against every host.
+
+If a certificate named <file>-cert.pub exists it will be presented along with the key.
+If a <file>.pub exists its comment will be used as the key comment.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			return
+		}
+		keyFile := args[0]
+		privateKey, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		certFile := keyCertificate
+		if certFile == "" {
+			if _, err := os.Stat(keyFile + "-cert.pub"); err == nil {
+				certFile = keyFile + "-cert.pub"
+			}
+		}
+		var certificate []byte
+		if certFile != "" {
+			certificate, err = ioutil.ReadFile(certFile)
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+		comment := keyComment
+		if comment == "" {
+			comment = readKeyComment(keyFile)
+		}
+
+		store.Begin()
+		defer store.Commit()
+		for _, user := range keyUsers {
+			key, err := sshauditor.NewKeyCredential(user, privateKey, []byte(keyPassphrase), certificate, comment, scanIntervalDays)
+			if err != nil {
+				log.Error(err.Error(), "file", keyFile)
+				os.Exit(1)
+			}
+			l := log.New("user", key.User, "fingerprint", key.Fingerprint, "comment", key.Comment, "interval", key.ScanInterval)
+			added, err := store.AddKeyCredential(key)
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+			if added {
+				l.Info("added key credential")
+			} else {
+				l.Info("updated key credential")
+			}
+		}
+	},
+}
+
 func init() {
 	credentialAddCmd.Flags().IntVar(&scanIntervalDays, "scan-interval", 14, "How often to re-scan for this credential, in days")
 	RootCmd.AddCommand(credentialAddCmd)
@@ -188,7 +294,15 @@ func init() {
 	credentialCmd.AddCommand(credentialAddCmd)
 	credentialCmd.AddCommand(credentialListCmd)
 	credentialCmd.AddCommand(credentialResetCmd)
+	credentialCmd.AddCommand(credentialEncryptCmd)
 	credentialCmd.AddCommand(credentialImportCmd)
 	credentialImportCmd.AddCommand(credentialImportTSVCmd)
 	credentialImportCmd.AddCommand(credentialImportJSONCmd)
+	credentialImportCmd.AddCommand(credentialImportKeyCmd)
+
+	credentialImportKeyCmd.Flags().StringSliceVarP(&keyUsers, "user", "u", []string{"root"}, "users to try this key as")
+	credentialImportKeyCmd.Flags().StringVar(&keyPassphrase, "passphrase", "", "passphrase for an encrypted private key")
+	credentialImportKeyCmd.Flags().StringVar(&keyCertificate, "cert", "", "OpenSSH certificate to present with the key (default <file>-cert.pub if it exists)")
+	credentialImportKeyCmd.Flags().StringVar(&keyComment, "comment", "", "key comment (default from <file>.pub or the file name)")
+	credentialImportKeyCmd.Flags().IntVar(&scanIntervalDays, "scan-interval", 14, "How often to re-scan for this credential, in days")
 }
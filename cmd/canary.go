@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"net"
+	"os"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+var canaryListen string
+
+var canaryCmd = &cobra.Command{
+	Use:   "canary",
+	Short: "Run a canary listener for --tunnel-verify canary",
+	Long: `Run a canary listener for --tunnel-verify canary.
+
+Run this on a host in each network that should not be reachable through a
+tunnel, then pass its address to scan with --tunnel-canary.`,
+	// Don't create a store
+	PersistentPreRunE:  func(cmd *cobra.Command, args []string) error { return nil },
+	PersistentPostRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	Run: func(cmd *cobra.Command, args []string) {
+		l, err := net.Listen("tcp", canaryListen)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		log.Info("canary listening", "addr", l.Addr())
+		err = sshauditor.ServeCanary(l)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	canaryCmd.Flags().StringVarP(&canaryListen, "listen", "l", ":2223", "address to listen on")
+	RootCmd.AddCommand(canaryCmd)
+}
@@ -0,0 +1,364 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	html_template "html/template"
+	"os"
+	"text/tabwriter"
+	text_template "text/template"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+var diffSince string
+var diffFormat string
+
+var reportDiffCmd = &cobra.Command{
+	Use:     "diff",
+	Example: "report diff --since 2020-03-01 --format html",
+	Short:   "show what changed since a past run",
+	Long: `Show what changed since a past run: new and disappeared active hosts, new
+and remediated vulnerabilities, new duplicate key clusters and host changes.
+
+--since is a run id from 'report runs', or a date to compare with the last
+run before it.  Passwords are always redacted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if diffSince == "" {
+			log.Error("--since is required")
+			os.Exit(1)
+		}
+		auditor := sshauditor.New(store)
+		report, err := auditor.GetDiffReport(diffSince)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		switch diffFormat {
+		case "json":
+			w := json.NewEncoder(os.Stdout)
+			w.SetIndent("", "  ")
+			err = w.Encode(report)
+		case "txt":
+			t := text_template.Must(text_template.New("diff").Parse(diffTXTTemplate))
+			err = t.Execute(os.Stdout, report)
+		case "html":
+			t := html_template.Must(html_template.New("diff").Parse(diffHTMLTemplate))
+			err = t.Execute(os.Stdout, report)
+		default:
+			err = fmt.Errorf("unknown format %q", diffFormat)
+		}
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var reportRunsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "list the recorded discover, scan and rescan runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		runs, err := store.GetRuns()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tType\tStarted\tFinished\tStatus\tActive Hosts\tVulnerabilities\tDuplicate Keys")
+		for _, r := range runs {
+			status := r.Status
+			if r.Pruned {
+				status += " (pruned)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n",
+				r.ID, r.Type, r.Started, r.Finished, status, r.ActiveHosts, r.Vulnerabilities, r.DuplicateKeys)
+		}
+		w.Flush()
+	},
+}
+
+var pruneKeep int
+var pruneOlderThan string
+
+var reportRunsPruneCmd = &cobra.Command{
+	Use:     "prune",
+	Example: "report runs prune --keep 30\n  report runs prune --older-than 2020-01-01",
+	Short:   "delete the hosts and vulnerabilities saved with old runs",
+	Long: `Delete the hosts and vulnerabilities saved with old runs.  Every successful
+run saves a copy of the active hosts and vulnerabilities for 'report diff',
+so run this regularly to keep the database from growing.  The runs and their
+counts are kept for the trend in the html report, but can't be used with
+'report diff' any more.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if pruneKeep <= 0 && pruneOlderThan == "" {
+			log.Error("--keep or --older-than is required")
+			os.Exit(1)
+		}
+		auditor := sshauditor.New(store)
+		_, err := auditor.PruneRuns(pruneKeep, pruneOlderThan)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	reportDiffCmd.Flags().StringVar(&diffSince, "since", "", "run id or date (2006-01-02) to compare with")
+	reportDiffCmd.Flags().StringVar(&diffFormat, "format", "txt", "output format: txt, html or json")
+	reportCmd.AddCommand(reportDiffCmd)
+	reportCmd.AddCommand(reportRunsCmd)
+	reportRunsPruneCmd.Flags().IntVar(&pruneKeep, "keep", 0, "keep the newest this many successful runs")
+	reportRunsPruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "prune runs that finished before this date (2006-01-02)")
+	reportRunsCmd.AddCommand(reportRunsPruneCmd)
+}
+
+var diffTXTTemplate = `
+Changes since run {{.Since.ID}} ({{.Since.Type}} finished {{.Since.Finished}})
+
+New Vulnerabilities: {{ .NewVulnerabilitiesCount }}
+{{ range .NewVulnerabilities }}
+	Host {{.Hostport}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	User {{.User}}
+	Password {{.Password}}
+	Result {{.Result}}
+	Severity {{.Severity}}
+{{end}}
+
+Remediated Vulnerabilities: {{ .RemediatedVulnerabilitiesCount }}
+{{ range .RemediatedVulnerabilities }}
+	Host {{.Hostport}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	User {{.User}}
+	Password {{.Password}}
+	Result {{.Result}}
+	Severity {{.Severity}}
+{{end}}
+
+New Duplicate Keys: {{ .NewDuplicateKeysCount }}
+{{ range $key, $hosts := .NewDuplicateKeys }}
+{{$key}}:
+{{ range $hosts }}
+	Host {{.Hostport}}
+	{{- if .Names}}
+	Name {{.Names}}
+	{{- end}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	Version {{.Version}}
+{{end}}
+{{end}}
+
+Host Changes: {{ .HostChangesCount }}
+{{ range .HostChanges }}
+	Host {{.Hostport}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	Time {{.Time}}
+	Class {{.Classification}}
+	{{- if .NewFingerprint}}
+	Fingerprint {{.OldFingerprint}} -> {{.NewFingerprint}}
+	{{- end}}
+	{{- if .NewVersion}}
+	Version {{.OldVersion}} -> {{.NewVersion}}
+	{{- end}}
+{{end}}
+
+New Hosts: {{ .NewHostsCount }}
+{{ range .NewHosts }}
+	Host {{.Hostport}}
+	{{- if .Names}}
+	Name {{.Names}}
+	{{- end}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	Version {{.Version}}
+	Seen First {{.SeenFirst}}
+{{end}}
+
+Disappeared Hosts: {{ .DisappearedHostsCount }}
+{{ range .DisappearedHosts }}
+	Host {{.Hostport}}
+	{{- if .Names}}
+	Name {{.Names}}
+	{{- end}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	Version {{.Version}}
+	Seen Last {{.SeenLast}}
+{{end}}
+`
+
+var diffHTMLTemplate = `
+<html>
+<body>
+
+<p>Changes since run {{.Since.ID}} ({{.Since.Type}} finished {{.Since.Finished}})</p>
+
+<h1>New Vulnerabilities: {{ .NewVulnerabilitiesCount }}</h1>
+<table>
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Owner</th>
+		<th>User</th>
+		<th>Password</th>
+		<th>Result</th>
+		<th>Severity</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .NewVulnerabilities }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.User}} </td>
+	<td> {{.Password}} </td>
+	<td> {{.Result}} </td>
+	<td> {{.Severity}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+
+<h1>Remediated Vulnerabilities: {{ .RemediatedVulnerabilitiesCount }}</h1>
+<table>
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Owner</th>
+		<th>User</th>
+		<th>Password</th>
+		<th>Result</th>
+		<th>Severity</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .RemediatedVulnerabilities }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.User}} </td>
+	<td> {{.Password}} </td>
+	<td> {{.Result}} </td>
+	<td> {{.Severity}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+
+<h1>New Duplicate Keys: {{ .NewDuplicateKeysCount }}</h1>
+{{ range $key, $hosts := .NewDuplicateKeys }}
+<h2> {{$key}} </h2>
+<table>
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
+		<th>Version</th>
+	</tr>
+</thead>
+<tbody>
+{{ range $hosts }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Names}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Version}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+
+<h1>Host Changes: {{ .HostChangesCount }}</h1>
+<table>
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Owner</th>
+		<th>Time</th>
+		<th>Class</th>
+		<th>Old Fingerprint</th>
+		<th>New Fingerprint</th>
+		<th>Old Version</th>
+		<th>New Version</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .HostChanges }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Time}} </td>
+	<td> {{.Classification}} </td>
+	<td> {{.OldFingerprint}} </td>
+	<td> {{.NewFingerprint}} </td>
+	<td> {{.OldVersion}} </td>
+	<td> {{.NewVersion}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+
+<h1>New Hosts: {{ .NewHostsCount }}</h1>
+<table>
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
+		<th>Version</th>
+		<th>Seen First</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .NewHosts }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Names}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Version}} </td>
+	<td> {{.SeenFirst}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+
+<h1>Disappeared Hosts: {{ .DisappearedHostsCount }}</h1>
+<table>
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
+		<th>Version</th>
+		<th>Seen Last</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .DisappearedHosts }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Names}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Version}} </td>
+	<td> {{.SeenLast}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+`
@@ -11,25 +11,81 @@ import (
 
 var ports []int
 var exclude []string
+var minIPv6Prefix int
+var seedKnownHosts []string
+var seedNeighbors bool
+var randomize bool
+
+//loadSeeds returns the addresses from the known_hosts files and neighbor
+//cache selected on the command line
+func loadSeeds() ([]string, error) {
+	var seeds []string
+	for _, path := range seedKnownHosts {
+		s, err := sshauditor.KnownHostsSeeds(path)
+		if err != nil {
+			return nil, err
+		}
+		log.Info("loaded seeds from known_hosts", "file", path, "count", len(s))
+		seeds = append(seeds, s...)
+	}
+	if seedNeighbors {
+		s, err := sshauditor.NeighborSeeds()
+		if err != nil {
+			return nil, err
+		}
+		log.Info("loaded seeds from neighbor cache", "count", len(s))
+		seeds = append(seeds, s...)
+	}
+	return seeds, nil
+}
+
+//addDiscoverFlags adds the flags shared by the discover commands to cmd
+func addDiscoverFlags(cmd *cobra.Command) {
+	cmd.Flags().IntSliceVarP(&ports, "ports", "p", []int{22}, "ports to check during initial discovery")
+	cmd.Flags().StringSliceVarP(&exclude, "exclude", "x", []string{}, "subnets, addresses or hostnames to exclude from discovery")
+	cmd.Flags().IntVar(&minIPv6Prefix, "ipv6-min-prefix", sshauditor.DefaultMinIPv6Prefix, "refuse to enumerate IPv6 prefixes shorter than this, at least 65")
+	cmd.Flags().StringSliceVar(&seedKnownHosts, "seed-known-hosts", nil, "also discover the addresses in this known_hosts file, can be repeated")
+	cmd.Flags().BoolVar(&seedNeighbors, "seed-neighbors", false, "also discover the IPv6 neighbors of this host")
+	cmd.Flags().BoolVar(&randomize, "randomize", false, "discover hosts in a random order instead of one subnet at a time")
+	addHoneypotFlags(cmd)
+	addNotifyFlags(cmd)
+	addMetricsFlags(cmd)
+}
 
 var discoverCmd = &cobra.Command{
 	Use:     "discover",
 	Aliases: []string{"d"},
-	Example: "discover -p 22 -p 2222 192.168.1.0/24 10.1.1.0/24 --exclude 192.168.1.100/32",
+	Example: "discover -p 22 -p 2222 192.168.1.0/24 10.1.1.0/24 2001:db8::/120 --exclude 192.168.1.100/32",
 	Short:   "discover new hosts",
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 0 {
+		if len(args) == 0 && len(seedKnownHosts) == 0 && !seedNeighbors {
 			cmd.Usage()
 			return
 		}
+		if err := loadAuthOptions(); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		seeds, err := loadSeeds()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
 		scanConfig := sshauditor.ScanConfiguration{
-			Concurrency: concurrency,
-			Include:     args,
-			Exclude:     exclude,
-			Ports:       ports,
+			Concurrency:   concurrency,
+			Include:       append(args, seeds...),
+			Exclude:       exclude,
+			Ports:         ports,
+			MinIPv6Prefix: minIPv6Prefix,
+			Randomize:     randomize,
+			AuthOptions:   authOptions,
 		}
-		auditor := sshauditor.New(store)
-		err := auditor.Discover(scanConfig)
+		auditor, err := newAuditor()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		err = auditor.Discover(scanConfig)
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -40,20 +96,37 @@ var discoverCmd = &cobra.Command{
 var discoverFromFileCmd = &cobra.Command{
 	Use:     "fromfile",
 	Example: "fromfile -p 22 hosts.txt",
-	Short:   "discover new hosts using a list of hosts from stdin",
+	Short:   "discover new hosts using a list of hosts, networks or hostnames from stdin",
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadAuthOptions(); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		seeds, err := loadSeeds()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
 		scanner := bufio.NewScanner(os.Stdin)
 		scanConfig := sshauditor.ScanConfiguration{
-			Concurrency: concurrency,
-			Include:     []string{},
-			Ports:       ports,
+			Concurrency:   concurrency,
+			Include:       seeds,
+			Exclude:       exclude,
+			Ports:         ports,
+			MinIPv6Prefix: minIPv6Prefix,
+			Randomize:     randomize,
+			AuthOptions:   authOptions,
 		}
 		for scanner.Scan() {
 			host := scanner.Text()
 			scanConfig.Include = append(scanConfig.Include, host)
 		}
-		auditor := sshauditor.New(store)
-		err := auditor.Discover(scanConfig)
+		auditor, err := newAuditor()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		err = auditor.Discover(scanConfig)
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -62,10 +135,8 @@ var discoverFromFileCmd = &cobra.Command{
 }
 
 func init() {
-	discoverCmd.Flags().IntSliceVarP(&ports, "ports", "p", []int{22}, "ports to check during initial discovery")
-	discoverCmd.Flags().StringSliceVarP(&exclude, "exclude", "x", []string{}, "subnets to exclude from discovery")
-
-	discoverFromFileCmd.Flags().IntSliceVarP(&ports, "ports", "p", []int{22}, "ports to check during initial discovery")
+	addDiscoverFlags(discoverCmd)
+	addDiscoverFlags(discoverFromFileCmd)
 	RootCmd.AddCommand(discoverCmd)
 	discoverCmd.AddCommand(discoverFromFileCmd)
 }
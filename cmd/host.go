@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 
 	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
 
 	"github.com/spf13/cobra"
 )
@@ -51,9 +53,126 @@ var hostDeleteCmd = &cobra.Command{
 	},
 }
 
+var changesDays int
+var changesClassification string
+var changesAlert bool
+
+var hostChangesCmd = &cobra.Command{
+	Use:   "changes",
+	Short: "list fingerprint and version changes",
+	Long: `List fingerprint and version changes.
+
+Each change is classified as a version upgrade, a likely reinstall (new key
+and new version) or suspicious (new key, same version).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := store.GetHostChanges(changesDays)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		switch changesClassification {
+		case "":
+		case sshauditor.ChangeVersionUpgrade, sshauditor.ChangeReinstall, sshauditor.ChangeSuspicious:
+			changes = sshauditor.FilterHostChanges(changes, changesClassification)
+		default:
+			log.Error("invalid change class", "class", changesClassification)
+			os.Exit(1)
+		}
+		for _, c := range changes {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				c.Time, c.Hostport, c.Classification,
+				c.OldFingerprint, c.NewFingerprint,
+				c.OldVersion, c.NewVersion,
+			)
+		}
+		if changesAlert {
+			suspicious := sshauditor.FilterHostChanges(changes, sshauditor.ChangeSuspicious)
+			for _, c := range suspicious {
+				log.Crit("suspicious host key change", "host", c.Hostport, "time", c.Time, "old_fp", c.OldFingerprint, "new_fp", c.NewFingerprint)
+			}
+			if len(suspicious) > 0 {
+				os.Exit(2)
+			}
+		}
+	},
+}
+
+var exportSubnets []string
+var exportHashed bool
+var exportGroup string
+
+//exportHosts writes the active hosts that match the export flags with write
+func exportHosts(write func(hosts []sshauditor.Host) error) {
+	hosts, err := store.GetActiveHosts(hostMaxAgeDays)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	hosts, err = sshauditor.FilterHostsBySubnet(hosts, exportSubnets)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	err = write(hosts)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+var hostExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export hosts and their host keys",
+}
+
+var hostExportKnownHostsCmd = &cobra.Command{
+	Use:     "known_hosts",
+	Example: "host export known_hosts --hashed --subnet 10.0.0.0/8 > /etc/ssh/ssh_known_hosts",
+	Short:   "export host keys in OpenSSH known_hosts format",
+	Run: func(cmd *cobra.Command, args []string) {
+		exportHosts(func(hosts []sshauditor.Host) error {
+			return sshauditor.WriteKnownHosts(os.Stdout, hosts, exportHashed)
+		})
+	},
+}
+
+var hostExportCSVCmd = &cobra.Command{
+	Use:   "csv",
+	Short: "export hosts as CSV",
+	Run: func(cmd *cobra.Command, args []string) {
+		exportHosts(func(hosts []sshauditor.Host) error {
+			return sshauditor.WriteHostsCSV(os.Stdout, hosts)
+		})
+	},
+}
+
+var hostExportAnsibleCmd = &cobra.Command{
+	Use:   "ansible",
+	Short: "export hosts as an Ansible inventory",
+	Run: func(cmd *cobra.Command, args []string) {
+		exportHosts(func(hosts []sshauditor.Host) error {
+			return sshauditor.WriteAnsibleInventory(os.Stdout, hosts, exportGroup)
+		})
+	},
+}
+
 func init() {
 	RootCmd.AddCommand(hostCmd)
 	hostCmd.AddCommand(hostListCmd)
 	hostListCmd.Flags().IntVar(&hostMaxAgeDays, "max-age-days", 14, "List hosts seen at most this many days ago")
 	hostCmd.AddCommand(hostDeleteCmd)
+
+	hostCmd.AddCommand(hostChangesCmd)
+	hostChangesCmd.Flags().IntVar(&changesDays, "days", 14, "List changes from at most this many days ago")
+	hostChangesCmd.Flags().StringVar(&changesClassification, "class", "", "only list changes of this class (version upgrade, likely reinstall, suspicious)")
+	hostChangesCmd.Flags().BoolVar(&changesAlert, "alert", false, "log a critical alert for each suspicious change and exit with status 2 if there are any")
+
+	hostCmd.AddCommand(hostExportCmd)
+	hostExportCmd.PersistentFlags().IntVar(&hostMaxAgeDays, "max-age-days", 14, "Export hosts seen at most this many days ago")
+	hostExportCmd.PersistentFlags().StringSliceVar(&exportSubnets, "subnet", nil, "only export hosts in these subnets")
+	hostExportKnownHostsCmd.Flags().BoolVar(&exportHashed, "hashed", false, "hash host names like HashKnownHosts does")
+	hostExportAnsibleCmd.Flags().StringVar(&exportGroup, "group", "ssh_auditor", "inventory group to put the hosts in")
+	hostExportCmd.AddCommand(hostExportKnownHostsCmd)
+	hostExportCmd.AddCommand(hostExportCSVCmd)
+	hostExportCmd.AddCommand(hostExportAnsibleCmd)
 }
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+type importParser func(r io.Reader, ports []int) (sshauditor.Import, error)
+
+//runImport parses the scanner output in path with parse and runs discovery
+//on the ssh ports it lists
+func runImport(path string, parse importParser) error {
+	if err := loadAuthOptions(); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	imp, err := parse(f, ports)
+	if err != nil {
+		return err
+	}
+	scanConfig := sshauditor.ScanConfiguration{
+		Concurrency: concurrency,
+		Exclude:     exclude,
+		AuthOptions: authOptions,
+	}
+	auditor, err := newAuditor()
+	if err != nil {
+		return err
+	}
+	return auditor.DiscoverImport(imp, scanConfig)
+}
+
+var discoverImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "discover hosts from the output of another scanner",
+	Long: `Discover hosts from the output of another scanner.
+
+Ports the scanner identified as ssh are used whatever their number.  Open
+ports without any service information are only used if they are in --ports.`,
+}
+
+var discoverImportNmapCmd = &cobra.Command{
+	Use:     "nmap <file.xml>",
+	Example: "nmap -p- -sV -oX scan.xml 10.0.0.0/24; ssh-auditor discover import nmap scan.xml",
+	Short:   "discover hosts from nmap XML output",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runImport(args[0], sshauditor.ParseNmapXML)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var discoverImportMasscanCmd = &cobra.Command{
+	Use:     "masscan <file.json>",
+	Example: "masscan -p1-65535 --banners -oJ scan.json 10.0.0.0/8; ssh-auditor discover import masscan scan.json",
+	Short:   "discover hosts from masscan JSON output",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := runImport(args[0], sshauditor.ParseMasscanJSON)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{discoverImportNmapCmd, discoverImportMasscanCmd} {
+		cmd.Flags().IntSliceVarP(&ports, "ports", "p", []int{22}, "open ports to treat as ssh when the scanner didn't identify the service")
+		cmd.Flags().StringSliceVarP(&exclude, "exclude", "x", []string{}, "subnets, addresses or hostnames to exclude from discovery")
+		addHoneypotFlags(cmd)
+		addNotifyFlags(cmd)
+		addMetricsFlags(cmd)
+		discoverImportCmd.AddCommand(cmd)
+	}
+	discoverCmd.AddCommand(discoverImportCmd)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+var inventorySource string
+var inventoryReplace bool
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "manage the expected host keys that discovery checks against",
+}
+
+var inventoryImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import expected host keys",
+}
+
+type inventoryParser func(r io.Reader, source string) ([]sshauditor.ExpectedKey, error)
+
+//importInventory loads the expected keys in path with parse
+func importInventory(path string, parse inventoryParser) {
+	source := inventorySource
+	if source == "" {
+		source = path
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	defer f.Close()
+	keys, err := parse(f, source)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	err = store.AddExpectedKeys(keys, source, inventoryReplace)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	log.Info("imported expected host keys", "source", source, "count", len(keys))
+}
+
+var inventoryImportKnownHostsCmd = &cobra.Command{
+	Use:   "known_hosts <file>",
+	Short: "import expected host keys from a known_hosts file",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importInventory(args[0], sshauditor.ParseKnownHostsKeys)
+	},
+}
+
+var inventoryImportCSVCmd = &cobra.Command{
+	Use:   "csv <file>",
+	Short: "import expected host keys from a CMDB CSV export",
+	Long: `Import expected host keys from a CMDB CSV export.
+
+The header must have a host column and either a host_key column with the key
+in authorized_keys format or a fingerprint column with its SHA256
+fingerprint.  Optional port and key_type columns are also used.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		importInventory(args[0], sshauditor.ParseInventoryCSV)
+	},
+}
+
+var inventoryListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"l"},
+	Short:   "list expected host keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := store.GetExpectedKeys()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		for _, k := range keys {
+			fmt.Printf("%s\t%s\t%s\t%s\n", k.Name, k.KeyType, k.Fingerprint, k.Source)
+		}
+	},
+}
+
+var inventoryFindingsCmd = &cobra.Command{
+	Use:   "findings",
+	Short: "list host key mismatches, unexpected key types and hosts missing from the inventory",
+	Run: func(cmd *cobra.Command, args []string) {
+		findings, err := store.GetInventoryFindings()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		for _, f := range findings {
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", f.Hostport, f.Type, f.Expected, f.Observed, f.SeenLast)
+		}
+	},
+}
+
+func init() {
+	inventoryImportCmd.PersistentFlags().StringVar(&inventorySource, "source", "", "name of this source of keys (default the file name)")
+	inventoryImportCmd.PersistentFlags().BoolVar(&inventoryReplace, "replace", true, "remove the keys previously imported from the same source")
+	inventoryImportCmd.AddCommand(inventoryImportKnownHostsCmd)
+	inventoryImportCmd.AddCommand(inventoryImportCSVCmd)
+	inventoryCmd.AddCommand(inventoryImportCmd)
+	inventoryCmd.AddCommand(inventoryListCmd)
+	inventoryCmd.AddCommand(inventoryFindingsCmd)
+	RootCmd.AddCommand(inventoryCmd)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+var metadataDepartment string
+var metadataRemoveTags bool
+
+//updateMetadata loads the metadata for target, changes it with update and
+//stores it again
+func updateMetadata(target string, update func(m *sshauditor.Metadata)) {
+	target, err := sshauditor.NormalizeMetadataTarget(target)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	m, err := store.GetMetadata(target)
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	update(&m)
+	if m == (sshauditor.Metadata{Target: target}) {
+		err = store.DeleteMetadata(target)
+	} else {
+		err = store.SetMetadata(m)
+	}
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+var hostOwnerCmd = &cobra.Command{
+	Use:     "owner <target> <email>",
+	Example: "host owner 10.1.0.0/16 netops@example.com --department Networking",
+	Short:   "set the owner of a host or network",
+	Long: `Set the owner of a host or network.
+
+The target can be a host:port, an address, a hostname or a CIDR.  The most
+specific target wins, so a host can have a different owner from its network.
+An empty email clears the owner.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateMetadata(args[0], func(m *sshauditor.Metadata) {
+			m.Owner = args[1]
+			if cmd.Flags().Changed("department") {
+				m.Department = metadataDepartment
+			}
+		})
+	},
+}
+
+var hostTagCmd = &cobra.Command{
+	Use:     "tag <target> <tag>...",
+	Example: "host tag 10.1.2.3 dmz pci",
+	Short:   "add tags to a host or network",
+	Long: `Add tags to a host or network, or remove them with --remove.
+
+A host has the tags of every target that matches it.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateMetadata(args[0], func(m *sshauditor.Metadata) {
+			if metadataRemoveTags {
+				m.RemoveTags(args[1:]...)
+			} else {
+				m.AddTags(args[1:]...)
+			}
+		})
+	},
+}
+
+var hostNoteCmd = &cobra.Command{
+	Use:   "note <target> <text>",
+	Short: "set the notes for a host or network",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		updateMetadata(args[0], func(m *sshauditor.Metadata) {
+			m.Notes = strings.Join(args[1:], " ")
+		})
+	},
+}
+
+var hostMetadataCmd = &cobra.Command{
+	Use:   "metadata",
+	Short: "manage host and network owners, tags and notes",
+}
+
+var hostMetadataImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "import owners, tags and notes from a CSV file",
+	Long: `Import owners, tags and notes from a CSV file.
+
+The header must have a target column (or host or cidr).  The owner,
+department, tags and notes columns are optional.  Each row replaces any
+metadata already stored for its target.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		f, err := os.Open(args[0])
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		defer f.Close()
+		all, err := sshauditor.ParseMetadataCSV(f)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		err = store.ImportMetadata(all)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		log.Info("imported metadata", "file", args[0], "count", len(all))
+	},
+}
+
+var hostMetadataListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"l"},
+	Short:   "list metadata for every host and network",
+	Run: func(cmd *cobra.Command, args []string) {
+		all, err := store.GetAllMetadata()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		w := json.NewEncoder(os.Stdout)
+		for _, m := range all {
+			if err := w.Encode(m); err != nil {
+				panic(err)
+			}
+		}
+	},
+}
+
+var hostMetadataDeleteCmd = &cobra.Command{
+	Use:   "delete <target>...",
+	Short: "delete the metadata for hosts or networks",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, target := range args {
+			target, err := sshauditor.NormalizeMetadataTarget(target)
+			if err == nil {
+				err = store.DeleteMetadata(target)
+			}
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	hostOwnerCmd.Flags().StringVar(&metadataDepartment, "department", "", "department the owner belongs to")
+	hostTagCmd.Flags().BoolVar(&metadataRemoveTags, "remove", false, "remove the tags instead of adding them")
+	hostCmd.AddCommand(hostOwnerCmd)
+	hostCmd.AddCommand(hostTagCmd)
+	hostCmd.AddCommand(hostNoteCmd)
+	hostCmd.AddCommand(hostMetadataCmd)
+	hostMetadataCmd.AddCommand(hostMetadataImportCmd)
+	hostMetadataCmd.AddCommand(hostMetadataListCmd)
+	hostMetadataCmd.AddCommand(hostMetadataDeleteCmd)
+}
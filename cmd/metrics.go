@@ -0,0 +1,12 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var metricsListen string
+
+//addMetricsFlags adds the flags that configure the metrics endpoint to cmd
+func addMetricsFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&metricsListen, "metrics-listen", "", "address to serve Prometheus metrics on while running, like :9115")
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+//webhookSecretEnv is the environment variable that holds the secret used to
+//sign webhook requests
+const webhookSecretEnv = "SSH_AUDITOR_WEBHOOK_SECRET"
+
+var webhookURLs []string
+var webhookFormat string
+var webhookRetries int
+var siemAddress string
+var siemNetwork string
+var siemFormat string
+var siemEvents []string
+var siemCAFile string
+
+//addNotifyFlags adds the flags that configure notifications to cmd
+func addNotifyFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&webhookURLs, "webhook", nil, "URL to post new findings and suspicious host key changes to, can be repeated")
+	cmd.Flags().StringVar(&webhookFormat, "webhook-format", sshauditor.WebhookJSON, "webhook payload format: json, slack or mattermost")
+	cmd.Flags().IntVar(&webhookRetries, "webhook-retries", 3, "number of times to retry a failed webhook delivery")
+	cmd.Flags().StringVar(&siemAddress, "siem", "", "host:port of a syslog server to send events to")
+	cmd.Flags().StringVar(&siemNetwork, "siem-network", "udp", "syslog transport: udp, tcp or tls")
+	cmd.Flags().StringVar(&siemFormat, "siem-format", sshauditor.SIEMFormatCEF, "syslog message format: cef or leef")
+	cmd.Flags().StringSliceVar(&siemEvents, "siem-events", sshauditor.EventTypes, "events to send to the syslog server")
+	cmd.Flags().StringVar(&siemCAFile, "siem-ca", "", "CA certificate file used to verify the syslog server over tls")
+}
+
+//newSIEMNotifier returns the syslog notifier configured by the siem flags
+func newSIEMNotifier() (sshauditor.Notifier, error) {
+	for _, e := range siemEvents {
+		known := false
+		for _, t := range sshauditor.EventTypes {
+			known = known || e == t
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event type %q", e)
+		}
+	}
+	n, err := sshauditor.NewSyslogNotifier(siemNetwork, siemAddress, siemFormat, version)
+	if err != nil {
+		return nil, err
+	}
+	if siemCAFile != "" {
+		pem, err := ioutil.ReadFile(siemCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", siemCAFile)
+		}
+		n.TLSConfig = &tls.Config{RootCAs: pool}
+	}
+	return sshauditor.Filter(n, sshauditor.OfType(siemEvents...)), nil
+}
+
+//newAuditor returns an auditor that sends notifications as configured by
+//the notify flags, and serves metrics if --metrics-listen is set
+func newAuditor() (*sshauditor.SSHAuditor, error) {
+	auditor := sshauditor.New(store)
+	secret := []byte(os.Getenv(webhookSecretEnv))
+	for _, url := range webhookURLs {
+		n, err := sshauditor.NewWebhookNotifier(url, webhookFormat, secret)
+		if err != nil {
+			return nil, err
+		}
+		n.Retries = webhookRetries
+		auditor.AddNotifier(sshauditor.Filter(n, sshauditor.AlertEvents))
+	}
+	if siemAddress != "" {
+		n, err := newSIEMNotifier()
+		if err != nil {
+			return nil, err
+		}
+		auditor.AddNotifier(n)
+	}
+	if metricsListen != "" {
+		if err := auditor.ServeMetrics(metricsListen); err != nil {
+			return nil, err
+		}
+	}
+	return auditor, nil
+}
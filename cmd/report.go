@@ -1,10 +1,13 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fmt"
 	html_template "html/template"
 	"os"
+	"path/filepath"
+	"strings"
 	text_template "text/template"
+	"time"
 
 	log "github.com/inconshreveable/log15"
 	"github.com/ncsa/ssh-auditor/sshauditor"
@@ -18,19 +21,48 @@ var reportCmd = &cobra.Command{
 	Aliases: []string{"rep"},
 }
 
+var reportSections []string
+var reportFilter sshauditor.ReportFilter
+
+//addReportFlags adds the flags that select what goes in a report to cmd
+func addReportFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&reportSections, "sections", nil, "sections to include: "+strings.Join(sshauditor.ReportSections, ", ")+" (default all)")
+	cmd.Flags().StringSliceVar(&reportFilter.Networks, "cidr", nil, "only include hosts in these networks")
+	cmd.Flags().StringSliceVar(&reportFilter.Tags, "tag", nil, "only include hosts with one of these tags")
+	cmd.Flags().StringSliceVar(&reportFilter.Results, "result", nil, "only include vulnerabilities with these results, like exec or tunnel")
+}
+
+//loadReport returns the audit report with the redaction, section and filter
+//flags applied
+func loadReport() (sshauditor.AuditReport, error) {
+	auditor := sshauditor.New(store)
+	report, err := auditor.GetReport()
+	if err != nil {
+		return report, err
+	}
+	if redact {
+		report = report.Redacted()
+	}
+	report, err = report.Filter(reportFilter)
+	if err != nil {
+		return report, err
+	}
+	if len(reportSections) != 0 {
+		return report.Select(reportSections)
+	}
+	return report, nil
+}
+
 var reportJSONCmd = &cobra.Command{
 	Use:   "json",
 	Short: "json report",
 	Run: func(cmd *cobra.Command, args []string) {
-		auditor := sshauditor.New(store)
-		report, err := auditor.GetReport()
+		report, err := loadReport()
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
 		}
-		w := json.NewEncoder(os.Stdout)
-		w.SetIndent("", "  ")
-		err = w.Encode(report)
+		err = report.WriteJSON(os.Stdout)
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -42,8 +74,7 @@ var reportTXTCmd = &cobra.Command{
 	Use:   "txt",
 	Short: "plain text report",
 	Run: func(cmd *cobra.Command, args []string) {
-		auditor := sshauditor.New(store)
-		report, err := auditor.GetReport()
+		report, err := loadReport()
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -57,18 +88,39 @@ var reportTXTCmd = &cobra.Command{
 		return
 	},
 }
+
+//htmlReport is the report with the statistics shown at the top of the html
+//report
+type htmlReport struct {
+	sshauditor.AuditReport
+	Summary   sshauditor.ReportSummary
+	Generated string
+}
+
 var reportHTMLCmd = &cobra.Command{
 	Use:   "html",
-	Short: "html report",
+	Short: "html report with summary statistics, in a single file",
+	Long: `HTML report with summary cards, the version distribution, a trend over
+the past runs and tables that can be sorted and filtered.  Everything is
+inline, so the report is a single file that can be emailed or attached.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		auditor := sshauditor.New(store)
-		report, err := auditor.GetReport()
+		report, err := loadReport()
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
 		}
-		t := html_template.Must(html_template.New("report").Parse(reportHTMLTemplate))
-		err = t.Execute(os.Stdout, report)
+		runs, err := store.GetRuns()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		rep := htmlReport{
+			AuditReport: report,
+			Summary:     report.Summary(runs),
+			Generated:   time.Now().Format("2006-01-02 15:04:05"),
+		}
+		t := html_template.Must(html_template.New("report").Funcs(sshauditor.ReportTemplateFuncs()).Parse(reportHTMLTemplate))
+		err = t.Execute(os.Stdout, rep)
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -77,91 +129,485 @@ var reportHTMLCmd = &cobra.Command{
 	},
 }
 
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+var reportMarkdownCmd = &cobra.Command{
+	Use:     "md",
+	Aliases: []string{"markdown"},
+	Short:   "markdown report, for pasting into tickets",
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := loadReport()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		funcs := text_template.FuncMap{"md": markdownEscaper.Replace}
+		t := text_template.Must(text_template.New("report").Funcs(funcs).Parse(reportMarkdownTemplate))
+		err = t.Execute(os.Stdout, report)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+var csvSection string
+var csvDir string
+
+var reportCSVCmd = &cobra.Command{
+	Use:     "csv",
+	Example: "report csv --section vulns > vulns.csv\n  report csv --dir report/ --sections vulns,dupes",
+	Short:   "csv report, one section at a time or one file per section",
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := loadReport()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		switch {
+		case csvSection != "":
+			err = report.WriteCSV(os.Stdout, csvSection)
+		case csvDir != "":
+			err = writeCSVFiles(report, csvDir)
+		default:
+			err = fmt.Errorf("either --section or --dir is required")
+		}
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+//writeCSVFiles writes each section of report to <section>.csv in dir
+func writeCSVFiles(report sshauditor.AuditReport, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, section := range sshauditor.ReportSections {
+		if !report.Includes(section) {
+			continue
+		}
+		f, err := os.Create(filepath.Join(dir, section+".csv"))
+		if err != nil {
+			return err
+		}
+		err = report.WriteCSV(f, section)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		log.Info("wrote csv", "section", section, "file", f.Name())
+	}
+	return nil
+}
+
 func init() {
+	reportCmd.PersistentFlags().BoolVar(&redact, "redact", true, "mask each password instead of showing it")
 	RootCmd.AddCommand(reportCmd)
-	reportCmd.AddCommand(reportJSONCmd)
-	reportCmd.AddCommand(reportTXTCmd)
-	reportCmd.AddCommand(reportHTMLCmd)
+	for _, cmd := range []*cobra.Command{reportJSONCmd, reportTXTCmd, reportHTMLCmd, reportMarkdownCmd, reportCSVCmd} {
+		addReportFlags(cmd)
+		reportCmd.AddCommand(cmd)
+	}
+	reportCSVCmd.Flags().StringVar(&csvSection, "section", "", "section to write to stdout: "+strings.Join(sshauditor.ReportSections, ", "))
+	reportCSVCmd.Flags().StringVar(&csvDir, "dir", "", "directory to write one file per section to")
 }
 
 var reportTXTTemplate = `
-Vulnerabilities: {{ .VulnerabilitiesCount }} 
+{{if .Includes "vulns"}}Vulnerabilities: {{ .VulnerabilitiesCount }} 
 {{range .Vulnerabilities}}
 	Host {{.Host.Hostport}}
+	{{- if .Host.Names}}
+	Name {{.Host.Names}}
+	{{- end}}
+	{{- if .Host.Metadata.Owner}}
+	Owner {{.Host.Metadata.Owner}}
+	{{- end}}
+	{{- if .Host.Metadata.Department}}
+	Department {{.Host.Metadata.Department}}
+	{{- end}}
+	{{- if .Host.Metadata.Tags}}
+	Tags {{.Host.Metadata.Tags}}
+	{{- end}}
 	Version {{.Host.Version}}
 	User {{.HostCredential.User}}
 	Password {{.HostCredential.Password}}
 	Result {{.HostCredential.Result}}
+	Severity {{.Evidence.Severity}}
+	{{- if .Evidence.Privilege}}
+	Privilege {{.Evidence.Privilege}}
+	{{- end}}
 	Last Tested {{.HostCredential.LastTested}}
-{{end}}
+	Evidence Collected {{.Evidence.Collected}}
+	Auth Method {{.Evidence.AuthMethod}}
+	Host Key {{.Evidence.HostKeyFingerprint}}
+	Server Version {{.Evidence.Version}}
+	{{- if .Evidence.Banner}}
+	Banner {{.Evidence.Banner}}
+	{{- end}}
+	{{- if .Evidence.IDOutput}}
+	id Output {{.Evidence.IDOutput}}
+	{{- end}}
+	{{- if .Evidence.TunnelDestination}}
+	Tunnel Destination {{.Evidence.TunnelDestination}}
+	{{- end}}
+	{{- if .Evidence.Capabilities}}
+	Capabilities {{.Evidence.Capabilities}}
+	{{- end}}
+{{end}}{{end}}{{if .Includes "dupes"}}
 
 Duplicate Keys: {{ .DuplicateKeysCount }} 
 {{ range $key, $hosts := .DuplicateKeys }}
 {{$key}}:
 {{ range $hosts }}
 	Host {{.Hostport}}
+	{{- if .Names}}
+	Name {{.Names}}
+	{{- end}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	{{- if .Metadata.Department}}
+	Department {{.Metadata.Department}}
+	{{- end}}
+	{{- if .Metadata.Tags}}
+	Tags {{.Metadata.Tags}}
+	{{- end}}
 	Version {{.Version}}
 	Seen First {{.SeenFirst}}
 	Seen Last {{.SeenLast}}
 {{end}}
-{{end}}
+{{end}}{{end}}{{if .Includes "anomalies"}}
+
+Anomalous Hosts: {{ .AnomalousHostsCount }}
+{{ range .AnomalousHosts }}
+	Host {{.Hostport}}
+	{{- if .Names}}
+	Name {{.Names}}
+	{{- end}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	{{- if .Metadata.Department}}
+	Department {{.Metadata.Department}}
+	{{- end}}
+	{{- if .Metadata.Tags}}
+	Tags {{.Metadata.Tags}}
+	{{- end}}
+	Anomaly {{.Anomaly}}
+	Reason {{.AnomalyReason}}
+	Version {{.Version}}
+	Seen Last {{.SeenLast}}
+{{end}}{{end}}{{if .Includes "inventory"}}
+
+Inventory Findings: {{ .InventoryFindingsCount }}
+{{ range .InventoryFindings }}
+	Host {{.Hostport}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	{{- if .Metadata.Department}}
+	Department {{.Metadata.Department}}
+	{{- end}}
+	{{- if .Metadata.Tags}}
+	Tags {{.Metadata.Tags}}
+	{{- end}}
+	Finding {{.Type}}
+	{{- if .Expected}}
+	Expected {{.Expected}}
+	{{- end}}
+	Observed {{.Observed}}
+	Seen First {{.SeenFirst}}
+	Seen Last {{.SeenLast}}
+{{end}}{{end}}{{if .Includes "changes"}}
+
+Host Changes: {{ .HostChangesCount }}
+{{ range .HostChanges }}
+	Host {{.Hostport}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	{{- if .Metadata.Department}}
+	Department {{.Metadata.Department}}
+	{{- end}}
+	{{- if .Metadata.Tags}}
+	Tags {{.Metadata.Tags}}
+	{{- end}}
+	Time {{.Time}}
+	Class {{.Classification}}
+	{{- if .NewFingerprint}}
+	Fingerprint {{.OldFingerprint}} -> {{.NewFingerprint}}
+	{{- end}}
+	{{- if .NewVersion}}
+	Version {{.OldVersion}} -> {{.NewVersion}}
+	{{- end}}
+{{end}}{{end}}{{if .Includes "crypto"}}
+
+Weak Crypto: {{ .WeakCryptoCount }}
+{{ range .WeakCrypto }}
+	Host {{.Host.Hostport}}
+	{{- if .Host.Names}}
+	Name {{.Host.Names}}
+	{{- end}}
+	{{- if .Host.Metadata.Owner}}
+	Owner {{.Host.Metadata.Owner}}
+	{{- end}}
+	Issue {{.Issue}}
+	Version {{.Host.Version}}
+{{end}}{{end}}{{if .Includes "hosts"}}
 
 Active Hosts: {{ .ActiveHostsCount }}
 {{ range .ActiveHosts }}
 	Host {{.Hostport}}
+	{{- if .Names}}
+	Name {{.Names}}
+	{{- end}}
+	{{- if .Metadata.Owner}}
+	Owner {{.Metadata.Owner}}
+	{{- end}}
+	{{- if .Metadata.Department}}
+	Department {{.Metadata.Department}}
+	{{- end}}
+	{{- if .Metadata.Tags}}
+	Tags {{.Metadata.Tags}}
+	{{- end}}
 	Version {{.Version}}
 	Seen First {{.SeenFirst}}
 	Seen Last {{.SeenLast}}
-{{end}}
+	{{- if .Metadata.Notes}}
+	Notes {{.Metadata.Notes}}
+	{{- end}}
+{{end}}{{end}}
 `
 
-var reportHTMLTemplate = `
+var reportHTMLTemplate = `<!DOCTYPE html>
 <html>
+<head>
+<meta charset="utf-8">
+<title>SSH Audit Report {{.Generated}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 14px; color: #222; margin: 2em; }
+h1 { font-size: 1.6em; }
+h2 { font-size: 1.3em; margin-top: 2em; border-bottom: 1px solid #ddd; padding-bottom: .3em; }
+.generated { color: #666; }
+.cards { display: flex; flex-wrap: wrap; gap: 1em; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: .8em 1.2em; min-width: 9em; }
+.card .value { font-size: 2em; font-weight: bold; }
+.card .label { color: #666; }
+.card .detail { color: #666; font-size: .9em; }
+.critical { border-top: 4px solid #8b0000; }
+.high { border-top: 4px solid #d9480f; }
+.medium { border-top: 4px solid #f0a500; }
+.low { border-top: 4px solid #2f9e44; }
+.unknown { border-top: 4px solid #999; }
+.bars { width: 100%; max-width: 60em; border-collapse: collapse; }
+.bars td { padding: .2em .5em; border: none; }
+.bars .name { white-space: nowrap; width: 1%; }
+.bars .count { text-align: right; width: 1%; }
+.bar { background: #4c6ef5; height: 1em; min-width: 1px; }
+.trend { display: flex; align-items: flex-end; height: 160px; gap: 6px; border-bottom: 1px solid #999; max-width: 60em; }
+.run { display: flex; align-items: flex-end; gap: 1px; height: 100%; }
+.run div { width: 8px; min-height: 1px; }
+.legend span { display: inline-block; width: .8em; height: .8em; margin: 0 .3em 0 1em; }
+.hosts-bar { background: #4c6ef5; }
+.vulns-bar { background: #d9480f; }
+.dupes-bar { background: #f0a500; }
+input.filter { margin: .5em 0; padding: .3em; width: 20em; }
+table.data { border-collapse: collapse; width: 100%; }
+table.data th, table.data td { border: 1px solid #ddd; padding: .3em .5em; text-align: left; vertical-align: top; }
+table.data th { background: #f4f4f4; cursor: pointer; user-select: none; white-space: nowrap; }
+table.data th[aria-sort=ascending]::after { content: " \25B2"; }
+table.data th[aria-sort=descending]::after { content: " \25BC"; }
+table.data tbody tr:nth-child(even) { background: #fafafa; }
+pre { margin: 0; white-space: pre-wrap; }
+</style>
+</head>
 <body>
 
-<h1>Vulnerabilities: {{ .VulnerabilitiesCount }}</h1>
-<table>
+<h1>SSH Audit Report</h1>
+<p class="generated">Generated {{.Generated}}</p>
+
+<div class="cards">
+{{- if .Includes "hosts"}}
+	<div class="card"><div class="value">{{.Summary.Hosts}}</div><div class="label">Active Hosts</div></div>
+{{- end}}
+{{- if .Includes "vulns"}}
+	<div class="card"><div class="value">{{.Summary.Vulnerabilities}}</div><div class="label">Vulnerabilities</div><div class="detail">on {{.Summary.VulnerableHosts}} hosts</div></div>
+	{{- range .Summary.Severities}}
+	<div class="card {{.Key}}"><div class="value">{{.Count}}</div><div class="label">{{.Key}}</div></div>
+	{{- end}}
+{{- end}}
+{{- if .Includes "dupes"}}
+	<div class="card"><div class="value">{{.Summary.DuplicateKeyClusters}}</div><div class="label">Duplicate Key Clusters</div><div class="detail">{{.Summary.DuplicateKeyHosts}} hosts</div></div>
+{{- end}}
+</div>
+
+{{if .Summary.Versions}}
+<h2>Versions</h2>
+<table class="bars">
+{{- range .Summary.Versions}}
+<tr>
+	<td class="name">{{if .Key}}{{.Key}}{{else}}(none){{end}}</td>
+	<td class="count">{{.Count}}</td>
+	<td><div class="bar" style="width: {{percent .Count $.Summary.Hosts}}%"></div></td>
+</tr>
+{{- end}}
+</table>
+{{end}}
+
+{{if .Summary.Trend}}
+<h2>Trend</h2>
+<p class="legend">Last {{len .Summary.Trend}} successful runs, across all hosts:
+<span class="hosts-bar"></span>Active Hosts<span class="vulns-bar"></span>Vulnerabilities<span class="dupes-bar"></span>Duplicate Keys</p>
+<div class="trend">
+{{- range .Summary.Trend}}
+	<div class="run" title="run {{.ID}} {{.Type}} {{.Finished}}: {{.ActiveHosts}} hosts, {{.Vulnerabilities}} vulnerabilities, {{.DuplicateKeys}} duplicate keys">
+		<div class="hosts-bar" style="height: {{percent .ActiveHosts $.Summary.TrendMax}}%"></div>
+		<div class="vulns-bar" style="height: {{percent .Vulnerabilities $.Summary.TrendMax}}%"></div>
+		<div class="dupes-bar" style="height: {{percent .DuplicateKeys $.Summary.TrendMax}}%"></div>
+	</div>
+{{- end}}
+</div>
+{{end}}
+
+{{if .Includes "vulns"}}
+<h2>Vulnerabilities: {{ .VulnerabilitiesCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="vulns-filter">
+<table class="data" id="vulns">
 <thead>
 	<tr>
 		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
 		<th>User</th>
 		<th>Password</th>
 		<th>Result</th>
+		<th>Severity</th>
+		<th>Privilege</th>
 		<th>Last Tested</th>
 		<th>Version</th>
+		<th>Auth Method</th>
+		<th>Host Key</th>
+		<th>Evidence</th>
 	</tr>
 </thead>
 <tbody>
 {{range .Vulnerabilities}}
 <tr>
 	<td> {{.Host.Hostport}} </td>
+	<td> {{.Host.Names}} </td>
+	<td> {{.Host.Metadata.Owner}} </td>
 	<td> {{.HostCredential.User}} </td>
 	<td> {{.HostCredential.Password}} </td>
 	<td> {{.HostCredential.Result}} </td>
+	<td data-sort="{{severityRank .Evidence.Severity}}"> {{.Evidence.Severity}} </td>
+	<td> {{.Evidence.Privilege}} </td>
 	<td> {{.HostCredential.LastTested}} </td>
 	<td> {{.Host.Version}} </td>
+	<td> {{.Evidence.AuthMethod}} </td>
+	<td> {{.Evidence.HostKeyFingerprint}} </td>
+	<td>
+		{{- if .Evidence.Collected}} Collected {{.Evidence.Collected}}<br>{{end}}
+		{{- if .Evidence.Banner}} Banner <pre>{{.Evidence.Banner}}</pre>{{end}}
+		{{- if .Evidence.IDOutput}} id <pre>{{.Evidence.IDOutput}}</pre>{{end}}
+		{{- if .Evidence.TunnelDestination}} Tunnel {{.Evidence.TunnelDestination}}<br>{{end}}
+		{{- if .Evidence.Capabilities}} Capabilities {{.Evidence.Capabilities}}{{end}}
+	</td>
 </tr>
 {{end}}
 </tbody>
 </table>
+{{end}}
 
-<h1>Duplicate Keys: {{ .DuplicateKeysCount }} </h1>
-{{ range $key, $hosts := .DuplicateKeys }}
-<h2> {{$key}} </h2>
-<table>
+{{if .Includes "dupes"}}
+<h2>Duplicate Keys: {{ .DuplicateKeysCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="dupes-filter">
+<table class="data" id="dupes">
 <thead>
 	<tr>
+		<th>Fingerprint</th>
 		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
 		<th>Version</th>
 		<th>Seen First</th>
 		<th>Seen Last</th>
 	</tr>
 </thead>
 <tbody>
+{{ range $key, $hosts := .DuplicateKeys }}
 {{ range $hosts }}
+<tr>
+	<td> {{$key}} </td>
+	<td> {{.Hostport}} </td>
+	<td> {{.Names}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Version}} </td>
+	<td> {{.SeenFirst}} </td>
+	<td> {{.SeenLast}} </td>
+</tr>
+{{end}}
+{{end}}
+</tbody>
+</table>
+{{end}}
+
+{{if .Includes "anomalies"}}
+<h2>Anomalous Hosts: {{ .AnomalousHostsCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="anomalies-filter">
+<table class="data" id="anomalies">
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
+		<th>Anomaly</th>
+		<th>Reason</th>
+		<th>Version</th>
+		<th>Seen Last</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .AnomalousHosts }}
 <tr>
 	<td> {{.Hostport}} </td>
+	<td> {{.Names}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Anomaly}} </td>
+	<td> {{.AnomalyReason}} </td>
 	<td> {{.Version}} </td>
+	<td> {{.SeenLast}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+
+{{if .Includes "inventory"}}
+<h2>Inventory Findings: {{ .InventoryFindingsCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="inventory-filter">
+<table class="data" id="inventory">
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Owner</th>
+		<th>Finding</th>
+		<th>Expected</th>
+		<th>Observed</th>
+		<th>Seen First</th>
+		<th>Seen Last</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .InventoryFindings }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Type}} </td>
+	<td> {{.Expected}} </td>
+	<td> {{.Observed}} </td>
 	<td> {{.SeenFirst}} </td>
 	<td> {{.SeenLast}} </td>
 </tr>
@@ -170,11 +616,76 @@ var reportHTMLTemplate = `
 </table>
 {{end}}
 
-<h1> Active Hosts: {{ .ActiveHostsCount }} </h1>
-<table>
+{{if .Includes "changes"}}
+<h2>Host Changes: {{ .HostChangesCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="changes-filter">
+<table class="data" id="changes">
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Owner</th>
+		<th>Time</th>
+		<th>Class</th>
+		<th>Old Fingerprint</th>
+		<th>New Fingerprint</th>
+		<th>Old Version</th>
+		<th>New Version</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .HostChanges }}
+<tr>
+	<td> {{.Hostport}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Time}} </td>
+	<td> {{.Classification}} </td>
+	<td> {{.OldFingerprint}} </td>
+	<td> {{.NewFingerprint}} </td>
+	<td> {{.OldVersion}} </td>
+	<td> {{.NewVersion}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+
+{{if .Includes "crypto"}}
+<h2>Weak Crypto: {{ .WeakCryptoCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="crypto-filter">
+<table class="data" id="crypto">
 <thead>
 	<tr>
 		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
+		<th>Issue</th>
+		<th>Version</th>
+	</tr>
+</thead>
+<tbody>
+{{ range .WeakCrypto }}
+<tr>
+	<td> {{.Host.Hostport}} </td>
+	<td> {{.Host.Names}} </td>
+	<td> {{.Host.Metadata.Owner}} </td>
+	<td> {{.Issue}} </td>
+	<td> {{.Host.Version}} </td>
+</tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+
+{{if .Includes "hosts"}}
+<h2>Active Hosts: {{ .ActiveHostsCount }}</h2>
+<input class="filter" type="search" placeholder="Filter" id="hosts-filter">
+<table class="data" id="hosts">
+<thead>
+	<tr>
+		<th>Host</th>
+		<th>Name</th>
+		<th>Owner</th>
+		<th>Tags</th>
 		<th>Version</th>
 		<th>Seen First</th>
 		<th>Seen Last</th>
@@ -184,6 +695,9 @@ var reportHTMLTemplate = `
 {{ range .ActiveHosts }}
 <tr>
 	<td> {{.Hostport}} </td>
+	<td> {{.Names}} </td>
+	<td> {{.Metadata.Owner}} </td>
+	<td> {{.Metadata.Tags}} </td>
 	<td> {{.Version}} </td>
 	<td> {{.SeenFirst}} </td>
 	<td> {{.SeenLast}} </td>
@@ -191,4 +705,119 @@ var reportHTMLTemplate = `
 {{end}}
 </tbody>
 </table>
+{{end}}
+
+<script>
+(function() {
+	function sortValue(row, i) {
+		var cell = row.cells[i];
+		var v = cell.getAttribute("data-sort");
+		return v !== null ? v : cell.textContent.trim();
+	}
+	var tables = document.querySelectorAll("table.data");
+	for (var t = 0; t < tables.length; t++) {
+		(function(table) {
+			var body = table.tBodies[0];
+			var headers = table.tHead.rows[0].cells;
+			for (var i = 0; i < headers.length; i++) {
+				(function(i) {
+					headers[i].addEventListener("click", function() {
+						var asc = this.getAttribute("aria-sort") !== "ascending";
+						for (var j = 0; j < headers.length; j++) {
+							headers[j].removeAttribute("aria-sort");
+						}
+						this.setAttribute("aria-sort", asc ? "ascending" : "descending");
+						var rows = Array.prototype.slice.call(body.rows);
+						rows.sort(function(a, b) {
+							var c = sortValue(a, i).localeCompare(sortValue(b, i), undefined, {numeric: true});
+							return asc ? c : -c;
+						});
+						for (var j = 0; j < rows.length; j++) {
+							body.appendChild(rows[j]);
+						}
+					});
+				})(i);
+			}
+			document.getElementById(table.id + "-filter").addEventListener("input", function() {
+				var q = this.value.toLowerCase();
+				for (var j = 0; j < body.rows.length; j++) {
+					var row = body.rows[j];
+					row.style.display = row.textContent.toLowerCase().indexOf(q) === -1 ? "none" : "";
+				}
+			});
+		})(tables[t]);
+	}
+})();
+</script>
+</body>
+</html>
+`
+
+var reportMarkdownTemplate = `
+{{- if .Includes "vulns"}}
+## Vulnerabilities: {{ .VulnerabilitiesCount }}
+
+| Host | Name | Owner | User | Password | Result | Severity | Privilege | Last Tested | Version |
+| --- | --- | --- | --- | --- | --- | --- | --- | --- | --- |
+{{- range .Vulnerabilities}}
+| {{md .Host.Hostport}} | {{md .Host.Names}} | {{md .Host.Metadata.Owner}} | {{md .HostCredential.User}} | {{md .HostCredential.Password}} | {{md .HostCredential.Result}} | {{md .Evidence.Severity}} | {{md .Evidence.Privilege}} | {{md .HostCredential.LastTested}} | {{md .Host.Version}} |
+{{- end}}
+{{end}}
+{{- if .Includes "dupes"}}
+## Duplicate Keys: {{ .DuplicateKeysCount }}
+{{range $key, $hosts := .DuplicateKeys}}
+### {{md $key}}
+
+| Host | Name | Owner | Version | Seen First | Seen Last |
+| --- | --- | --- | --- | --- | --- |
+{{- range $hosts}}
+| {{md .Hostport}} | {{md .Names}} | {{md .Metadata.Owner}} | {{md .Version}} | {{md .SeenFirst}} | {{md .SeenLast}} |
+{{- end}}
+{{end}}
+{{end}}
+{{- if .Includes "anomalies"}}
+## Anomalous Hosts: {{ .AnomalousHostsCount }}
+
+| Host | Name | Owner | Anomaly | Reason | Version | Seen Last |
+| --- | --- | --- | --- | --- | --- | --- |
+{{- range .AnomalousHosts}}
+| {{md .Hostport}} | {{md .Names}} | {{md .Metadata.Owner}} | {{md .Anomaly}} | {{md .AnomalyReason}} | {{md .Version}} | {{md .SeenLast}} |
+{{- end}}
+{{end}}
+{{- if .Includes "inventory"}}
+## Inventory Findings: {{ .InventoryFindingsCount }}
+
+| Host | Owner | Finding | Expected | Observed | Seen First | Seen Last |
+| --- | --- | --- | --- | --- | --- | --- |
+{{- range .InventoryFindings}}
+| {{md .Hostport}} | {{md .Metadata.Owner}} | {{md .Type}} | {{md .Expected}} | {{md .Observed}} | {{md .SeenFirst}} | {{md .SeenLast}} |
+{{- end}}
+{{end}}
+{{- if .Includes "changes"}}
+## Host Changes: {{ .HostChangesCount }}
+
+| Host | Owner | Time | Class | Old Fingerprint | New Fingerprint | Old Version | New Version |
+| --- | --- | --- | --- | --- | --- | --- | --- |
+{{- range .HostChanges}}
+| {{md .Hostport}} | {{md .Metadata.Owner}} | {{md .Time}} | {{md .Classification}} | {{md .OldFingerprint}} | {{md .NewFingerprint}} | {{md .OldVersion}} | {{md .NewVersion}} |
+{{- end}}
+{{end}}
+{{- if .Includes "crypto"}}
+## Weak Crypto: {{ .WeakCryptoCount }}
+
+| Host | Name | Owner | Issue | Version |
+| --- | --- | --- | --- | --- |
+{{- range .WeakCrypto}}
+| {{md .Host.Hostport}} | {{md .Host.Names}} | {{md .Host.Metadata.Owner}} | {{md .Issue}} | {{md .Host.Version}} |
+{{- end}}
+{{end}}
+{{- if .Includes "hosts"}}
+## Active Hosts: {{ .ActiveHostsCount }}
+
+| Host | Name | Owner | Tags | Version | Seen First | Seen Last |
+| --- | --- | --- | --- | --- | --- | --- |
+{{- range .ActiveHosts}}
+| {{md .Hostport}} | {{md .Names}} | {{md .Metadata.Owner}} | {{md .Metadata.Tags}} | {{md .Version}} | {{md .SeenFirst}} | {{md .SeenLast}} |
+{{- end}}
+{{end}}
 `
@@ -12,11 +12,20 @@ var rescanCmd = &cobra.Command{
 	Use:   "rescan",
 	Short: "Rescan hosts with credentials that have previously worked",
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadAuthOptions(); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
 		scanConfig := sshauditor.ScanConfiguration{
 			Concurrency: concurrency,
+			AuthOptions: authOptions,
+		}
+		auditor, err := newAuditor()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
 		}
-		auditor := sshauditor.New(store)
-		_, err := auditor.Rescan(scanConfig)
+		_, err = auditor.Rescan(scanConfig)
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -25,5 +34,8 @@ var rescanCmd = &cobra.Command{
 }
 
 func init() {
+	addAuthOptionFlags(rescanCmd)
+	addNotifyFlags(rescanCmd)
+	addMetricsFlags(rescanCmd)
 	RootCmd.AddCommand(rescanCmd)
 }
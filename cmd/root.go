@@ -12,6 +12,29 @@ var store *sshauditor.SQLiteStore
 var dbPath string
 var debug bool
 var concurrency int
+var secretKeyFile string
+var logConfig sshauditor.LogConfig
+var logMaxSizeMB int64
+
+//secretKeyEnv is the environment variable that can hold the secret key used
+//to encrypt credentials in the store, as an alternative to --secret-key-file
+const secretKeyEnv = "SSH_AUDITOR_SECRET_KEY"
+
+func loadSecretBox() (*sshauditor.SecretBox, error) {
+	var key []byte
+	var err error
+	if secretKeyFile != "" {
+		key, err = sshauditor.LoadSecretKey(secretKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	} else if env := os.Getenv(secretKeyEnv); env != "" {
+		key = []byte(env)
+	} else {
+		return nil, nil
+	}
+	return sshauditor.NewSecretBox(key)
+}
 
 func initStore() error {
 	//This should really return err, but it doesn't look as nice as when I fail immediately
@@ -26,6 +49,21 @@ func initStore() error {
 		log.Error(err.Error())
 		os.Exit(1)
 	}
+	secrets, err := loadSecretBox()
+	if err != nil {
+		log.Error(err.Error())
+		os.Exit(1)
+	}
+	if secrets != nil {
+		updated, err := s.SetSecretBox(secrets)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		if updated != 0 {
+			log.Info("encrypted credentials stored before the secret key was configured", "rows", updated)
+		}
+	}
 	store = s
 	return err
 }
@@ -35,15 +73,14 @@ var RootCmd = &cobra.Command{
 	Short: "ssh-auditor tests ssh server password security",
 	Long:  `Complete documentation is available at https://github.com/ncsa/ssh-auditor`,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		if debug {
-			log.Root().SetHandler(log.LvlFilterHandler(
-				log.LvlDebug,
-				log.StderrHandler))
-		} else {
-			log.Root().SetHandler(log.LvlFilterHandler(
-				log.LvlInfo,
-				log.StderrHandler))
+		logConfig.Debug = debug
+		logConfig.MaxSize = logMaxSizeMB * 1024 * 1024
+		h, err := logConfig.Handler()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
 		}
+		log.Root().SetHandler(h)
 		return initStore()
 	},
 }
@@ -52,4 +89,10 @@ func init() {
 	RootCmd.PersistentFlags().IntVar(&concurrency, "concurrency", 256, "Number of concurrent hosts to scan at once")
 	RootCmd.PersistentFlags().StringVar(&dbPath, "db", "ssh_db.sqlite", "Path to database file")
 	RootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "debug")
+	RootCmd.PersistentFlags().StringVar(&logConfig.Format, "log-format", sshauditor.LogFormatTerminal, "log format: terminal, logfmt or json")
+	RootCmd.PersistentFlags().StringVar(&logConfig.File, "log-file", "", "also write the log to this file")
+	RootCmd.PersistentFlags().Int64Var(&logMaxSizeMB, "log-file-max-size", 100, "rotate the log file when it reaches this many megabytes, 0 to never rotate")
+	RootCmd.PersistentFlags().IntVar(&logConfig.Backups, "log-file-backups", 5, "number of rotated log files to keep")
+	RootCmd.PersistentFlags().StringVar(&logConfig.Syslog, "log-syslog", "", "also send the log to syslog: local, udp://host:port or tcp://host:port")
+	RootCmd.PersistentFlags().StringVar(&secretKeyFile, "secret-key-file", "", "File containing the key used to encrypt credentials in the database (or set "+secretKeyEnv+")")
 }
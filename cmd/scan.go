@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	log "github.com/inconshreveable/log15"
@@ -8,15 +9,74 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var authOptions sshauditor.AuthOptions
+var falsePositiveRulesFile string
+var honeypotRulesFile string
+
+//loadAuthOptions fills in the parts of authOptions that are read from files
+func loadAuthOptions() error {
+	if falsePositiveRulesFile != "" {
+		rules, err := sshauditor.LoadRules(falsePositiveRulesFile)
+		if err != nil {
+			return err
+		}
+		authOptions.FalsePositiveRules = append(sshauditor.DefaultFalsePositiveRules, rules...)
+	}
+	if honeypotRulesFile != "" {
+		rules, err := sshauditor.LoadRules(honeypotRulesFile)
+		if err != nil {
+			return err
+		}
+		authOptions.HoneypotRules = append(sshauditor.DefaultHoneypotRules, rules...)
+	}
+	switch authOptions.TunnelVerification {
+	case sshauditor.TunnelVerifyDial:
+	case sshauditor.TunnelVerifyCanary:
+		if len(authOptions.TunnelCanaries) == 0 {
+			return fmt.Errorf("--tunnel-verify canary requires at least one --tunnel-canary")
+		}
+	default:
+		return fmt.Errorf("unknown tunnel verification mode %q", authOptions.TunnelVerification)
+	}
+	return nil
+}
+
+//addHoneypotFlags adds the flags that control honeypot detection to cmd
+func addHoneypotFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&authOptions.HoneypotCheck, "honeypot-check", true, "tag hosts that look like honeypots and exclude them from vulnerabilities")
+	cmd.Flags().StringVar(&honeypotRulesFile, "honeypot-rules", "", "JSON file of additional honeypot rules")
+}
+
+//addAuthOptionFlags adds the flags that control the checks done after a
+//successful login to cmd
+func addAuthOptionFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&authOptions.SudoProbe, "sudo-probe", false, "run 'sudo -n true' after a successful login to check for passwordless sudo")
+	cmd.Flags().BoolVar(&authOptions.CapabilityProbes, "capability-probes", true, "check for sftp, port forwarding, agent forwarding, x11 and shell access after a successful login.  When exec is blocked the shell check starts a login shell, which runs the account's startup files and is recorded in the host's login records")
+	cmd.Flags().StringVar(&authOptions.TunnelVerification, "tunnel-verify", sshauditor.TunnelVerifyDial, "how to verify tunnels: dial (back to the host itself) or canary (nonce round trip through --tunnel-canary)")
+	cmd.Flags().StringSliceVar(&authOptions.TunnelCanaries, "tunnel-canary", nil, "host:port of a canary listener started with 'ssh-auditor canary', can be repeated")
+	cmd.Flags().BoolVar(&authOptions.AcceptsAnythingCheck, "accepts-anything-check", true, "try a random user and password on hosts where a credential worked")
+	cmd.Flags().StringVar(&falsePositiveRulesFile, "false-positive-rules", "", "JSON file of additional false positive rules")
+	addHoneypotFlags(cmd)
+}
+
 var scanCmd = &cobra.Command{
 	Use:   "scan",
 	Short: "Scan hosts using new or outdated credentials",
 	Run: func(cmd *cobra.Command, args []string) {
+		if err := loadAuthOptions(); err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
 		scanConfig := sshauditor.ScanConfiguration{
 			Concurrency: concurrency,
+			AuthOptions: authOptions,
+		}
+		auditor, err := newAuditor()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
 		}
-		auditor := sshauditor.New(store)
-		_, err := auditor.Scan(scanConfig)
+		_, err = auditor.Scan(scanConfig)
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
@@ -38,6 +98,9 @@ var scanResetIntervalCmd = &cobra.Command{
 }
 
 func init() {
+	addAuthOptionFlags(scanCmd)
+	addNotifyFlags(scanCmd)
+	addMetricsFlags(scanCmd)
 	RootCmd.AddCommand(scanCmd)
 	scanCmd.AddCommand(scanResetIntervalCmd)
 }
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"os"
+	"text/template"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+//smtpPasswordEnv is the environment variable that holds the SMTP password
+const smtpPasswordEnv = "SSH_AUDITOR_SMTP_PASSWORD"
+
+var smtpServer string
+var smtpFrom string
+var smtpUser string
+var sendSubject string
+var sendTemplateFile string
+var sendDefaultTo string
+var sendOwners []string
+var sendDryRun bool
+
+//loadMailer builds a Mailer from the report send flags
+func loadMailer() (sshauditor.Mailer, error) {
+	m := sshauditor.NewMailer(smtpServer, smtpFrom)
+	if smtpUser != "" {
+		host, _, err := net.SplitHostPort(smtpServer)
+		if err != nil {
+			return m, err
+		}
+		m.Auth = smtp.PlainAuth("", smtpUser, os.Getenv(smtpPasswordEnv), host)
+	}
+	subject, err := template.New("subject").Parse(sendSubject)
+	if err != nil {
+		return m, err
+	}
+	m.Subject = subject
+	if sendTemplateFile != "" {
+		body, err := ioutil.ReadFile(sendTemplateFile)
+		if err != nil {
+			return m, err
+		}
+		m.Template, err = template.New("body").Parse(string(body))
+		if err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+var reportSendCmd = &cobra.Command{
+	Use:     "send",
+	Example: "report send --smtp-server mail.example.com:25 --from ssh-auditor@example.com --default-to security@example.com",
+	Short:   "email each host owner the findings for their hosts",
+	Long: `Email each host owner the findings for their hosts.
+
+The report is split by the owner set with 'host owner' or 'host metadata
+import'.  Each owner gets only the vulnerabilities, duplicate keys and weak
+crypto findings (ssh-dss or small RSA host keys, SSH-1) for their hosts.
+Findings for hosts without an owner go to --default-to.
+
+The subject and the --template file are Go text templates executed with the
+owner's report, which has the same fields as the json report plus Owner.
+The SMTP password is read from ` + smtpPasswordEnv + `.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		mailer, err := loadMailer()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		report, err := loadReport()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		reports := report.ByOwner()
+		if len(sendOwners) != 0 {
+			only := make(map[string]sshauditor.OwnerReport)
+			for _, owner := range sendOwners {
+				if rep, ok := reports[owner]; ok {
+					only[owner] = rep
+				}
+			}
+			reports = only
+		}
+		if sendDryRun {
+			if err := mailer.WriteOwnerReports(os.Stdout, reports, sendDefaultTo); err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+		sent, err := mailer.SendOwnerReports(reports, sendDefaultTo)
+		log.Info("sent owner reports", "sent", len(sent), "owners", len(reports))
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	reportSendCmd.Flags().StringVar(&smtpServer, "smtp-server", "localhost:25", "SMTP server host:port")
+	reportSendCmd.Flags().StringVar(&smtpFrom, "from", "ssh-auditor@localhost", "From address")
+	reportSendCmd.Flags().StringVar(&smtpUser, "smtp-user", "", "SMTP user, the password is read from "+smtpPasswordEnv)
+	reportSendCmd.Flags().StringVar(&sendSubject, "subject", sshauditor.DefaultOwnerReportSubject, "subject template")
+	reportSendCmd.Flags().StringVar(&sendTemplateFile, "template", "", "file containing the message body template")
+	reportSendCmd.Flags().StringVar(&sendDefaultTo, "default-to", "", "address to send findings for hosts without an owner to")
+	reportSendCmd.Flags().StringSliceVar(&sendOwners, "owner", nil, "only send to these owners")
+	reportSendCmd.Flags().BoolVar(&sendDryRun, "dry-run", false, "write the messages to stdout instead of sending them")
+	addReportFlags(reportSendCmd)
+	reportCmd.AddCommand(reportSendCmd)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	html_template "html/template"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	text_template "text/template"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/ncsa/ssh-auditor/sshauditor"
+	"github.com/spf13/cobra"
+)
+
+var templateFile string
+var templateMode string
+
+//executeReportTemplate runs the template in file against report.  html mode
+//escapes the output with html/template.
+func executeReportTemplate(file, mode string, report sshauditor.AuditReport) error {
+	body, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if mode == "" {
+		mode = "text"
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".html", ".htm":
+			mode = "html"
+		}
+	}
+	name := filepath.Base(file)
+	funcs := sshauditor.ReportTemplateFuncs()
+	switch mode {
+	case "text":
+		t, err := text_template.New(name).Funcs(funcs).Parse(string(body))
+		if err != nil {
+			return err
+		}
+		return t.Execute(os.Stdout, report)
+	case "html":
+		t, err := html_template.New(name).Funcs(funcs).Parse(string(body))
+		if err != nil {
+			return err
+		}
+		return t.Execute(os.Stdout, report)
+	}
+	return fmt.Errorf("unknown template mode %q", mode)
+}
+
+var reportTemplateCmd = &cobra.Command{
+	Use:     "template",
+	Example: "report template --file owners.tmpl --sections vulns,dupes",
+	Short:   "report using your own Go template",
+	Long: `Report using your own Go template.
+
+The template is executed with the same report as 'report json', so every
+field in it can be used.  --mode is text or html, html escapes the output.
+It defaults to html for .html files and text otherwise.
+
+Besides the text/template builtins, templates can use:
+
+	groupBy "Host.Metadata.Owner" .Vulnerabilities  groups, each with .Key, .Items and .Count
+	countBy "Result" .Vulnerabilities               keys with .Key and .Count, most common first
+	sortBy "Host.Hostport" .Vulnerabilities         items sorted by a field
+	date "Jan 2, 2006" .LastTested                  formats a time
+	now                                             the current time
+	redact .Password                                a masked secret, with --redact=false
+	join ", " .List, lower, upper                   string helpers
+	percent .Count .Total                           a whole percentage
+	severityRank .Evidence.Severity                 0 for critical, 1 for high and so on`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if templateFile == "" {
+			log.Error("--file is required")
+			os.Exit(1)
+		}
+		report, err := loadReport()
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+		err = executeReportTemplate(templateFile, templateMode, report)
+		if err != nil {
+			log.Error(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	reportTemplateCmd.Flags().StringVar(&templateFile, "file", "", "template file")
+	reportTemplateCmd.Flags().StringVar(&templateMode, "mode", "", "text or html (default from the file extension)")
+	addReportFlags(reportTemplateCmd)
+	reportCmd.AddCommand(reportTemplateCmd)
+}
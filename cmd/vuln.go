@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 
@@ -9,29 +10,70 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var redact bool
+var showEvidence bool
+var minSeverity string
+var showAnomalous bool
+
 var vulnCmd = &cobra.Command{
 	Use:   "vuln",
 	Short: "Show vulnerabilities",
 	Run: func(cmd *cobra.Command, args []string) {
+		if showAnomalous {
+			hosts, err := store.GetAnomalousHosts()
+			if err != nil {
+				log.Error(err.Error())
+				os.Exit(1)
+			}
+			for _, h := range hosts {
+				fmt.Printf("%s\t%s\t%s\t%s\n", h.Hostport, h.Anomaly, h.AnomalyReason, h.Version)
+			}
+			return
+		}
 		auditor := sshauditor.New(store)
 		vulns, err := auditor.Vulnerabilities()
 		if err != nil {
 			log.Error(err.Error())
 			os.Exit(1)
 		}
+		if redact {
+			vulns = sshauditor.RedactVulnerabilities(vulns)
+		}
+		if minSeverity != "" {
+			if sshauditor.SeverityRank(minSeverity) == len(sshauditor.Severities) {
+				log.Error("invalid severity", "severity", minSeverity)
+				os.Exit(1)
+			}
+			vulns = sshauditor.FilterVulnerabilitiesBySeverity(vulns, minSeverity)
+		}
+		if showEvidence {
+			w := json.NewEncoder(os.Stdout)
+			for _, v := range vulns {
+				if err := w.Encode(v); err != nil {
+					panic(err)
+				}
+			}
+			return
+		}
 		for _, v := range vulns {
-			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\n",
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
 				v.Host.Hostport,
 				v.HostCredential.User,
 				v.HostCredential.Password,
 				v.HostCredential.Result,
 				v.HostCredential.LastTested,
 				v.Host.Version,
+				v.Evidence.Severity,
+				v.Host.Names(),
 			)
 		}
 	},
 }
 
 func init() {
+	vulnCmd.Flags().BoolVar(&redact, "redact", true, "mask each password instead of showing it")
+	vulnCmd.Flags().BoolVar(&showEvidence, "evidence", false, "output each vulnerability with its evidence as JSON")
+	vulnCmd.Flags().StringVar(&minSeverity, "min-severity", "", "only show vulnerabilities at least this severe (critical, high, medium, low)")
+	vulnCmd.Flags().BoolVar(&showAnomalous, "anomalous", false, "show hosts excluded from vulnerabilities as honeypots, tarpits, or because they accept any password")
 	RootCmd.AddCommand(vulnCmd)
 }
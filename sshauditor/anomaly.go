@@ -0,0 +1,73 @@
+package sshauditor
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	log "github.com/inconshreveable/log15"
+)
+
+//Anomalies are stored on a host when it behaves in a way that makes its
+//brute force results meaningless.  Vulnerabilities on these hosts are reported
+//separately.
+const (
+	//AnomalyAcceptsAnything is a host that accepted a random user and password
+	AnomalyAcceptsAnything = "accepts-anything"
+)
+
+func randomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//acceptsAnything returns true if a random user and password that can't
+//possibly be valid is able to log in to hostport.  It also returns the
+//evidence from the nonsense login.
+func acceptsAnything(hostport string, opts AuthOptions) (bool, Evidence, error) {
+	cred := Credential{
+		User:     "sa-" + randomString(4),
+		Password: randomString(16),
+	}
+	//Only the login itself matters here
+	opts.SudoProbe = false
+	opts.HoneypotCheck = false
+	opts.CapabilityProbes = false
+	result, ev, err := SSHCredentialAuthAttempt(hostport, cred, opts)
+	if err != nil {
+		return false, ev, err
+	}
+	if result != "" {
+		log.Warn("host accepted a random user and password", "host", hostport, "user", cred.User, "result", result)
+		return true, ev, nil
+	}
+	return false, ev, nil
+}
+
+//checkAnomalies runs the enabled anomaly checks against a host that cred
+//worked on.  It returns the anomaly and the reason for it, or a blank anomaly
+//if the host looks normal.  ok is false if the checks could not be completed.
+func checkAnomalies(hostport string, cred Credential, ev Evidence, opts AuthOptions) (anomaly, reason string, ok bool) {
+	var anything bool
+	if opts.AcceptsAnythingCheck {
+		var err error
+		anything, _, err = acceptsAnything(hostport, opts)
+		if err != nil {
+			log.Error("accepts anything check failed", "host", hostport, "err", err)
+			return "", "", false
+		}
+	}
+	if opts.HoneypotCheck {
+		reasons, honeypot := detectHoneypot(cred, ev, anything, opts.honeypotRules())
+		if honeypot {
+			reason := strings.Join(reasons, ", ")
+			log.Warn("host looks like a honeypot", "host", hostport, "reason", reason)
+			return AnomalyHoneypot, reason, true
+		}
+	}
+	if anything {
+		return AnomalyAcceptsAnything, "accepted a random user and password", true
+	}
+	return "", "", true
+}
@@ -17,6 +17,12 @@ type ScanConfiguration struct {
 	Exclude     []string
 	Ports       []int
 	Concurrency int
+	//MinIPv6Prefix is the shortest IPv6 prefix that will be enumerated,
+	//DefaultMinIPv6Prefix is used if this is 0
+	MinIPv6Prefix int
+	//Randomize discovers hosts in a random order instead of sequentially
+	Randomize bool
+	AuthOptions
 }
 type AuditResult struct {
 	totalCount int
@@ -33,6 +39,21 @@ type AuditReport struct {
 
 	Vulnerabilities      []Vulnerability
 	VulnerabilitiesCount int
+
+	AnomalousHosts      []Host
+	AnomalousHostsCount int
+
+	InventoryFindings      []InventoryFinding
+	InventoryFindingsCount int
+
+	HostChanges      []HostChange
+	HostChangesCount int
+
+	WeakCrypto      []CryptoFinding
+	WeakCryptoCount int
+
+	//Sections are the sections selected with Select, nil for all of them
+	Sections []string `json:"-"`
 }
 
 func joinInts(ints []int, sep string) string {
@@ -47,22 +68,28 @@ func joinInts(ints []int, sep string) string {
 //of all hostports that match the scan configuration.
 func expandScanConfiguration(cfg ScanConfiguration) (chan string, error) {
 	hostChan := make(chan string, 1024)
-	hosts, err := EnumerateHosts(cfg.Include, cfg.Exclude)
+	minIPv6Prefix := cfg.MinIPv6Prefix
+	if minIPv6Prefix == 0 {
+		minIPv6Prefix = DefaultMinIPv6Prefix
+	}
+	hosts, err := NewHostEnumerator(cfg.Include, cfg.Exclude, minIPv6Prefix)
 	if err != nil {
 		return hostChan, err
 	}
 	log.Info("discovering hosts",
 		"include", strings.Join(cfg.Include, ","),
 		"exclude", strings.Join(cfg.Exclude, ","),
-		"total", len(hosts),
+		"total", hosts.Count(),
 		"ports", joinInts(cfg.Ports, ","),
+		"randomize", cfg.Randomize,
 	)
 	go func() {
 		// Iterate over ports first, so for a large scan there's a
 		// delay between attempts per host
 		for _, port := range cfg.Ports {
 			portString := strconv.Itoa(port)
-			for _, h := range hosts {
+			it := hosts.Iterator(cfg.Randomize)
+			for h, ok := it.Next(); ok; h, ok = it.Next() {
 				hostChan <- net.JoinHostPort(h, portString)
 			}
 		}
@@ -73,7 +100,12 @@ func expandScanConfiguration(cfg ScanConfiguration) (chan string, error) {
 
 type SSHAuditor struct {
 	//TODO: should be interface
-	store *SQLiteStore
+	store     *SQLiteStore
+	notifiers []Notifier
+	//notifications delivers events to the notifiers in the background
+	notifications notificationQueue
+	//storeMetrics is set when metrics are being served
+	storeMetrics bool
 }
 
 func New(store *SQLiteStore) *SSHAuditor {
@@ -82,12 +114,28 @@ func New(store *SQLiteStore) *SSHAuditor {
 	}
 }
 
-func (a *SSHAuditor) updateStoreFromDiscovery(hosts chan SSHHost) error {
+//knownHoneypotKey returns the honeypot rule that matches the host key of h
+func knownHoneypotKey(h SSHHost, rules []Rule) (Rule, bool) {
+	ev := Evidence{HostKeyFingerprint: h.keyfp}
+	for _, r := range rules {
+		if r.Field == "hostkey" && r.Matches(ev) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (a *SSHAuditor) updateStoreFromDiscovery(hosts chan SSHHost, cfg ScanConfiguration, names map[string]string) error {
 	knownHosts, err := a.store.getKnownHosts()
 	if err != nil {
 		return err
 	}
 	log.Info("current known hosts", "count", len(knownHosts))
+	expectedKeys, err := a.store.GetExpectedKeys()
+	if err != nil {
+		return err
+	}
+	inv := newInventory(expectedKeys)
 	var totalCount, updatedCount, newCount int
 
 	hostsWrapped := make(chan interface{})
@@ -102,13 +150,16 @@ func (a *SSHAuditor) updateStoreFromDiscovery(hosts chan SSHHost) error {
 		if err != nil {
 			return errors.Wrap(err, "updateStoreFromDiscovery")
 		}
+		var events []Event
 		for _, host := range hostBatch {
 			host := host.(SSHHost)
+			host.hostname = hostnameFor(host.hostport, names)
 			var needUpdate bool
 			rec, existing := knownHosts[host.hostport]
 			if existing {
 				if host.keyfp == "" {
 					host.keyfp = rec.Fingerprint
+					host.hostKey = rec.HostKey
 				}
 				if host.version == "" {
 					host.version = rec.Version
@@ -119,7 +170,17 @@ func (a *SSHAuditor) updateStoreFromDiscovery(hosts chan SSHHost) error {
 					return errors.Wrap(err, "updateStoreFromDiscovery")
 				}
 			}
-			l := log.New("host", host.hostport, "version", host.version, "fp", host.keyfp)
+			l := log.New("host", host.hostport, "hostname", host.hostname, "version", host.version, "fp", host.keyfp)
+			if existing && needUpdate {
+				e := a.alertHostChange(HostChange{
+					Hostport:       host.hostport,
+					OldFingerprint: rec.Fingerprint,
+					NewFingerprint: host.keyfp,
+					OldVersion:     rec.Version,
+					NewVersion:     host.version,
+				}, l)
+				events = append(events, e)
+			}
 			if !existing || needUpdate {
 				err = a.store.addOrUpdateHost(host)
 				if err != nil {
@@ -132,10 +193,45 @@ func (a *SSHAuditor) updateStoreFromDiscovery(hosts chan SSHHost) error {
 				if err != nil {
 					return errors.Wrap(err, "updateStoreFromDiscovery")
 				}
+				if !needUpdate && host.hostKey != "" && host.hostKey != rec.HostKey {
+					err = a.store.setHostKey(host)
+					if err != nil {
+						return errors.Wrap(err, "updateStoreFromDiscovery")
+					}
+				}
+				if (host.hostname != "" && host.hostname != rec.Hostname) || host.reverseDNS != rec.ReverseDNS {
+					err = a.store.setHostNames(host)
+					if err != nil {
+						return errors.Wrap(err, "updateStoreFromDiscovery")
+					}
+				}
+			}
+			if host.anomaly == "" && cfg.HoneypotCheck {
+				if rule, honeypot := knownHoneypotKey(host, cfg.honeypotRules()); honeypot {
+					host.anomaly = AnomalyHoneypot
+					host.anomalyReason = "matched rule " + rule.Name
+				}
+			}
+			if host.anomaly != "" {
+				l.Warn("host anomaly", "anomaly", host.anomaly, "reason", host.anomalyReason)
+				err = a.store.setHostAnomaly(host.hostport, host.anomaly, host.anomalyReason)
+			} else {
+				err = a.store.clearHostAnomaly(host.hostport, AnomalyTarpit)
+			}
+			if err != nil {
+				return errors.Wrap(err, "updateStoreFromDiscovery")
+			}
+			if !inv.empty() {
+				err = a.checkInventory(inv, host, l)
+				if err != nil {
+					return errors.Wrap(err, "updateStoreFromDiscovery")
+				}
 			}
 			totalCount++
 			if !existing {
 				l.Info("discovered new host")
+				events = append(events, discoveryEvent(host))
+				auditorMetrics.hostsDiscovered.Add(1)
 				newCount++
 			} else if needUpdate {
 				l.Info("discovered changed host")
@@ -146,11 +242,45 @@ func (a *SSHAuditor) updateStoreFromDiscovery(hosts chan SSHHost) error {
 		if err != nil {
 			return errors.Wrap(err, "updateStoreFromDiscovery")
 		}
+		for _, e := range events {
+			a.notify(e)
+		}
 	}
 	log.Info("discovery report", "total", totalCount, "new", newCount, "updated", updatedCount)
 	return nil
 }
 
+//alertHostChange classifies a change to a host and raises an alert if it is
+//suspicious.  It returns the event to notify about once the change is stored.
+func (a *SSHAuditor) alertHostChange(c HostChange, l log.Logger) Event {
+	c.classify()
+	if c.Classification == ChangeSuspicious {
+		l.Crit("suspicious host key change", "old_fp", c.OldFingerprint, "new_fp", c.NewFingerprint)
+	}
+	return hostChangeEvent(c)
+}
+
+//checkInventory compares a discovered host against the expected host keys
+//and records the result
+func (a *SSHAuditor) checkInventory(inv inventory, host SSHHost, l log.Logger) error {
+	finding, expected := inv.check(host)
+	switch finding {
+	case "":
+		//Keep an earlier finding if the key couldn't be fetched this time
+		if host.keyfp == "" {
+			return nil
+		}
+		return a.store.clearInventoryFindings(host.hostport)
+	case FindingHostKeyMismatch:
+		l.Error("host key does not match inventory", "expected", expected)
+	case FindingUnexpectedKeyType:
+		l.Error("host key type is not in inventory", "expected", expected)
+	default:
+		l.Warn("host is not in the inventory")
+	}
+	return a.store.setInventoryFinding(host.hostport, finding, expected, host.keyfp)
+}
+
 func (a *SSHAuditor) updateQueues() error {
 	queued, err := a.store.initHostCreds()
 	if err != nil {
@@ -161,10 +291,14 @@ func (a *SSHAuditor) updateQueues() error {
 		return err
 	}
 	log.Info("brute force queue size", "new", queued, "total", queuesize)
-	return nil
+	return a.updateStoreMetrics()
 }
 
-func (a *SSHAuditor) Discover(cfg ScanConfiguration) error {
+func (a *SSHAuditor) Discover(cfg ScanConfiguration) (err error) {
+	defer observeRun("discover", time.Now(), &err)
+	defer a.recordRun("discover", time.Now(), &err)
+	defer a.flushNotifications()
+	names := resolveTargets(&cfg)
 	//Push all candidate hosts into the banner fetcher queue
 	hostChan, err := expandScanConfiguration(cfg)
 	if err != nil {
@@ -174,7 +308,44 @@ func (a *SSHAuditor) Discover(cfg ScanConfiguration) error {
 	portResults := bannerFetcher(cfg.Concurrency*2, hostChan)
 	keyResults := fingerPrintFetcher(cfg.Concurrency, portResults)
 
-	err = a.updateStoreFromDiscovery(keyResults)
+	err = a.updateStoreFromDiscovery(keyResults, cfg, names)
+	if err != nil {
+		return err
+	}
+
+	err = a.updateQueues()
+	return err
+}
+
+//DiscoverImport runs discovery on the open ssh ports found by another
+//scanner.  The banner scan is skipped, so any port can be used.
+func (a *SSHAuditor) DiscoverImport(imp Import, cfg ScanConfiguration) (err error) {
+	defer observeRun("discover", time.Now(), &err)
+	defer a.recordRun("discover", time.Now(), &err)
+	defer a.flushNotifications()
+	resolveTargets(&cfg)
+	excluded, err := parseAddressList(cfg.Exclude)
+	if err != nil {
+		return err
+	}
+	log.Info("discovering imported hosts",
+		"total", imp.Count(),
+		"exclude", strings.Join(cfg.Exclude, ","),
+	)
+	portResults := make(chan ScanResult, 1024)
+	go func() {
+		for _, res := range imp.hosts {
+			host, _, err := net.SplitHostPort(res.hostport)
+			if err == nil && excluded.contains(host) {
+				continue
+			}
+			portResults <- res
+		}
+		close(portResults)
+	}()
+	keyResults := fingerPrintFetcher(cfg.Concurrency, portResults)
+
+	err = a.updateStoreFromDiscovery(keyResults, cfg, imp.names)
 	if err != nil {
 		return err
 	}
@@ -183,10 +354,11 @@ func (a *SSHAuditor) Discover(cfg ScanConfiguration) error {
 	return err
 }
 
-func (a *SSHAuditor) brute(scantype string, cfg ScanConfiguration) (AuditResult, error) {
-	var res AuditResult
+func (a *SSHAuditor) brute(scantype string, cfg ScanConfiguration) (res AuditResult, err error) {
+	defer observeRun(scantype, time.Now(), &err)
+	defer a.recordRun(scantype, time.Now(), &err)
+	defer a.flushNotifications()
 	a.updateQueues()
-	var err error
 
 	var sc []ScanRequest
 	switch scantype {
@@ -198,7 +370,7 @@ func (a *SSHAuditor) brute(scantype string, cfg ScanConfiguration) (AuditResult,
 	if err != nil {
 		return res, errors.Wrap(err, "Error getting scan queue")
 	}
-	bruteResults := bruteForcer(cfg.Concurrency, sc)
+	bruteResults := bruteForcer(cfg.Concurrency, sc, cfg.AuthOptions)
 
 	bruteResultsWrapped := make(chan interface{})
 	go func() {
@@ -208,6 +380,19 @@ func (a *SSHAuditor) brute(scantype string, cfg ScanConfiguration) (AuditResult,
 		close(bruteResultsWrapped)
 	}()
 
+	//Hosts that accept anything or look like honeypots don't have real
+	//findings, so they are not notified about
+	anomalous := make(map[string]bool)
+	if len(a.notifiers) != 0 {
+		hosts, err := a.store.GetAnomalousHosts()
+		if err != nil {
+			return res, errors.Wrap(err, "brute")
+		}
+		for _, h := range hosts {
+			anomalous[h.Hostport] = true
+		}
+	}
+
 	var totalCount, errCount, negCount, posCount int
 	for bruteBatch := range batch(context.TODO(), bruteResultsWrapped, 50, 2*time.Second) {
 		_, err = a.store.Begin()
@@ -215,36 +400,77 @@ func (a *SSHAuditor) brute(scantype string, cfg ScanConfiguration) (AuditResult,
 			return res, errors.Wrap(err, "brute")
 		}
 
+		var events []Event
 		for _, br := range bruteBatch {
 			br := br.(BruteForceResult)
 			l := log.New(
 				"host", br.hostport,
 				"user", br.cred.User,
-				"password", br.cred.Password,
+				"password", RedactSecret(br.cred.Password),
 				"result", br.result,
+				"severity", br.evidence.Severity,
 			)
 			if br.err != nil {
 				l.Error("brute force error", "err", br.err.Error())
+				auditorMetrics.authAttempts.Add(1, "err")
 				errCount++
 			} else if br.result == "" {
 				l.Debug("negative brute force result")
+				auditorMetrics.authAttempts.Add(1, "neg")
 				negCount++
 			} else {
 				l.Info("positive brute force result")
+				auditorMetrics.authAttempts.Add(1, "pos")
 				posCount++
 			}
+			previous := ""
+			if br.err == nil && len(a.notifiers) != 0 {
+				previous, err = a.store.getBruteResult(br)
+				if err != nil {
+					return res, err
+				}
+			}
 			err = a.store.updateBruteResult(br)
 			if err != nil {
 				return res, err
 			}
+			if br.anomalyChecked {
+				if br.anomaly != "" {
+					anomalous[br.hostport] = true
+					l.Warn("host anomaly", "anomaly", br.anomaly, "reason", br.anomalyReason)
+					err = a.store.setHostAnomaly(br.hostport, br.anomaly, br.anomalyReason)
+				} else {
+					delete(anomalous, br.hostport)
+					err = a.store.clearHostAnomaly(br.hostport, AnomalyAcceptsAnything, AnomalyHoneypot)
+				}
+				if err != nil {
+					return res, err
+				}
+			}
+			if br.err == nil && !anomalous[br.hostport] {
+				if br.result != "" && previous == "" {
+					events = append(events, findingEvent(br))
+				} else if br.result == "" && previous != "" {
+					events = append(events, remediationEvent(br, previous))
+				}
+			}
 			totalCount++
 		}
 		err = a.store.Commit()
 		if err != nil {
 			return res, errors.Wrap(err, "brute")
 		}
+		//Notify after the commit so events are only sent for stored
+		//results
+		for _, e := range events {
+			a.notify(e)
+		}
 	}
 	log.Info("brute force scan report", "total", totalCount, "neg", negCount, "pos", posCount, "err", errCount)
+	err = a.updateStoreMetrics()
+	if err != nil {
+		return res, err
+	}
 	return AuditResult{
 		totalCount: totalCount,
 		negCount:   negCount,
@@ -261,14 +487,18 @@ func (a *SSHAuditor) Rescan(cfg ScanConfiguration) (AuditResult, error) {
 }
 
 func (a *SSHAuditor) Dupes() (map[string][]Host, error) {
-	keyMap := make(map[string][]Host)
-
 	hosts, err := a.store.GetActiveHosts(2)
 
 	if err != nil {
-		return keyMap, errors.Wrap(err, "Dupes")
+		return make(map[string][]Host), errors.Wrap(err, "Dupes")
 	}
+	return duplicateKeys(hosts), nil
+}
 
+//duplicateKeys groups hosts by fingerprint, keeping the fingerprints used by
+//more than one host
+func duplicateKeys(hosts []Host) map[string][]Host {
+	keyMap := make(map[string][]Host)
 	for _, h := range hosts {
 		keyMap[h.Fingerprint] = append(keyMap[h.Fingerprint], h)
 	}
@@ -278,7 +508,7 @@ func (a *SSHAuditor) Dupes() (map[string][]Host, error) {
 			delete(keyMap, fp)
 		}
 	}
-	return keyMap, nil
+	return keyMap
 }
 func (a *SSHAuditor) getLogCheckScanQueue() ([]ScanRequest, error) {
 	var requests []ScanRequest
@@ -293,7 +523,7 @@ func (a *SSHAuditor) getLogCheckScanQueue() ([]ScanRequest, error) {
 			log.Warn("bad hostport? %s %s", h.Hostport, err)
 			continue
 		}
-		user := fmt.Sprintf("logcheck-%s", host)
+		user := logcheckUser(host)
 
 		sr := ScanRequest{
 			hostport:    h.Hostport,
@@ -311,7 +541,7 @@ func (a *SSHAuditor) Logcheck(cfg ScanConfiguration) error {
 		return err
 	}
 
-	bruteResults := bruteForcer(cfg.Concurrency, sc)
+	bruteResults := bruteForcer(cfg.Concurrency, sc, AuthOptions{})
 
 	for br := range bruteResults {
 		l := log.New("host", br.hostport, "user", br.cred.User)
@@ -350,7 +580,7 @@ func (a *SSHAuditor) LogcheckReport(ls LogSearcher) error {
 			log.Error("invalid hostport", "host", host.Hostport)
 			continue
 		}
-		fmt.Printf("%s %v\n", host.Hostport, logPresent[ip])
+		fmt.Printf("%s %v\n", host.Hostport, logPresent[stripZone(ip)])
 	}
 	return nil
 }
@@ -359,6 +589,38 @@ func (a *SSHAuditor) Vulnerabilities() ([]Vulnerability, error) {
 	return a.store.GetVulnerabilities()
 }
 
+//RedactVulnerabilities returns a copy of vulns with every password replaced
+//by RedactSecret
+func RedactVulnerabilities(vulns []Vulnerability) []Vulnerability {
+	redacted := make([]Vulnerability, len(vulns))
+	for i, v := range vulns {
+		v.HostCredential.Password = RedactSecret(v.HostCredential.Password)
+		redacted[i] = v
+	}
+	return redacted
+}
+
+//FilterVulnerabilitiesBySeverity returns the vulnerabilities that are at
+//least as severe as minSeverity
+func FilterVulnerabilitiesBySeverity(vulns []Vulnerability, minSeverity string) []Vulnerability {
+	var filtered []Vulnerability
+	for _, v := range vulns {
+		if SeverityRank(v.Evidence.Severity) <= SeverityRank(minSeverity) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered
+}
+
+//Redacted returns a copy of the report that does not contain any passwords
+func (r AuditReport) Redacted() AuditReport {
+	r.Vulnerabilities = RedactVulnerabilities(r.Vulnerabilities)
+	return r
+}
+
+//reportChangeDays is how far back the report lists host changes
+const reportChangeDays = 14
+
 func (a *SSHAuditor) GetReport() (AuditReport, error) {
 	var rep AuditReport
 	hosts, err := a.store.GetActiveHosts(2)
@@ -382,5 +644,69 @@ func (a *SSHAuditor) GetReport() (AuditReport, error) {
 	rep.Vulnerabilities = vulns
 	rep.VulnerabilitiesCount = len(vulns)
 
+	anomalous, err := a.store.GetAnomalousHosts()
+	if err != nil {
+		return rep, err
+	}
+	rep.AnomalousHosts = anomalous
+	rep.AnomalousHostsCount = len(anomalous)
+
+	findings, err := a.store.GetInventoryFindings()
+	if err != nil {
+		return rep, err
+	}
+	rep.InventoryFindings = findings
+	rep.InventoryFindingsCount = len(findings)
+
+	changes, err := a.store.GetHostChanges(reportChangeDays)
+	if err != nil {
+		return rep, err
+	}
+	rep.HostChanges = changes
+	rep.HostChangesCount = len(changes)
+
+	rep.WeakCrypto = WeakCrypto(hosts)
+	rep.WeakCryptoCount = len(rep.WeakCrypto)
+
+	all, err := a.store.GetAllMetadata()
+	if err != nil {
+		return rep, err
+	}
+	rep.attachMetadata(newMetadataIndex(all))
+
 	return rep, nil
 }
+
+//attachMetadata fills in the owner, department, tags and notes of every host
+//in the report so each finding shows who to contact about it
+func (r *AuditReport) attachMetadata(idx metadataIndex) {
+	idx.attach(r.ActiveHosts)
+	idx.attach(r.AnomalousHosts)
+	for _, hosts := range r.DuplicateKeys {
+		idx.attach(hosts)
+	}
+	for i := range r.Vulnerabilities {
+		r.Vulnerabilities[i].Host.Metadata = idx.lookup(r.Vulnerabilities[i].Host)
+	}
+	for i := range r.WeakCrypto {
+		r.WeakCrypto[i].Host.Metadata = idx.lookup(r.WeakCrypto[i].Host)
+	}
+	//Findings and changes only have a hostport, use the active host to
+	//also match on its hostname
+	known := make(map[string]Host)
+	for _, h := range r.ActiveHosts {
+		known[h.Hostport] = h
+	}
+	host := func(hostport string) Host {
+		if h, ok := known[hostport]; ok {
+			return h
+		}
+		return Host{Hostport: hostport}
+	}
+	for i := range r.InventoryFindings {
+		r.InventoryFindings[i].Metadata = idx.lookup(host(r.InventoryFindings[i].Hostport))
+	}
+	for i := range r.HostChanges {
+		r.HostChanges[i].Metadata = idx.lookup(host(r.HostChanges[i].Hostport))
+	}
+}
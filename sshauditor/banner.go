@@ -7,9 +7,13 @@ import (
 )
 
 type ScanResult struct {
-	hostport string
-	success  bool
-	banner   string
+	hostport    string
+	success     bool
+	banner      string
+	bannerDelay time.Duration
+	timedOut    bool
+	//imported results come from another scanner and may not have a banner
+	imported bool
 }
 
 func ScanPort(hostport string) ScanResult {
@@ -21,8 +25,13 @@ func ScanPort(hostport string) ScanResult {
 	}
 	defer conn.Close()
 	bannerBuffer := make([]byte, 256)
-	conn.SetDeadline(time.Now().Add(4 * time.Second))
+	start := time.Now()
+	conn.SetDeadline(start.Add(4 * time.Second))
 	n, err := conn.Read(bannerBuffer)
+	res.bannerDelay = time.Since(start)
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		res.timedOut = true
+	}
 	if err == nil {
 		banner = string(bannerBuffer[:n])
 
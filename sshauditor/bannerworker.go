@@ -1,10 +1,16 @@
 package sshauditor
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 func bannerWorker(jobs <-chan string, results chan<- ScanResult) {
 	for host := range jobs {
-		results <- ScanPort(host)
+		start := time.Now()
+		res := ScanPort(host)
+		observeProbe(probeBanner, start)
+		results <- res
 	}
 }
 
@@ -12,23 +12,35 @@ type BruteForceResult struct {
 	cred     Credential
 	err      error
 	result   string
+	evidence Evidence
+	//anomalyChecked is set on the first positive result for a host when
+	//any anomaly checks are enabled.  anomaly is the result of those checks.
+	anomalyChecked bool
+	anomaly        string
+	anomalyReason  string
 }
 
-func bruteworker(jobs <-chan ScanRequest, results chan<- BruteForceResult) {
+func bruteworker(jobs <-chan ScanRequest, results chan<- BruteForceResult, opts AuthOptions) {
 	for sr := range jobs {
 		failures := 0
+		anomalyChecked := false
 		for _, cred := range sr.credentials {
 			//TODO: make this configurable
 			//After 5 connection errors, stop trying this host for this run
 			if failures > 5 {
 				continue
 			}
-			result, err := SSHAuthAttempt(sr.hostport, cred.User, cred.Password)
+			result, evidence, err := SSHCredentialAuthAttempt(sr.hostport, cred, opts)
 			res := BruteForceResult{
 				hostport: sr.hostport,
 				cred:     cred,
 				result:   result,
 				err:      err,
+				evidence: evidence,
+			}
+			if (opts.AcceptsAnythingCheck || opts.HoneypotCheck) && result != "" && !anomalyChecked {
+				anomalyChecked = true
+				res.anomaly, res.anomalyReason, res.anomalyChecked = checkAnomalies(sr.hostport, cred, evidence, opts)
 			}
 			results <- res
 			if err != nil {
@@ -38,7 +50,7 @@ func bruteworker(jobs <-chan ScanRequest, results chan<- BruteForceResult) {
 	}
 }
 
-func bruteForcer(numWorkers int, requests []ScanRequest) chan BruteForceResult {
+func bruteForcer(numWorkers int, requests []ScanRequest, opts AuthOptions) chan BruteForceResult {
 	var wg sync.WaitGroup
 
 	requestChan := make(chan ScanRequest, numWorkers)
@@ -49,11 +61,14 @@ func bruteForcer(numWorkers int, requests []ScanRequest) chan BruteForceResult {
 		close(requestChan)
 	}()
 	results := make(chan BruteForceResult, 1000)
+	if opts.HoneypotCheck {
+		opts.unames = newUnameCache()
+	}
 
 	for w := 0; w <= numWorkers; w++ {
 		wg.Add(1)
 		go func() {
-			bruteworker(requestChan, results)
+			bruteworker(requestChan, results, opts)
 			wg.Done()
 		}()
 	}
@@ -0,0 +1,117 @@
+package sshauditor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"golang.org/x/crypto/ssh"
+)
+
+//Tunnel verification modes
+const (
+	//TunnelVerifyDial counts a tunnel as working if a connection back to the
+	//host's own ssh port or to localhost can be opened
+	TunnelVerifyDial = "dial"
+	//TunnelVerifyCanary counts a tunnel as working only if a nonce makes the
+	//round trip through a canary listener we control
+	TunnelVerifyCanary = "canary"
+)
+
+//canaryGreeting starts every line sent to a canary listener
+const canaryGreeting = "SSH-AUDITOR-CANARY"
+
+//CanaryTimeout is how long to wait for a canary to echo the nonce
+var CanaryTimeout = 5 * time.Second
+
+//ServeCanary accepts connections on l and echoes back a single canary line
+//on each.  Anything else is dropped.  It returns when l is closed.
+func ServeCanary(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go handleCanary(conn)
+	}
+}
+
+func handleCanary(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(CanaryTimeout))
+	line, err := bufio.NewReader(io.LimitReader(conn, 256)).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, canaryGreeting+" ") {
+		return
+	}
+	log.Debug("canary connection", "remote", conn.RemoteAddr(), "line", strings.TrimSpace(line))
+	conn.Write([]byte(line))
+}
+
+//verifyCanary sends a nonce to dest through client and checks that it comes
+//back
+func verifyCanary(client *ssh.Client, dest string) error {
+	conn, err := client.Dial("tcp", dest)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	line := fmt.Sprintf("%s %s\n", canaryGreeting, randomString(16))
+	done := make(chan error, 1)
+	go func() {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			done <- err
+			return
+		}
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			done <- err
+			return
+		}
+		if reply != line {
+			done <- fmt.Errorf("canary replied with %q", strings.TrimSpace(reply))
+			return
+		}
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(CanaryTimeout):
+		return fmt.Errorf("timed out waiting for canary")
+	}
+}
+
+//SSHCanaryAttempt returns the canaries that can be reached through client
+func SSHCanaryAttempt(client *ssh.Client, hostport string, canaries []string) []string {
+	var reachable []string
+	for _, dest := range canaries {
+		err := verifyCanary(client, dest)
+		if err != nil {
+			log.Debug("canary not reachable", "host", hostport, "canary", dest, "error", err)
+			continue
+		}
+		reachable = append(reachable, dest)
+	}
+	return reachable
+}
+
+//tunnelAttempt checks whether client can be used as a tunnel using the
+//verification mode in opts.  It returns the destinations that worked.
+//When exec already worked the tunnel is only checked as a capability and a
+//failure is expected, so it is only logged at debug level.
+func tunnelAttempt(client *ssh.Client, hostport string, opts AuthOptions, execSuccess bool) (string, bool) {
+	if opts.TunnelVerification != TunnelVerifyCanary {
+		logFailure := log.Error
+		if execSuccess {
+			logFailure = log.Debug
+		}
+		return sshDialAttempt(client, hostport, logFailure)
+	}
+	reachable := SSHCanaryAttempt(client, hostport, opts.TunnelCanaries)
+	return strings.Join(reachable, ","), len(reachable) > 0
+}
@@ -0,0 +1,143 @@
+package sshauditor
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//testSSHServer starts an ssh server that accepts the password "test" and
+//forwards direct-tcpip channels when forward is true.  Sessions can request a
+//pty, a shell and the sftp subsystem, every other session request fails.
+func testSSHServer(t *testing.T, forward bool) net.Listener {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) == "test" {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+	return listen(t, func(c net.Conn) {
+		_, chans, reqs, err := ssh.NewServerConn(c, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+		for nc := range chans {
+			if nc.ChannelType() == "session" {
+				ch, creqs, err := nc.Accept()
+				if err != nil {
+					continue
+				}
+				go testSession(ch, creqs)
+				continue
+			}
+			if nc.ChannelType() != "direct-tcpip" || !forward {
+				nc.Reject(ssh.Prohibited, "no")
+				continue
+			}
+			var dest struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			if err := ssh.Unmarshal(nc.ExtraData(), &dest); err != nil {
+				nc.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			remote, err := net.Dial("tcp", net.JoinHostPort(dest.Host, fmt.Sprint(dest.Port)))
+			if err != nil {
+				nc.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			ch, creqs, err := nc.Accept()
+			if err != nil {
+				remote.Close()
+				continue
+			}
+			go ssh.DiscardRequests(creqs)
+			go func() {
+				io.Copy(ch, remote)
+				ch.Close()
+			}()
+			go func() {
+				io.Copy(remote, ch)
+				remote.Close()
+			}()
+		}
+	})
+}
+
+//testSession replies to the requests on a testSSHServer session
+func testSession(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	for req := range reqs {
+		ok := false
+		switch req.Type {
+		case "pty-req", "shell":
+			ok = true
+		case "subsystem":
+			var subsystem struct{ Name string }
+			ok = ssh.Unmarshal(req.Payload, &subsystem) == nil && subsystem.Name == "sftp"
+		}
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+}
+
+func TestCanaryTunnel(t *testing.T) {
+	canary, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer canary.Close()
+	go ServeCanary(canary)
+
+	//Something that accepts connections but isn't a canary
+	other := listen(t, func(c net.Conn) {
+		c.Write([]byte("SSH-2.0-OpenSSH_7.4\r\n"))
+		c.Close()
+	})
+	defer other.Close()
+
+	opts := AuthOptions{
+		TunnelVerification: TunnelVerifyCanary,
+		TunnelCanaries:     []string{canary.Addr().String(), other.Addr().String()},
+	}
+	cred := Credential{User: "test", Password: "test"}
+
+	for _, forward := range []bool{true, false} {
+		server := testSSHServer(t, forward)
+		defer server.Close()
+		result, ev, err := SSHCredentialAuthAttempt(server.Addr().String(), cred, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forward {
+			if result != "tunnel" || ev.TunnelDestination != canary.Addr().String() {
+				t.Errorf("forwarding server: got result %q destination %q, expected tunnel to %s", result, ev.TunnelDestination, canary.Addr())
+			}
+		} else {
+			if result != "auth" || ev.TunnelDestination != "" {
+				t.Errorf("non forwarding server: got result %q destination %q, expected auth", result, ev.TunnelDestination)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package sshauditor
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//Capabilities that a successful login can have.  A restricted account that
+//can only use sftp is a very different risk from one with a full shell.
+const (
+	CapabilityExec          = "exec"
+	CapabilityShell         = "shell"
+	CapabilityTunnel        = "tunnel"
+	CapabilitySFTP          = "sftp"
+	CapabilityRemoteForward = "remote-forward"
+	CapabilityAgentForward  = "agent-forward"
+	CapabilityX11           = "x11"
+)
+
+//HasCapability returns true if capability was found during the login
+func (ev Evidence) HasCapability(capability string) bool {
+	for _, c := range ev.CapabilityList() {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+//CapabilityList returns the capabilities found during the login
+func (ev Evidence) CapabilityList() []string {
+	if ev.Capabilities == "" {
+		return nil
+	}
+	return strings.Split(ev.Capabilities, ",")
+}
+
+//probeCapabilities checks what the account can do besides what was already
+//determined by the exec and tunnel attempts.  The sftp, forwarding, agent and
+//x11 probes only make requests and close them again.  The shell probe does
+//start a login shell, see probeShell.
+func probeCapabilities(client *ssh.Client, exec, tunnel bool) []string {
+	var caps []string
+	if exec {
+		caps = append(caps, CapabilityExec)
+	} else if probeShell(client) {
+		caps = append(caps, CapabilityShell)
+	}
+	if tunnel {
+		caps = append(caps, CapabilityTunnel)
+	}
+	if probeSFTP(client) {
+		caps = append(caps, CapabilitySFTP)
+	}
+	if probeRemoteForward(client) {
+		caps = append(caps, CapabilityRemoteForward)
+	}
+	if probeSessionRequest(client, "auth-agent-req@openssh.com", nil) {
+		caps = append(caps, CapabilityAgentForward)
+	}
+	x11 := ssh.Marshal(struct {
+		SingleConnection bool
+		AuthProtocol     string
+		AuthCookie       string
+		ScreenNumber     uint32
+	}{
+		AuthProtocol: "MIT-MAGIC-COOKIE-1",
+		AuthCookie:   randomString(16),
+	})
+	if probeSessionRequest(client, "x11-req", x11) {
+		caps = append(caps, CapabilityX11)
+	}
+	return caps
+}
+
+//probeShell returns true if an interactive shell can be started.  This is
+//only checked when exec fails, since some servers only block exec.  The
+//server starts a real login shell on a pty, which runs the account's shell
+//startup files and is recorded in utmp, wtmp and lastlog, before the session
+//is closed again.
+func probeShell(client *ssh.Client) bool {
+	session, err := client.NewSession()
+	if err != nil {
+		return false
+	}
+	defer session.Close()
+	err = session.RequestPty("xterm", 24, 80, ssh.TerminalModes{ssh.ECHO: 0})
+	if err != nil {
+		return false
+	}
+	return session.Shell() == nil
+}
+
+//probeSFTP returns true if the sftp subsystem can be started
+func probeSFTP(client *ssh.Client) bool {
+	session, err := client.NewSession()
+	if err != nil {
+		return false
+	}
+	defer session.Close()
+	return session.RequestSubsystem("sftp") == nil
+}
+
+//probeRemoteForward returns true if the server agrees to listen on a port
+//and forward connections back to us.  The listener is closed immediately.
+func probeRemoteForward(client *ssh.Client) bool {
+	l, err := client.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+}
+
+//probeSessionRequest returns true if the server accepts a session request
+func probeSessionRequest(client *ssh.Client, name string, payload []byte) bool {
+	session, err := client.NewSession()
+	if err != nil {
+		return false
+	}
+	defer session.Close()
+	ok, err := session.SendRequest(name, true, payload)
+	return err == nil && ok
+}
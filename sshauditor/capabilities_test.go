@@ -0,0 +1,36 @@
+package sshauditor
+
+import (
+	"testing"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	opts := AuthOptions{
+		TunnelVerification: TunnelVerifyDial,
+		CapabilityProbes:   true,
+	}
+	cred := Credential{User: "test", Password: "test"}
+
+	tests := []struct {
+		forward      bool
+		result       string
+		capabilities string
+	}{
+		{true, "tunnel", "shell,tunnel,sftp"},
+		{false, "auth", "shell,sftp"},
+	}
+	for _, tt := range tests {
+		server := testSSHServer(t, tt.forward)
+		defer server.Close()
+		result, ev, err := SSHCredentialAuthAttempt(server.Addr().String(), cred, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != tt.result || ev.Capabilities != tt.capabilities {
+			t.Errorf("forward=%v: got result %q capabilities %q, expected %q %q", tt.forward, result, ev.Capabilities, tt.result, tt.capabilities)
+		}
+		if !ev.HasCapability(CapabilityShell) || ev.HasCapability(CapabilityExec) {
+			t.Errorf("forward=%v: expected shell but not exec in %v", tt.forward, ev.CapabilityList())
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package sshauditor
+
+//Classifications of a change to a host seen during discovery
+const (
+	//ChangeVersionUpgrade is a new server version with the same host key
+	ChangeVersionUpgrade = "version upgrade"
+	//ChangeReinstall is a new host key together with a new server version,
+	//which usually means the machine was reinstalled
+	ChangeReinstall = "likely reinstall"
+	//ChangeSuspicious is a new host key with the same server version.
+	//Nothing about the software changed, so why did the key?
+	ChangeSuspicious = "suspicious"
+)
+
+//HostChange is a change to the fingerprint or version of a host between two
+//discoveries
+type HostChange struct {
+	Time           string
+	Hostport       string
+	OldFingerprint string
+	NewFingerprint string
+	OldVersion     string
+	NewVersion     string
+	Classification string
+	Metadata       Metadata `db:"-"`
+}
+
+//ClassifyHostChange classifies a change to a host.  A fingerprint appearing
+//where there wasn't one before, like after a failed key fetch, is not a key
+//change.  It returns "" if nothing changed.
+func ClassifyHostChange(oldFP, newFP, oldVersion, newVersion string) string {
+	keyChanged := oldFP != "" && newFP != "" && oldFP != newFP
+	versionChanged := oldVersion != newVersion
+	switch {
+	case keyChanged && versionChanged:
+		return ChangeReinstall
+	case keyChanged:
+		return ChangeSuspicious
+	case versionChanged:
+		return ChangeVersionUpgrade
+	}
+	return ""
+}
+
+//classify fills in the Classification of c
+func (c *HostChange) classify() {
+	c.Classification = ClassifyHostChange(c.OldFingerprint, c.NewFingerprint, c.OldVersion, c.NewVersion)
+}
+
+//FilterHostChanges returns the changes with the given classification
+func FilterHostChanges(changes []HostChange, classification string) []HostChange {
+	var filtered []HostChange
+	for _, c := range changes {
+		if c.Classification == classification {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,96 @@
+package sshauditor
+
+import "testing"
+
+func TestClassifyHostChange(t *testing.T) {
+	for _, tt := range []struct {
+		oldFP, newFP, oldVersion, newVersion string
+		expected                             string
+	}{
+		{"a", "a", "SSH-2.0-OpenSSH_7.4", "SSH-2.0-OpenSSH_8.0", ChangeVersionUpgrade},
+		{"a", "b", "SSH-2.0-OpenSSH_7.4", "SSH-2.0-OpenSSH_8.0", ChangeReinstall},
+		{"a", "b", "SSH-2.0-OpenSSH_7.4", "SSH-2.0-OpenSSH_7.4", ChangeSuspicious},
+		{"", "b", "SSH-2.0-OpenSSH_7.4", "SSH-2.0-OpenSSH_7.4", ""},
+		{"a", "a", "SSH-2.0-OpenSSH_7.4", "SSH-2.0-OpenSSH_7.4", ""},
+	} {
+		c := ClassifyHostChange(tt.oldFP, tt.newFP, tt.oldVersion, tt.newVersion)
+		if c != tt.expected {
+			t.Errorf("ClassifyHostChange(%q, %q, %q, %q) => %q, want %q", tt.oldFP, tt.newFP, tt.oldVersion, tt.newVersion, c, tt.expected)
+		}
+	}
+}
+
+func TestGetHostChanges(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	check(s.Init())
+	discover := func(hosts ...SSHHost) {
+		c := make(chan SSHHost, len(hosts))
+		for _, h := range hosts {
+			c <- h
+		}
+		close(c)
+		check(New(s).updateStoreFromDiscovery(c, ScanConfiguration{}, nil))
+	}
+	discover(
+		SSHHost{hostport: "192.0.2.1:22", keyfp: "a", version: "SSH-2.0-OpenSSH_7.4"},
+		SSHHost{hostport: "192.0.2.2:22", keyfp: "b", version: "SSH-2.0-OpenSSH_7.4"},
+		SSHHost{hostport: "192.0.2.3:22", keyfp: "c", version: "SSH-2.0-OpenSSH_7.4"},
+	)
+	discover(
+		SSHHost{hostport: "192.0.2.1:22", keyfp: "a", version: "SSH-2.0-OpenSSH_8.0"},
+		SSHHost{hostport: "192.0.2.2:22", keyfp: "b2", version: "SSH-2.0-OpenSSH_8.0"},
+		SSHHost{hostport: "192.0.2.3:22", keyfp: "c2", version: "SSH-2.0-OpenSSH_7.4"},
+	)
+	changes, err := s.GetHostChanges(1)
+	check(err)
+	classes := make(map[string]string)
+	for _, c := range changes {
+		classes[c.Hostport] = c.Classification
+	}
+	expected := map[string]string{
+		"192.0.2.1:22": ChangeVersionUpgrade,
+		"192.0.2.2:22": ChangeReinstall,
+		"192.0.2.3:22": ChangeSuspicious,
+	}
+	if len(changes) != 3 || classes["192.0.2.1:22"] != expected["192.0.2.1:22"] ||
+		classes["192.0.2.2:22"] != expected["192.0.2.2:22"] || classes["192.0.2.3:22"] != expected["192.0.2.3:22"] {
+		t.Errorf("GetHostChanges => %#v, want classes %v", changes, expected)
+	}
+	suspicious := FilterHostChanges(changes, ChangeSuspicious)
+	if len(suspicious) != 1 || suspicious[0].OldFingerprint != "c" || suspicious[0].NewFingerprint != "c2" {
+		t.Errorf("FilterHostChanges => %#v", suspicious)
+	}
+}
+
+func TestGetHostChangesSecondBoundary(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	check(s.Init())
+	old := Host{Hostport: "192.0.2.1:22", Fingerprint: "a", Version: "SSH-2.0-OpenSSH_7.4"}
+	check(s.addHostChanges(SSHHost{hostport: old.Hostport, keyfp: "b", version: "SSH-2.0-OpenSSH_8.0"}, old))
+	//A reinstall whose rows ended up a second apart is still one change
+	_, err = s.Exec(`UPDATE host_changes SET time=datetime(time, '+1 second') WHERE type='version'`)
+	check(err)
+	//Rows from before change ids are combined by time
+	_, err = s.Exec(`INSERT INTO host_changes (time, hostport, type, old, new) VALUES
+		(datetime('now', 'localtime'), '192.0.2.2:22', 'fingerprint', 'c', 'd'),
+		(datetime('now', 'localtime'), '192.0.2.2:22', 'version', 'SSH-2.0-OpenSSH_7.4', 'SSH-2.0-OpenSSH_8.0')`)
+	check(err)
+
+	changes, err := s.GetHostChanges(1)
+	check(err)
+	if len(changes) != 2 || changes[0].Classification != ChangeReinstall || changes[1].Classification != ChangeReinstall {
+		t.Errorf("GetHostChanges => %#v, want two reinstalls", changes)
+	}
+}
@@ -0,0 +1,61 @@
+package sshauditor
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//MinRSABits is the smallest RSA host key that isn't reported as weak
+const MinRSABits = 2048
+
+//CryptoFinding is a host using an obsolete protocol version or a weak host
+//key
+type CryptoFinding struct {
+	Host  Host
+	Issue string
+}
+
+//weakCryptoIssues returns the weak crypto problems with h.  Only the host key
+//found during discovery is checked, so a server with several host keys may
+//have other weak ones.
+func weakCryptoIssues(h Host) []string {
+	var issues []string
+	//SSH-1.99 means the server speaks both protocol versions
+	if strings.HasPrefix(h.Version, "SSH-1.") {
+		issues = append(issues, "SSH-1 protocol ("+strings.SplitN(h.Version, "-", 3)[1]+")")
+	}
+	if h.HostKey == "" {
+		return issues
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(h.HostKey))
+	if err != nil {
+		return issues
+	}
+	switch key.Type() {
+	case ssh.KeyAlgoDSA:
+		issues = append(issues, "ssh-dss host key")
+	case ssh.KeyAlgoRSA:
+		ck, ok := key.(ssh.CryptoPublicKey)
+		if !ok {
+			break
+		}
+		if pub, ok := ck.CryptoPublicKey().(*rsa.PublicKey); ok && pub.N.BitLen() < MinRSABits {
+			issues = append(issues, fmt.Sprintf("%d bit RSA host key", pub.N.BitLen()))
+		}
+	}
+	return issues
+}
+
+//WeakCrypto returns a finding for each weak crypto problem on hosts
+func WeakCrypto(hosts []Host) []CryptoFinding {
+	var findings []CryptoFinding
+	for _, h := range hosts {
+		for _, issue := range weakCryptoIssues(h) {
+			findings = append(findings, CryptoFinding{Host: h, Issue: issue})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,274 @@
+package sshauditor
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ipRange is an inclusive range of addresses in one address family
+type ipRange struct {
+	v6          bool
+	first, last *big.Int
+}
+
+//size returns the number of addresses in r.  ok is false if it doesn't fit
+//in a uint64.
+func (r ipRange) size() (n uint64, ok bool) {
+	d := new(big.Int).Sub(r.last, r.first)
+	if !d.IsUint64() || d.Uint64() == math.MaxUint64 {
+		return 0, false
+	}
+	return d.Uint64() + 1, true
+}
+
+func ipToInt(ip net.IP) (*big.Int, bool) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return new(big.Int).SetBytes(ip4), false
+	}
+	return new(big.Int).SetBytes(ip.To16()), true
+}
+
+func intToIP(n *big.Int, v6 bool) net.IP {
+	size := net.IPv4len
+	if v6 {
+		size = net.IPv6len
+	}
+	b := n.Bytes()
+	ip := make(net.IP, size)
+	copy(ip[size-len(b):], b)
+	return ip
+}
+
+//parseRange returns the range of addresses in a CIDR or single address
+func parseRange(s string, minIPv6Prefix int) (ipRange, bool, error) {
+	if !strings.ContainsRune(s, '/') {
+		ip := net.ParseIP(trimBrackets(s))
+		if ip == nil {
+			return ipRange{}, false, nil
+		}
+		n, v6 := ipToInt(ip)
+		return ipRange{v6: v6, first: n, last: n}, true, nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return ipRange{}, false, err
+	}
+	if err := checkPrefixSize(ipnet, minIPv6Prefix); err != nil {
+		return ipRange{}, false, err
+	}
+	first, v6 := ipToInt(ipnet.IP)
+	ones, bits := ipnet.Mask.Size()
+	hostBits := new(big.Int).Lsh(big.NewInt(1), uint(bits-ones))
+	last := new(big.Int).Add(first, hostBits)
+	last.Sub(last, big.NewInt(1))
+	return ipRange{v6: v6, first: first, last: last}, true, nil
+}
+
+//sortRanges sorts ranges by family and then start address and merges
+//overlapping or adjacent ranges
+func sortRanges(ranges []ipRange) []ipRange {
+	sort.Slice(ranges, func(i, j int) bool {
+		if ranges[i].v6 != ranges[j].v6 {
+			return !ranges[i].v6
+		}
+		return ranges[i].first.Cmp(ranges[j].first) < 0
+	})
+	var merged []ipRange
+	one := big.NewInt(1)
+	for _, r := range ranges {
+		if len(merged) > 0 {
+			prev := &merged[len(merged)-1]
+			next := new(big.Int).Add(prev.last, one)
+			if prev.v6 == r.v6 && r.first.Cmp(next) <= 0 {
+				if r.last.Cmp(prev.last) > 0 {
+					prev.last = r.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+//subtractRanges returns the parts of include that are not in exclude.  Both
+//must be sorted and merged.
+func subtractRanges(include, exclude []ipRange) []ipRange {
+	var result []ipRange
+	one := big.NewInt(1)
+	for _, r := range include {
+		first := r.first
+		for _, e := range exclude {
+			if e.v6 != r.v6 || e.last.Cmp(first) < 0 {
+				continue
+			}
+			if e.first.Cmp(r.last) > 0 {
+				break
+			}
+			if e.first.Cmp(first) > 0 {
+				result = append(result, ipRange{v6: r.v6, first: first, last: new(big.Int).Sub(e.first, one)})
+			}
+			first = new(big.Int).Add(e.last, one)
+			if first.Cmp(r.last) > 0 {
+				break
+			}
+		}
+		if first.Cmp(r.last) <= 0 {
+			result = append(result, ipRange{v6: r.v6, first: first, last: r.last})
+		}
+	}
+	return result
+}
+
+//HostEnumerator streams the addresses in a set of networks, minus a set of
+//excluded networks, without building a list of them.  Excludes are
+//subtracted arithmetically so excluding a /8 costs nothing.
+type HostEnumerator struct {
+	//names are hostnames and zoned addresses, which are passed through
+	names  []string
+	ranges []ipRange
+	//offsets[i] is the index of the first address of ranges[i]
+	offsets []uint64
+	total   uint64
+}
+
+//NewHostEnumerator returns a HostEnumerator for the hosts in include that
+//are not in exclude
+func NewHostEnumerator(include, exclude []string, minIPv6Prefix int) (*HostEnumerator, error) {
+	if minIPv6Prefix < MinIPv6PrefixLimit || minIPv6Prefix > 128 {
+		return nil, fmt.Errorf("minimum IPv6 prefix %d is out of range, it has to be between %d and 128", minIPv6Prefix, MinIPv6PrefixLimit)
+	}
+	e := &HostEnumerator{}
+	excludeNames, err := parseAddressList(exclude)
+	if err != nil {
+		return nil, err
+	}
+	var excludeRanges []ipRange
+	for _, x := range exclude {
+		//Excludes can be any size
+		r, ok, err := parseRange(x, 0)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			excludeRanges = append(excludeRanges, r)
+		}
+	}
+	var includeRanges []ipRange
+	for _, netblock := range include {
+		r, ok, err := parseRange(netblock, minIPv6Prefix)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			includeRanges = append(includeRanges, r)
+			continue
+		}
+		host := trimBrackets(netblock)
+		if !excludeNames.contains(host) {
+			e.names = append(e.names, host)
+		}
+	}
+	e.ranges = subtractRanges(sortRanges(includeRanges), sortRanges(excludeRanges))
+	e.total = uint64(len(e.names))
+	for _, r := range e.ranges {
+		size, ok := r.size()
+		if !ok || e.total+size < e.total {
+			return nil, fmt.Errorf("too many hosts to enumerate, use longer prefixes")
+		}
+		e.offsets = append(e.offsets, e.total)
+		e.total += size
+	}
+	return e, nil
+}
+
+//Count returns the number of hosts that will be enumerated
+func (e *HostEnumerator) Count() uint64 {
+	return e.total
+}
+
+//host returns the i'th host
+func (e *HostEnumerator) host(i uint64) string {
+	if i < uint64(len(e.names)) {
+		return e.names[i]
+	}
+	r := sort.Search(len(e.offsets), func(j int) bool { return e.offsets[j] > i }) - 1
+	n := new(big.Int).SetUint64(i - e.offsets[r])
+	n.Add(n, e.ranges[r].first)
+	return intToIP(n, e.ranges[r].v6).String()
+}
+
+//Iterator returns an iterator over the hosts.  If randomize is true the
+//hosts are returned in a random order, so consecutive hosts are spread out
+//over all of the included networks.
+func (e *HostEnumerator) Iterator(randomize bool) *HostIterator {
+	it := &HostIterator{e: e, mask: 1<<64 - 1, a: 1, c: 1}
+	if randomize && e.total > 1 {
+		//A linear congruential generator x = a*x + c mod m has a full period
+		//when m is a power of 2, c is odd and a-1 is a multiple of 4.
+		//Values >= total are skipped.
+		m := uint64(1)
+		for m < e.total && m < 1<<63 {
+			m <<= 1
+		}
+		rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+		it.mask = m - 1
+		it.a = (rnd.Uint64()<<2 | 1) & it.mask
+		it.c = (rnd.Uint64() | 1) & it.mask
+		it.x = rnd.Uint64() & it.mask
+		it.steps = m
+		it.random = true
+	}
+	return it
+}
+
+//HostIterator returns the hosts of a HostEnumerator one at a time
+type HostIterator struct {
+	e *HostEnumerator
+	//next index when iterating in order
+	i uint64
+	//LCG state when randomizing
+	random     bool
+	mask, a, c uint64
+	x, steps   uint64
+}
+
+//Next returns the next host, or false when there are no more
+func (it *HostIterator) Next() (string, bool) {
+	if !it.random {
+		if it.i >= it.e.total {
+			return "", false
+		}
+		it.i++
+		return it.e.host(it.i - 1), true
+	}
+	for it.steps > 0 {
+		it.steps--
+		x := it.x
+		it.x = (it.a*it.x + it.c) & it.mask
+		if x < it.e.total {
+			return it.e.host(x), true
+		}
+	}
+	return "", false
+}
+
+//EnumerateHosts returns the hosts in netblocks that are not in exclude
+func EnumerateHosts(netblocks []string, exclude []string) ([]string, error) {
+	var hosts []string
+	e, err := NewHostEnumerator(netblocks, exclude, DefaultMinIPv6Prefix)
+	if err != nil {
+		return hosts, err
+	}
+	it := e.Iterator(false)
+	for h, ok := it.Next(); ok; h, ok = it.Next() {
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
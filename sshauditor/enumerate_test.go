@@ -0,0 +1,94 @@
+package sshauditor
+
+import (
+	"sort"
+	"testing"
+)
+
+func collectHosts(it *HostIterator) []string {
+	var hosts []string
+	for h, ok := it.Next(); ok; h, ok = it.Next() {
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+func TestHostEnumeratorLarge(t *testing.T) {
+	//Large enough that materializing it would be noticeable
+	e, err := NewHostEnumerator([]string{"10.0.0.0/8"}, []string{"10.0.0.0/9", "10.128.0.0/10", "10.192.0.0/11", "10.224.0.0/12"}, DefaultMinIPv6Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Count() != 1<<20 {
+		t.Errorf("Count() => %d, want %d", e.Count(), 1<<20)
+	}
+	it := e.Iterator(false)
+	if h, _ := it.Next(); h != "10.240.0.0" {
+		t.Errorf("first host => %s, want 10.240.0.0", h)
+	}
+}
+
+func TestHostEnumeratorIPv6Size(t *testing.T) {
+	e, err := NewHostEnumerator([]string{"2001:db8::/65"}, nil, MinIPv6PrefixLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Count() != 1<<63 {
+		t.Errorf("Count() => %d, want %d", e.Count(), uint64(1<<63))
+	}
+	var tests = []struct {
+		include   []string
+		minPrefix int
+	}{
+		{[]string{"2001:db8::/64"}, 64},
+		{[]string{"2001:db8::/120"}, 0},
+		{[]string{"2001:db8::/65", "2001:db8:0:0:8000::/65", "192.0.2.1"}, MinIPv6PrefixLimit},
+	}
+	for _, tt := range tests {
+		if _, err := NewHostEnumerator(tt.include, nil, tt.minPrefix); err == nil {
+			t.Errorf("NewHostEnumerator(%v, %d) succeeded", tt.include, tt.minPrefix)
+		}
+	}
+}
+
+func TestHostEnumeratorOverlap(t *testing.T) {
+	e, err := NewHostEnumerator([]string{"192.168.1.0/25", "192.168.1.0/24", "192.168.1.5", "example.com"}, []string{"192.168.1.10", "192.168.1.250/31"}, DefaultMinIPv6Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := collectHosts(e.Iterator(false))
+	if len(hosts) != 254 || uint64(len(hosts)) != e.Count() {
+		t.Errorf("got %d hosts, Count() %d, want 254", len(hosts), e.Count())
+	}
+	if hosts[0] != "example.com" || hosts[1] != "192.168.1.0" || hosts[len(hosts)-1] != "192.168.1.255" {
+		t.Errorf("unexpected order %v", hosts)
+	}
+}
+
+func TestHostEnumeratorRandomize(t *testing.T) {
+	include := []string{"192.168.1.0/24", "2001:db8::/120", "example.com"}
+	exclude := []string{"192.168.1.128/25"}
+	e, err := NewHostEnumerator(include, exclude, DefaultMinIPv6Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordered := collectHosts(e.Iterator(false))
+	random := collectHosts(e.Iterator(true))
+	if len(random) != len(ordered) || len(ordered) != 128+256+1 {
+		t.Fatalf("got %d random and %d ordered hosts, want %d", len(random), len(ordered), 128+256+1)
+	}
+	same := true
+	for i := range ordered {
+		same = same && ordered[i] == random[i]
+	}
+	if same {
+		t.Errorf("randomized order is the same as sequential")
+	}
+	sort.Strings(ordered)
+	sort.Strings(random)
+	for i := range ordered {
+		if ordered[i] != random[i] {
+			t.Fatalf("randomized hosts differ from sequential: %s != %s", random[i], ordered[i])
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package sshauditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+//FilterHostsBySubnet returns the hosts whose address is in one of subnets.
+//All hosts are returned if subnets is empty.
+func FilterHostsBySubnet(hosts []Host, subnets []string) ([]Host, error) {
+	if len(subnets) == 0 {
+		return hosts, nil
+	}
+	list, err := parseAddressList(subnets)
+	if err != nil {
+		return nil, errors.Wrap(err, "FilterHostsBySubnet")
+	}
+	var filtered []Host
+	for _, h := range hosts {
+		host, _, err := net.SplitHostPort(h.Hostport)
+		if err == nil && list.contains(host) {
+			filtered = append(filtered, h)
+		}
+	}
+	return filtered, nil
+}
+
+//WriteKnownHosts writes the host keys of hosts in OpenSSH known_hosts format.
+//Each line lists the address and the hostname the host was discovered
+//under.  If hashed is true the names are hashed like HashKnownHosts does.
+//Hosts discovered before full host keys were stored are skipped.
+func WriteKnownHosts(w io.Writer, hosts []Host, hashed bool) error {
+	for _, h := range hosts {
+		if h.HostKey == "" {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(h.HostKey))
+		if err != nil {
+			return errors.Wrapf(err, "WriteKnownHosts: %s", h.Hostport)
+		}
+		host, port, err := net.SplitHostPort(h.Hostport)
+		if err != nil {
+			continue
+		}
+		names := []string{knownHostsName(host, port)}
+		if h.Hostname != "" {
+			names = append(names, knownHostsName(h.Hostname, port))
+		}
+		if hashed {
+			for i, n := range names {
+				names[i] = knownhosts.HashHostname(n)
+			}
+		}
+		line := strings.Join(names, ",") + " " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return errors.Wrap(err, "WriteKnownHosts")
+		}
+	}
+	return nil
+}
+
+//knownHostsName returns host the way OpenSSH writes it in known_hosts.
+//knownhosts.Normalize brackets IPv6 addresses on port 22, which OpenSSH
+//doesn't match.
+func knownHostsName(host, port string) string {
+	if port == "22" {
+		return host
+	}
+	return "[" + host + "]:" + port
+}
+
+//WriteHostsCSV writes hosts as CSV with a header row
+func WriteHostsCSV(w io.Writer, hosts []Host) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"hostport", "address", "port", "hostname", "reverse_dns", "version", "fingerprint", "host_key", "seen_first", "seen_last"})
+	for _, h := range hosts {
+		host, port, _ := net.SplitHostPort(h.Hostport)
+		cw.Write([]string{h.Hostport, host, port, h.Hostname, h.ReverseDNS, h.Version, h.Fingerprint, h.HostKey, h.SeenFirst, h.SeenLast})
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "WriteHostsCSV")
+}
+
+//WriteAnsibleInventory writes hosts as an INI style Ansible inventory in
+//group.  Hosts are named by the hostname they were discovered under if there
+//is one, otherwise by address.  Hosts on a port other than 22 get the port
+//appended to their name, since the same machine can run more than one sshd.
+//Hosts that would still share a name, like the IPv4 and IPv6 address of a
+//dual stack host, get their address appended as well.  The colons in IPv6
+//addresses are replaced with dashes.
+func WriteAnsibleInventory(w io.Writer, hosts []Host, group string) error {
+	if _, err := fmt.Fprintf(w, "[%s]\n", group); err != nil {
+		return errors.Wrap(err, "WriteAnsibleInventory")
+	}
+	names := make([]string, len(hosts))
+	count := make(map[string]int)
+	for i, h := range hosts {
+		names[i] = ansibleName(h, false)
+		count[names[i]]++
+	}
+	for i, h := range hosts {
+		host, port, err := net.SplitHostPort(h.Hostport)
+		if err != nil {
+			continue
+		}
+		name := names[i]
+		if count[name] > 1 {
+			name = ansibleName(h, true)
+		}
+		_, err = fmt.Fprintf(w, "%s ansible_host=%s ansible_port=%s ssh_auditor_fingerprint=%s\n",
+			name, host, port, strconv.Quote(h.Fingerprint))
+		if err != nil {
+			return errors.Wrap(err, "WriteAnsibleInventory")
+		}
+	}
+	return nil
+}
+
+//ansibleName returns the inventory name of h, with the address appended to
+//the hostname if withAddress is true
+func ansibleName(h Host, withAddress bool) string {
+	host, port, err := net.SplitHostPort(h.Hostport)
+	if err != nil {
+		return h.Hostport
+	}
+	name := host
+	if h.Hostname != "" {
+		name = h.Hostname
+		if withAddress {
+			name = name + "_" + host
+		}
+	}
+	if port != "22" {
+		name = name + "_" + port
+	}
+	//Ansible reads a colon in a host name as the start of a port
+	return strings.Replace(name, ":", "-", -1)
+}
@@ -0,0 +1,93 @@
+package sshauditor
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const testHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIKeRoAlR8Vtle+MmB6ljoMeQVgGdl4PaIiRy/1Lh4Fks"
+
+var exportHosts = []Host{
+	{Hostport: "192.0.2.10:22", Hostname: "web.example.com", Fingerprint: "SHA256:x", HostKey: testHostKey},
+	{Hostport: "[2001:db8::1]:2222", Fingerprint: "SHA256:y", HostKey: testHostKey},
+	{Hostport: "192.0.2.11:22", Fingerprint: "SHA256:z"},
+}
+
+func TestWriteKnownHosts(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteKnownHosts(&buf, exportHosts, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "192.0.2.10,web.example.com " + testHostKey + "\n" +
+		"[2001:db8::1]:2222 " + testHostKey + "\n"
+	if buf.String() != expected {
+		t.Errorf("WriteKnownHosts =>\n%s\nwant\n%s", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := WriteKnownHosts(&buf, exportHosts, true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "192.0.2.10") || strings.Count(buf.String(), "|1|") != 3 {
+		t.Errorf("WriteKnownHosts hashed => %s", buf.String())
+	}
+
+	//The output should be usable by the knownhosts package
+	buf.Reset()
+	WriteKnownHosts(&buf, exportHosts, true)
+	if _, _, _, _, _, err := ssh.ParseKnownHosts(buf.Bytes()); err != nil {
+		t.Errorf("unable to parse output: %v", err)
+	}
+}
+
+func TestFilterHostsBySubnet(t *testing.T) {
+	hosts, err := FilterHostsBySubnet(exportHosts, []string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 2 || hosts[0].Hostport != "192.0.2.10:22" || hosts[1].Hostport != "192.0.2.11:22" {
+		t.Errorf("FilterHostsBySubnet => %#v", hosts)
+	}
+	hosts, _ = FilterHostsBySubnet(exportHosts, nil)
+	if len(hosts) != len(exportHosts) {
+		t.Errorf("FilterHostsBySubnet with no subnets should return every host")
+	}
+}
+
+func TestWriteAnsibleInventory(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteAnsibleInventory(&buf, exportHosts, "ssh"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[ssh]
+web.example.com ansible_host=192.0.2.10 ansible_port=22 ssh_auditor_fingerprint="SHA256:x"
+2001-db8--1_2222 ansible_host=2001:db8::1 ansible_port=2222 ssh_auditor_fingerprint="SHA256:y"
+192.0.2.11 ansible_host=192.0.2.11 ansible_port=22 ssh_auditor_fingerprint="SHA256:z"
+`
+	if buf.String() != expected {
+		t.Errorf("WriteAnsibleInventory =>\n%s\nwant\n%s", buf.String(), expected)
+	}
+}
+
+func TestWriteAnsibleInventoryDuplicateNames(t *testing.T) {
+	hosts := []Host{
+		{Hostport: "192.0.2.20:22", Hostname: "dual.example.com", Fingerprint: "SHA256:a"},
+		{Hostport: "[2001:db8::20]:22", Hostname: "dual.example.com", Fingerprint: "SHA256:a"},
+		{Hostport: "192.0.2.20:2222", Hostname: "dual.example.com", Fingerprint: "SHA256:b"},
+	}
+	var buf bytes.Buffer
+	if err := WriteAnsibleInventory(&buf, hosts, "ssh"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `[ssh]
+dual.example.com_192.0.2.20 ansible_host=192.0.2.20 ansible_port=22 ssh_auditor_fingerprint="SHA256:a"
+dual.example.com_2001-db8--20 ansible_host=2001:db8::20 ansible_port=22 ssh_auditor_fingerprint="SHA256:a"
+dual.example.com_2222 ansible_host=192.0.2.20 ansible_port=2222 ssh_auditor_fingerprint="SHA256:b"
+`
+	if buf.String() != expected {
+		t.Errorf("WriteAnsibleInventory =>\n%s\nwant\n%s", buf.String(), expected)
+	}
+}
@@ -0,0 +1,115 @@
+package sshauditor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Anomalies detected by the honeypot and tarpit heuristics
+const (
+	//AnomalyHoneypot is a host that looks like a Cowrie/Kippo style honeypot
+	AnomalyHoneypot = "honeypot"
+	//AnomalyTarpit is a host that holds connections open without sending an
+	//ssh banner, like endlessh
+	AnomalyTarpit = "tarpit"
+)
+
+//TarpitBannerDelay is how long a server can take to send its first line
+//before a non ssh banner is treated as a tarpit
+const TarpitBannerDelay = 2 * time.Second
+
+//DefaultHoneypotRules are the stock banners and fake system details of common
+//ssh honeypots.
+//
+//Matches on the hostkey and uname fields are enough on their own to tag a
+//host as a honeypot.  Matches on any other field are only a hint, since real
+//servers can send the same version string, and need a second hint.
+var DefaultHoneypotRules = []Rule{
+	{Name: "cowrie-version", Field: "version", Match: "regex", Pattern: `^SSH-2\.0-OpenSSH_6\.0p1 Debian-4\+deb7u2$`},
+	{Name: "kippo-version", Field: "version", Match: "regex", Pattern: `^SSH-2\.0-OpenSSH_5\.1p1 Debian-5$`},
+	{Name: "cowrie-uname", Field: "uname", Pattern: "Linux svr04 3.2.0-4-amd64"},
+	{Name: "kippo-uname", Field: "uname", Pattern: "Linux nas3 2.6.26-2-686"},
+}
+
+func init() {
+	for i := range DefaultHoneypotRules {
+		if err := DefaultHoneypotRules[i].compile(); err != nil {
+			panic(err)
+		}
+	}
+}
+
+//isStrongHoneypotRule returns true if a match on rule alone identifies a
+//honeypot
+func isStrongHoneypotRule(r Rule) bool {
+	return r.Field == "hostkey" || r.Field == "uname"
+}
+
+//detectHoneypot looks at the evidence from a successful login and returns
+//the reasons the host looks like a honeypot, and whether there are enough of
+//them to be sure.
+func detectHoneypot(cred Credential, ev Evidence, acceptsAnything bool, rules []Rule) ([]string, bool) {
+	var reasons []string
+	strong := false
+	for _, r := range rules {
+		if r.Matches(ev) {
+			reasons = append(reasons, "matched rule "+r.Name)
+			strong = strong || isStrongHoneypotRule(r)
+		}
+	}
+	//Honeypots commonly give every user a root shell
+	if uid, _, ok := parseIDOutput(ev.IDOutput); ok && uid == 0 && cred.User != "root" {
+		reasons = append(reasons, fmt.Sprintf("id reports uid 0 for user %s", cred.User))
+	}
+	if acceptsAnything {
+		reasons = append(reasons, "accepted a random user and password")
+	}
+	return reasons, strong || len(reasons) >= 2
+}
+
+//detectTarpit returns a reason if a banner scan result looks like a tarpit
+//that holds connections open instead of sending an ssh banner
+func detectTarpit(res ScanResult) (string, bool) {
+	if !res.success {
+		return "", false
+	}
+	if res.timedOut {
+		return "no banner received", true
+	}
+	if !strings.HasPrefix(res.banner, "SSH-") && res.bannerDelay > TarpitBannerDelay {
+		return fmt.Sprintf("non ssh banner after %s", res.bannerDelay.Round(time.Millisecond)), true
+	}
+	return "", false
+}
+
+//unameCache holds the uname output of each host, so it is only collected on
+//the first exec login to a host in a run instead of on every one
+type unameCache struct {
+	mu     sync.Mutex
+	unames map[string]string
+}
+
+func newUnameCache() *unameCache {
+	return &unameCache{unames: make(map[string]string)}
+}
+
+//get returns the cached uname output for hostport, calling uname to collect
+//it if there is none yet.  A nil cache always calls uname.
+func (c *unameCache) get(hostport string, uname func() string) string {
+	if c == nil {
+		return uname()
+	}
+	c.mu.Lock()
+	out, ok := c.unames[hostport]
+	c.mu.Unlock()
+	if ok {
+		return out
+	}
+	out = uname()
+	c.mu.Lock()
+	c.unames[hostport] = out
+	c.mu.Unlock()
+	return out
+}
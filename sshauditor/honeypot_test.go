@@ -0,0 +1,109 @@
+package sshauditor
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+var honeypotTestCases = []struct {
+	name     string
+	user     string
+	ev       Evidence
+	anything bool
+	expected bool
+}{
+	{"normal", "root", Evidence{Version: "SSH-2.0-OpenSSH_7.4", IDOutput: "uid=0(root) gid=0(root)"}, false, false},
+	{"old debian", "root", Evidence{Version: "SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2", IDOutput: "uid=0(root) gid=0(root)"}, false, false},
+	{"cowrie version and accepts anything", "root", Evidence{Version: "SSH-2.0-OpenSSH_6.0p1 Debian-4+deb7u2"}, true, true},
+	{"root for every user", "test", Evidence{IDOutput: "uid=0(root) gid=0(root)"}, true, true},
+	{"root for one user", "test", Evidence{IDOutput: "uid=0(root) gid=0(root)"}, false, false},
+	{"cowrie uname", "test", Evidence{Uname: "Linux svr04 3.2.0-4-amd64 #1 SMP Debian 3.2.68-1+deb7u1 x86_64 GNU/Linux"}, false, true},
+	{"accepts anything only", "test", Evidence{IDOutput: "uid=1000(test) gid=1000(test)"}, true, false},
+}
+
+func TestDetectHoneypot(t *testing.T) {
+	for _, tt := range honeypotTestCases {
+		reasons, honeypot := detectHoneypot(Credential{User: tt.user}, tt.ev, tt.anything, DefaultHoneypotRules)
+		if honeypot != tt.expected {
+			t.Errorf("detectHoneypot(%s) => %v %v, want %v", tt.name, honeypot, reasons, tt.expected)
+		}
+	}
+
+	hostKeyRules := []Rule{{Name: "lab-honeypot", Field: "hostkey", Pattern: "SHA256:honeypot"}}
+	if _, honeypot := detectHoneypot(Credential{User: "root"}, Evidence{HostKeyFingerprint: "SHA256:honeypot"}, false, hostKeyRules); !honeypot {
+		t.Errorf("detectHoneypot did not detect a known honeypot host key")
+	}
+	if _, ok := knownHoneypotKey(SSHHost{keyfp: "SHA256:honeypot"}, hostKeyRules); !ok {
+		t.Errorf("knownHoneypotKey did not detect a known honeypot host key")
+	}
+}
+
+//listen starts a listener that runs handle for every connection
+func listen(t *testing.T, handle func(net.Conn)) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return l
+}
+
+func TestDetectTarpit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+	ssh := listen(t, func(c net.Conn) {
+		c.Write([]byte("SSH-2.0-OpenSSH_7.4\r\n"))
+		c.Close()
+	})
+	defer ssh.Close()
+	tarpit := listen(t, func(c net.Conn) {
+		time.Sleep(6 * time.Second)
+		c.Close()
+	})
+	defer tarpit.Close()
+
+	res := ScanPort(ssh.Addr().String())
+	if reason, ok := detectTarpit(res); ok {
+		t.Errorf("ssh server detected as a tarpit: %s", reason)
+	}
+	res = ScanPort(tarpit.Addr().String())
+	if _, ok := detectTarpit(res); !ok {
+		t.Errorf("silent server not detected as a tarpit: %#v", res)
+	}
+	if _, ok := detectTarpit(ScanResult{success: true, banner: "x7Tq", bannerDelay: 3 * time.Second}); !ok {
+		t.Errorf("slow non ssh banner not detected as a tarpit")
+	}
+}
+
+func TestUnameCache(t *testing.T) {
+	calls := 0
+	uname := func() string {
+		calls++
+		return "Linux svr04 3.2.0-4-amd64"
+	}
+	c := newUnameCache()
+	for i := 0; i < 3; i++ {
+		if out := c.get("192.0.2.1:22", uname); out != "Linux svr04 3.2.0-4-amd64" {
+			t.Errorf("get => %q", out)
+		}
+	}
+	c.get("192.0.2.2:22", uname)
+	if calls != 2 {
+		t.Errorf("expected uname once per host, got %d calls", calls)
+	}
+	var none *unameCache
+	none.get("192.0.2.1:22", uname)
+	if calls != 3 {
+		t.Errorf("expected a nil cache to always call uname")
+	}
+}
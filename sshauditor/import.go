@@ -0,0 +1,206 @@
+package sshauditor
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Import is the list of open ssh ports found by another scanner, like nmap or
+//masscan.  They are fed straight to the fingerprint stage of discovery.
+type Import struct {
+	hosts []ScanResult
+	//names maps an address to the hostname the scanner was given
+	names map[string]string
+	seen  map[string]int
+}
+
+func newImport() Import {
+	return Import{
+		names: make(map[string]string),
+		seen:  make(map[string]int),
+	}
+}
+
+//Count returns the number of ssh ports that were imported
+func (imp Import) Count() int {
+	return len(imp.hosts)
+}
+
+//add records an open port.  Scanners can report the same port more than
+//once, for example masscan reports the open port and its banner separately.
+func (imp *Import) add(host string, port int, banner string) {
+	hostport := net.JoinHostPort(host, strconv.Itoa(port))
+	if i, ok := imp.seen[hostport]; ok {
+		if imp.hosts[i].banner == "" {
+			imp.hosts[i].banner = banner
+		}
+		return
+	}
+	imp.seen[hostport] = len(imp.hosts)
+	imp.hosts = append(imp.hosts, ScanResult{
+		hostport: hostport,
+		success:  true,
+		banner:   banner,
+		imported: true,
+	})
+}
+
+//isSSHPort decides if an open port is ssh.  Ports the scanner identified as
+//ssh are used whatever their number.  Ports without any service information
+//are only used if they are in ports.  Callers pass an empty service when the
+//scanner only guessed it from the port number.
+func isSSHPort(port int, service, banner string, ports []int) bool {
+	if service == "ssh" || strings.HasPrefix(banner, "SSH-") {
+		return true
+	}
+	if service != "" || banner != "" {
+		return false
+	}
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+type nmapRun struct {
+	Hosts []struct {
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name string `xml:"name,attr"`
+				//Method is table when nmap guessed the service from the
+				//port number instead of probing it (no -sV)
+				Method string `xml:"method,attr"`
+			} `xml:"service"`
+			Scripts []struct {
+				ID     string `xml:"id,attr"`
+				Output string `xml:"output,attr"`
+			} `xml:"script"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+//ParseNmapXML returns the open ssh ports in nmap XML output (nmap -oX).
+//The banner script output is used as the version when present.
+func ParseNmapXML(r io.Reader, ports []int) (Import, error) {
+	imp := newImport()
+	var run nmapRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return imp, errors.Wrap(err, "ParseNmapXML")
+	}
+	for _, h := range run.Hosts {
+		var addr string
+		for _, a := range h.Addresses {
+			if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+				addr = a.Addr
+				break
+			}
+		}
+		if addr == "" {
+			continue
+		}
+		for _, hn := range h.Hostnames {
+			if hn.Type == "user" {
+				imp.names[addr] = hn.Name
+			}
+		}
+		for _, p := range h.Ports {
+			if p.Protocol != "tcp" || p.State.State != "open" {
+				continue
+			}
+			var banner string
+			for _, s := range p.Scripts {
+				if s.ID == "banner" {
+					banner = strings.TrimSpace(s.Output)
+				}
+			}
+			service := p.Service.Name
+			if p.Service.Method == "table" {
+				service = ""
+			}
+			if isSSHPort(p.PortID, service, banner, ports) {
+				imp.add(addr, p.PortID, banner)
+			}
+		}
+	}
+	return imp, nil
+}
+
+type masscanRecord struct {
+	IP    string
+	Ports []struct {
+		Port    int
+		Proto   string
+		Status  string
+		Service struct {
+			Name   string
+			Banner string
+		}
+	}
+}
+
+//ParseMasscanJSON returns the open ssh ports in masscan JSON output
+//(masscan -oJ or -oD).  masscan writes one record per line, with commas and
+//brackets around them that don't always make valid JSON, so each line is
+//parsed on its own.
+func ParseMasscanJSON(r io.Reader, ports []int) (Import, error) {
+	imp := newImport()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.Trim(strings.TrimSpace(scanner.Text()), ",")
+		if line == "" || line == "[" || line == "]" {
+			continue
+		}
+		var rec masscanRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			//Older versions end the file with {finished: 1}
+			if strings.Contains(line, "finished") {
+				continue
+			}
+			return imp, errors.Wrap(err, fmt.Sprintf("ParseMasscanJSON: line %d", lineno))
+		}
+		for _, p := range rec.Ports {
+			if p.Proto != "tcp" || (p.Status != "" && p.Status != "open") {
+				continue
+			}
+			//A service name without a banner is no more than a guess
+			banner := strings.TrimSpace(p.Service.Banner)
+			service := p.Service.Name
+			if banner == "" {
+				service = ""
+			}
+			if isSSHPort(p.Port, service, banner, ports) {
+				imp.add(rec.IP, p.Port, banner)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return imp, errors.Wrap(err, "ParseMasscanJSON")
+	}
+	return imp, nil
+}
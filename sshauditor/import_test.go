@@ -0,0 +1,147 @@
+package sshauditor
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var nmapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -sV -oX - 192.0.2.0/30">
+<host><status state="up"/>
+<address addr="192.0.2.1" addrtype="ipv4"/>
+<address addr="52:54:00:12:34:56" addrtype="mac"/>
+<hostnames><hostname name="gw.example.com" type="user"/><hostname name="gw.example.com" type="PTR"/></hostnames>
+<ports>
+<port protocol="tcp" portid="22"><state state="open"/><service name="ssh" product="OpenSSH" version="7.4"/></port>
+<port protocol="tcp" portid="2222"><state state="open"/><service name="ssh"/><script id="banner" output="SSH-2.0-dropbear_2019.78"/></port>
+<port protocol="tcp" portid="80"><state state="open"/><service name="http"/></port>
+<port protocol="tcp" portid="2200"><state state="closed"/><service name="ssh"/></port>
+</ports>
+</host>
+<host><status state="up"/>
+<address addr="2001:db8::2" addrtype="ipv6"/>
+<ports>
+<port protocol="tcp" portid="22"><state state="open"/></port>
+<port protocol="tcp" portid="8022"><state state="open"/></port>
+</ports>
+</host>
+</nmaprun>
+`
+
+func TestParseNmapXML(t *testing.T) {
+	imp, err := ParseNmapXML(strings.NewReader(nmapXML), []int{22})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScanResult{
+		{hostport: "192.0.2.1:22", success: true, imported: true},
+		{hostport: "192.0.2.1:2222", success: true, imported: true, banner: "SSH-2.0-dropbear_2019.78"},
+		{hostport: "[2001:db8::2]:22", success: true, imported: true},
+	}
+	if !reflect.DeepEqual(imp.hosts, expected) {
+		t.Errorf("ParseNmapXML => %#v, want %#v", imp.hosts, expected)
+	}
+	if imp.names["192.0.2.1"] != "gw.example.com" {
+		t.Errorf("ParseNmapXML names => %v", imp.names)
+	}
+}
+
+//nmapTableXML is a scan without -sV, where every service is guessed from the
+//port number
+var nmapTableXML = `<?xml version="1.0" encoding="UTF-8"?>
+<nmaprun scanner="nmap" args="nmap -p 22,2222,3389 -oX - 192.0.2.5">
+<host><status state="up"/>
+<address addr="192.0.2.5" addrtype="ipv4"/>
+<ports>
+<port protocol="tcp" portid="22"><state state="open"/><service name="ssh" method="table" conf="3"/></port>
+<port protocol="tcp" portid="2222"><state state="open"/><service name="EtherNetIP-1" method="table" conf="3"/></port>
+<port protocol="tcp" portid="3389"><state state="open"/><service name="ms-wbt-server" method="table" conf="3"/></port>
+</ports>
+</host>
+</nmaprun>
+`
+
+func TestParseNmapXMLTableServices(t *testing.T) {
+	imp, err := ParseNmapXML(strings.NewReader(nmapTableXML), []int{22, 2222})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScanResult{
+		{hostport: "192.0.2.5:22", success: true, imported: true},
+		{hostport: "192.0.2.5:2222", success: true, imported: true},
+	}
+	if !reflect.DeepEqual(imp.hosts, expected) {
+		t.Errorf("ParseNmapXML => %#v, want %#v", imp.hosts, expected)
+	}
+}
+
+var masscanJSON = `[
+{   "ip": "192.0.2.1",   "timestamp": "1600000000", "ports": [ {"port": 2222, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] }
+,
+{   "ip": "192.0.2.1",   "timestamp": "1600000001", "ports": [ {"port": 2222, "proto": "tcp", "service": {"name": "ssh", "banner": "SSH-2.0-OpenSSH_7.4"} } ] }
+,
+{   "ip": "192.0.2.2",   "timestamp": "1600000000", "ports": [ {"port": 22, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] }
+,
+{   "ip": "192.0.2.3",   "timestamp": "1600000000", "ports": [ {"port": 80, "proto": "tcp", "status": "open", "reason": "syn-ack", "ttl": 64} ] }
+,
+{   "ip": "192.0.2.4",   "timestamp": "1600000000", "ports": [ {"port": 22, "proto": "tcp", "service": {"name": "unknown"} } ] }
+,
+{finished: 1}
+]
+`
+
+func TestParseMasscanJSON(t *testing.T) {
+	imp, err := ParseMasscanJSON(strings.NewReader(masscanJSON), []int{22})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ScanResult{
+		{hostport: "192.0.2.1:2222", success: true, imported: true, banner: "SSH-2.0-OpenSSH_7.4"},
+		{hostport: "192.0.2.2:22", success: true, imported: true},
+		{hostport: "192.0.2.4:22", success: true, imported: true},
+	}
+	if !reflect.DeepEqual(imp.hosts, expected) {
+		t.Errorf("ParseMasscanJSON => %#v, want %#v", imp.hosts, expected)
+	}
+	_, err = ParseMasscanJSON(strings.NewReader("{not json"), nil)
+	if err == nil {
+		t.Errorf("ParseMasscanJSON did not return an error for invalid input")
+	}
+}
+
+func TestDiscoverImport(t *testing.T) {
+	server := testSSHServer(t, false)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr().String())
+	portInt, _ := strconv.Atoi(port)
+
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	imp := newImport()
+	imp.add("127.0.0.1", portInt, "")
+	imp.names["127.0.0.1"] = "test.example.com"
+
+	err = New(store).DiscoverImport(imp, ScanConfiguration{Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := store.getKnownHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, ok := hosts[server.Addr().String()]
+	if !ok {
+		t.Fatalf("imported host was not stored: %#v", hosts)
+	}
+	if !strings.HasPrefix(h.Version, "SSH-2.0-") || h.Fingerprint == "" || !strings.HasPrefix(h.HostKey, "ssh-ed25519 ") || h.Hostname != "test.example.com" {
+		t.Errorf("imported host missing banner, fingerprint or name: %#v", h)
+	}
+}
@@ -0,0 +1,257 @@
+package sshauditor
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+//Inventory findings
+const (
+	//FindingHostKeyMismatch is a host whose key is not the one in the
+	//inventory
+	FindingHostKeyMismatch = "host key mismatch"
+	//FindingUnknownHost is an active host that isn't in the inventory
+	FindingUnknownHost = "unknown host"
+	//FindingUnexpectedKeyType is a host that offered a key of a type the
+	//inventory has no key of, like a new ed25519 key on a host pinned to
+	//ssh-rsa
+	FindingUnexpectedKeyType = "unexpected key type"
+)
+
+//ExpectedKey is a host key from an authoritative source like a managed
+//known_hosts file or a CMDB export
+type ExpectedKey struct {
+	//Name is the host as it appears in known_hosts, host for port 22 and
+	//[host]:port otherwise.  It may be hashed.
+	Name        string
+	KeyType     string `db:"key_type"`
+	Fingerprint string
+	Source      string
+}
+
+//InventoryFinding is a difference between the inventory and what discovery
+//found
+type InventoryFinding struct {
+	Hostport  string
+	Type      string
+	Expected  string
+	Observed  string
+	SeenFirst string   `db:"seen_first"`
+	SeenLast  string   `db:"seen_last"`
+	Metadata  Metadata `db:"-"`
+}
+
+//ParseKnownHostsKeys returns the keys in a known_hosts file.  Wildcard
+//patterns, @revoked and @cert-authority lines are skipped.
+func ParseKnownHostsKeys(r io.Reader, source string) ([]ExpectedKey, error) {
+	var keys []ExpectedKey
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "@") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("ParseKnownHostsKeys: line %d: missing key", lineno)
+		}
+		rest := strings.TrimSpace(line[len(fields[0]):])
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(rest))
+		if err != nil {
+			return nil, errors.Wrapf(err, "ParseKnownHostsKeys: line %d", lineno)
+		}
+		for _, name := range strings.Split(fields[0], ",") {
+			if strings.ContainsAny(name, "*?!") {
+				continue
+			}
+			keys = append(keys, ExpectedKey{
+				Name:        name,
+				KeyType:     key.Type(),
+				Fingerprint: ssh.FingerprintSHA256(key),
+				Source:      source,
+			})
+		}
+	}
+	return keys, errors.Wrap(scanner.Err(), "ParseKnownHostsKeys")
+}
+
+//ParseInventoryCSV returns the keys in a CMDB style CSV file.  The header
+//must have a host column and either a host_key column with the key in
+//authorized_keys format or a fingerprint column with its SHA256 fingerprint.
+//Optional port and key_type columns are also used.
+func ParseInventoryCSV(r io.Reader, source string) ([]ExpectedKey, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseInventoryCSV")
+	}
+	col := make(map[string]int)
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	_, hasHost := col["host"]
+	_, hasKey := col["host_key"]
+	_, hasFP := col["fingerprint"]
+	if !hasHost || !(hasKey || hasFP) {
+		return nil, errors.New("ParseInventoryCSV: header needs a host column and a host_key or fingerprint column")
+	}
+	var keys []ExpectedKey
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseInventoryCSV")
+		}
+		host := trimBrackets(get(rec, "host"))
+		if host == "" {
+			continue
+		}
+		port := get(rec, "port")
+		if port == "" {
+			port = "22"
+		}
+		k := ExpectedKey{
+			Name:        knownHostsName(host, port),
+			KeyType:     get(rec, "key_type"),
+			Fingerprint: get(rec, "fingerprint"),
+			Source:      source,
+		}
+		if hk := get(rec, "host_key"); hk != "" {
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hk))
+			if err != nil {
+				return nil, errors.Wrapf(err, "ParseInventoryCSV: %s", host)
+			}
+			k.KeyType = key.Type()
+			k.Fingerprint = ssh.FingerprintSHA256(key)
+		}
+		if k.Fingerprint == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+//matchHashedName returns true if hashed is a known_hosts |1|salt|hash entry
+//for name
+func matchHashedName(hashed, name string) bool {
+	parts := strings.Split(hashed, "|")
+	if len(parts) != 4 || parts[1] != "1" {
+		return false
+	}
+	salt, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	hash, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, salt)
+	mac.Write([]byte(name))
+	return hmac.Equal(mac.Sum(nil), hash)
+}
+
+//inventory is the set of expected host keys used during discovery
+type inventory struct {
+	byName map[string][]ExpectedKey
+	hashed []ExpectedKey
+}
+
+func newInventory(keys []ExpectedKey) inventory {
+	inv := inventory{byName: make(map[string][]ExpectedKey)}
+	for _, k := range keys {
+		if strings.HasPrefix(k.Name, "|") {
+			inv.hashed = append(inv.hashed, k)
+		} else {
+			inv.byName[k.Name] = append(inv.byName[k.Name], k)
+		}
+	}
+	return inv
+}
+
+func (inv inventory) empty() bool {
+	return len(inv.byName) == 0 && len(inv.hashed) == 0
+}
+
+//lookup returns the expected keys for any of names
+func (inv inventory) lookup(names []string) []ExpectedKey {
+	var keys []ExpectedKey
+	for _, n := range names {
+		keys = append(keys, inv.byName[n]...)
+		for _, k := range inv.hashed {
+			if matchHashedName(k.Name, n) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	return keys
+}
+
+//check compares the key of a discovered host against the inventory.  The
+//host is looked up by address, the name it was discovered under and its
+//reverse DNS names.  It returns the finding type and the expected
+//fingerprints, or an empty finding if the key matches or can't be compared.
+//A host that isn't in the inventory is unknown even if its key couldn't be
+//fetched.  A host is only compared against expected keys of the same type,
+//since a server with several host keys only offers one of them.  If the
+//inventory has no key of the offered type the host is reported with the keys
+//it does have, so the inventory should list every key type a host offers.
+func (inv inventory) check(h SSHHost) (finding string, expected string) {
+	host, port, err := net.SplitHostPort(h.hostport)
+	if err != nil {
+		return "", ""
+	}
+	names := []string{knownHostsName(host, port)}
+	for _, n := range (Host{Hostname: h.hostname, ReverseDNS: h.reverseDNS}).nameList() {
+		names = append(names, knownHostsName(n, port))
+	}
+	keys := inv.lookup(names)
+	if len(keys) == 0 {
+		return FindingUnknownHost, ""
+	}
+	if h.keyfp == "" {
+		return "", ""
+	}
+	var keyType string
+	if fields := strings.Fields(h.hostKey); len(fields) > 0 {
+		keyType = fields[0]
+	}
+	var fps, otherTypes []string
+	for _, k := range keys {
+		if k.KeyType != "" && keyType != "" && k.KeyType != keyType {
+			otherTypes = append(otherTypes, k.KeyType+" "+k.Fingerprint)
+			continue
+		}
+		if k.Fingerprint == h.keyfp {
+			return "", ""
+		}
+		fps = append(fps, k.Fingerprint)
+	}
+	if len(fps) == 0 {
+		return FindingUnexpectedKeyType, strings.Join(otherTypes, ",")
+	}
+	return FindingHostKeyMismatch, strings.Join(fps, ",")
+}
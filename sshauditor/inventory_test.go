@@ -0,0 +1,163 @@
+package sshauditor
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const otherHostKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"
+
+func fingerprint(t *testing.T, authorizedKey string) string {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ssh.FingerprintSHA256(key)
+}
+
+func TestParseKnownHostsKeys(t *testing.T) {
+	knownHosts := "# managed\n" +
+		"web.example.com,192.0.2.10 " + testHostKey + "\n" +
+		"*.example.com " + testHostKey + "\n" +
+		"@cert-authority *.example.com " + testHostKey + "\n" +
+		knownhosts.HashHostname("[192.0.2.11]:2222") + " " + otherHostKey + "\n"
+	keys, err := ParseKnownHostsKeys(strings.NewReader(knownHosts), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expected 3 keys, got %#v", keys)
+	}
+	if keys[0].Name != "web.example.com" || keys[1].Name != "192.0.2.10" || keys[0].KeyType != "ssh-ed25519" || keys[0].Fingerprint != fingerprint(t, testHostKey) {
+		t.Errorf("unexpected keys %#v", keys)
+	}
+	if !matchHashedName(keys[2].Name, "[192.0.2.11]:2222") || matchHashedName(keys[2].Name, "192.0.2.11") {
+		t.Errorf("hashed name did not match")
+	}
+}
+
+func TestParseInventoryCSV(t *testing.T) {
+	csv := "Host,Port,Owner,Fingerprint,Host_Key\n" +
+		"192.0.2.10,,ops," + fingerprint(t, testHostKey) + ",\n" +
+		"2001:db8::1,2222,ops,,\"" + otherHostKey + "\"\n" +
+		"192.0.2.12,22,ops,,\n"
+	keys, err := ParseInventoryCSV(strings.NewReader(csv), "cmdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("expected 2 keys, got %#v", keys)
+	}
+	if keys[0].Name != "192.0.2.10" || keys[0].KeyType != "" {
+		t.Errorf("unexpected key %#v", keys[0])
+	}
+	if keys[1].Name != "[2001:db8::1]:2222" || keys[1].KeyType != "ssh-ed25519" || keys[1].Fingerprint != fingerprint(t, otherHostKey) {
+		t.Errorf("unexpected key %#v", keys[1])
+	}
+	if _, err := ParseInventoryCSV(strings.NewReader("name,owner\n"), "cmdb"); err == nil {
+		t.Errorf("expected an error for a CSV without host and key columns")
+	}
+}
+
+func TestInventoryDiscovery(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	check(s.Init())
+	knownHosts := "192.0.2.10 " + testHostKey + "\n" +
+		"web.example.com " + testHostKey + "\n"
+	keys, err := ParseKnownHostsKeys(strings.NewReader(knownHosts), "test")
+	check(err)
+	check(s.AddExpectedKeys(keys, "test", true))
+
+	hosts := make(chan SSHHost, 4)
+	//matches by address
+	hosts <- SSHHost{hostport: "192.0.2.10:22", keyfp: fingerprint(t, testHostKey), hostKey: testHostKey}
+	//matches by name, but the key is different
+	hosts <- SSHHost{hostport: "192.0.2.11:22", keyfp: fingerprint(t, otherHostKey), hostKey: otherHostKey}
+	//not in the inventory at all
+	hosts <- SSHHost{hostport: "192.0.2.12:22", keyfp: fingerprint(t, otherHostKey), hostKey: otherHostKey}
+	close(hosts)
+	names := map[string]string{"192.0.2.11": "web.example.com"}
+	check(New(s).updateStoreFromDiscovery(hosts, ScanConfiguration{}, names))
+
+	findings, err := s.GetInventoryFindings()
+	check(err)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %#v", findings)
+	}
+	if findings[0].Hostport != "192.0.2.11:22" || findings[0].Type != FindingHostKeyMismatch || findings[0].Expected != fingerprint(t, testHostKey) {
+		t.Errorf("unexpected mismatch finding %#v", findings[0])
+	}
+	if findings[1].Hostport != "192.0.2.12:22" || findings[1].Type != FindingUnknownHost {
+		t.Errorf("unexpected unknown host finding %#v", findings[1])
+	}
+
+	//A host that is fixed loses its finding
+	hosts = make(chan SSHHost, 1)
+	hosts <- SSHHost{hostport: "192.0.2.11:22", keyfp: fingerprint(t, testHostKey), hostKey: testHostKey}
+	close(hosts)
+	check(New(s).updateStoreFromDiscovery(hosts, ScanConfiguration{}, names))
+	findings, err = s.GetInventoryFindings()
+	check(err)
+	if len(findings) != 1 || findings[0].Hostport != "192.0.2.12:22" {
+		t.Errorf("expected only the unknown host finding, got %#v", findings)
+	}
+}
+
+func TestInventoryCheckKeyType(t *testing.T) {
+	inv := newInventory([]ExpectedKey{
+		{Name: "192.0.2.10", KeyType: "ssh-rsa", Fingerprint: "SHA256:rsa"},
+		{Name: "192.0.2.11", KeyType: "ssh-rsa", Fingerprint: "SHA256:rsa"},
+		{Name: "192.0.2.11", KeyType: "ssh-ed25519", Fingerprint: fingerprint(t, testHostKey)},
+	})
+	//Only an rsa key is pinned, so a new ed25519 key is unexpected
+	h := SSHHost{hostport: "192.0.2.10:22", keyfp: fingerprint(t, testHostKey), hostKey: testHostKey}
+	if finding, expected := inv.check(h); finding != FindingUnexpectedKeyType || expected != "ssh-rsa SHA256:rsa" {
+		t.Errorf("expected an unexpected key type finding, got %q %q", finding, expected)
+	}
+	//The rsa key isn't compared against the ed25519 key the host offered
+	h.hostport = "192.0.2.11:22"
+	if finding, _ := inv.check(h); finding != "" {
+		t.Errorf("expected no finding when the key of the offered type matches, got %q", finding)
+	}
+	h.hostport = "192.0.2.10:22"
+	h.hostport = "192.0.2.10:2222"
+	if finding, _ := inv.check(h); finding != FindingUnknownHost {
+		t.Errorf("expected a different port to be an unknown host, got %q", finding)
+	}
+}
+
+func TestInventoryCheckNames(t *testing.T) {
+	keys, err := ParseKnownHostsKeys(strings.NewReader("ptr.example.com\t"+testHostKey+"\n"), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].Name != "ptr.example.com" {
+		t.Fatalf("expected a key for the tab separated line, got %#v", keys)
+	}
+	inv := newInventory(keys)
+
+	//Only the reverse DNS name is in the inventory
+	h := SSHHost{hostport: "192.0.2.10:22", reverseDNS: "other.example.com,ptr.example.com", keyfp: fingerprint(t, otherHostKey), hostKey: otherHostKey}
+	if finding, expected := inv.check(h); finding != FindingHostKeyMismatch || expected != fingerprint(t, testHostKey) {
+		t.Errorf("expected a mismatch by reverse DNS name, got %q %q", finding, expected)
+	}
+
+	//Without a key a known host can't be compared, but an unknown one is still reported
+	h.keyfp, h.hostKey = "", ""
+	if finding, _ := inv.check(h); finding != "" {
+		t.Errorf("expected no finding for a known host without a key, got %q", finding)
+	}
+	h.reverseDNS = ""
+	if finding, _ := inv.check(h); finding != FindingUnknownHost {
+		t.Errorf("expected an unknown host without a key, got %q", finding)
+	}
+}
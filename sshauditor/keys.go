@@ -0,0 +1,132 @@
+package sshauditor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+//keyReferencePrefix is prepended to a key fingerprint when a key credential
+//is stored in host_creds.  This keeps the private key itself out of the
+//password column and out of every report.
+const keyReferencePrefix = "key:"
+
+//KeyCredential is a private key that should be tried as User against every
+//host.  Only the fingerprint and comment are ever displayed.
+type KeyCredential struct {
+	User         string
+	Fingerprint  string
+	Comment      string
+	PrivateKey   string `db:"private_key" json:"-"`
+	Passphrase   string `json:"-"`
+	Certificate  string `json:"-"`
+	ScanInterval int    `db:"scan_interval"`
+}
+
+func (k KeyCredential) String() string {
+	return fmt.Sprintf("%s:%s (%s) every %d days", k.User, k.Fingerprint, k.Comment, k.ScanInterval)
+}
+
+//reference returns the value stored in the password column of host_creds for
+//this key
+func (k KeyCredential) reference() string {
+	return keyReferencePrefix + k.Fingerprint
+}
+
+//credential returns the Credential used to queue and scan this key
+func (k KeyCredential) credential() Credential {
+	key := k
+	return Credential{
+		User:         k.User,
+		Password:     k.reference(),
+		ScanInterval: k.ScanInterval,
+		Key:          &key,
+	}
+}
+
+//Signer parses the private key, decrypting it with the passphrase if needed.
+//If the key has a certificate, the returned signer presents the certificate.
+func (k KeyCredential) Signer() (ssh.Signer, error) {
+	signer, err := parsePrivateKey([]byte(k.PrivateKey), []byte(k.Passphrase))
+	if err != nil {
+		return nil, err
+	}
+	if k.Certificate == "" {
+		return signer, nil
+	}
+	cert, err := parseCertificate([]byte(k.Certificate))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return nil, errors.New("certificate does not match private key")
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+//NewKeyCredential validates a private key and optional passphrase and
+//certificate and returns a KeyCredential with the fingerprint filled in.
+func NewKeyCredential(user string, privateKey, passphrase, certificate []byte, comment string, scanInterval int) (KeyCredential, error) {
+	k := KeyCredential{
+		User:         user,
+		Comment:      comment,
+		PrivateKey:   string(privateKey),
+		Passphrase:   string(passphrase),
+		Certificate:  string(certificate),
+		ScanInterval: scanInterval,
+	}
+	signer, err := k.Signer()
+	if err != nil {
+		return k, errors.Wrap(err, "NewKeyCredential")
+	}
+	if cert, ok := signer.PublicKey().(*ssh.Certificate); ok {
+		k.Fingerprint = ssh.FingerprintSHA256(cert.Key)
+	} else {
+		k.Fingerprint = ssh.FingerprintSHA256(signer.PublicKey())
+	}
+	return k, nil
+}
+
+func parsePrivateKey(pemBytes, passphrase []byte) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(pemBytes)
+	if _, missing := err.(*ssh.PassphraseMissingError); missing {
+		if len(passphrase) == 0 {
+			return nil, err
+		}
+		return ssh.ParsePrivateKeyWithPassphrase(pemBytes, passphrase)
+	}
+	return signer, err
+}
+
+func parseCertificate(certBytes []byte) (*ssh.Certificate, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(certBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid certificate")
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.New("public key is not a certificate")
+	}
+	return cert, nil
+}
+
+//isKeyReference returns true if the password column refers to a key credential
+func isKeyReference(s string) bool {
+	return strings.HasPrefix(s, keyReferencePrefix)
+}
+
+//displayPassword returns a printable form of a stored password.  Private keys
+//stored inline in the credentials table are shown as their fingerprint.
+func displayPassword(password string) string {
+	if !isPrivateKey(password) {
+		return password
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(password))
+	if err != nil {
+		return keyReferencePrefix + "unknown"
+	}
+	return keyReferencePrefix + ssh.FingerprintSHA256(signer.PublicKey())
+}
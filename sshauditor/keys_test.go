@@ -0,0 +1,117 @@
+package sshauditor
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func readTestKey(t *testing.T) []byte {
+	return mustReadFile(t, "../testing/docker/alpine-sshd-test-key/test.key")
+}
+
+func TestNewKeyCredential(t *testing.T) {
+	key := readTestKey(t)
+	k, err := NewKeyCredential("test", key, nil, nil, "test key", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(mustReadFile(t, "../testing/docker/alpine-sshd-test-key/test.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ssh.FingerprintSHA256(pub); k.Fingerprint != want {
+		t.Errorf("Fingerprint = %q, want %q", k.Fingerprint, want)
+	}
+	if displayPassword(string(key)) != k.reference() {
+		t.Errorf("displayPassword(key) = %q, want %q", displayPassword(string(key)), k.reference())
+	}
+}
+
+func TestNewKeyCredentialPassphrase(t *testing.T) {
+	block, _ := pem.Decode(readTestKey(t))
+	encrypted, err := x509.EncryptPEMBlock(rand.Reader, block.Type, block.Bytes, []byte("hunter2"), x509.PEMCipherAES256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedKey := pem.EncodeToMemory(encrypted)
+
+	_, err = NewKeyCredential("test", encryptedKey, nil, nil, "", 7)
+	if err == nil {
+		t.Errorf("NewKeyCredential without a passphrase did not return an error")
+	}
+	_, err = NewKeyCredential("test", encryptedKey, []byte("wrong"), nil, "", 7)
+	if err == nil {
+		t.Errorf("NewKeyCredential with the wrong passphrase did not return an error")
+	}
+	k, err := NewKeyCredential("test", encryptedKey, []byte("hunter2"), nil, "", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewKeyCredential("test", readTestKey(t), nil, nil, "", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Fingerprint != plain.Fingerprint {
+		t.Errorf("Fingerprint = %q, want %q", k.Fingerprint, plain.Fingerprint)
+	}
+}
+
+func TestNewKeyCredentialCertificate(t *testing.T) {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caSigner, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userSigner, err := ssh.ParsePrivateKey(readTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &ssh.Certificate{
+		Key:             userSigner.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"test"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, caSigner); err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKeyCredential("test", readTestKey(t), nil, ssh.MarshalAuthorizedKey(cert), "", 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ssh.FingerprintSHA256(userSigner.PublicKey()); k.Fingerprint != want {
+		t.Errorf("Fingerprint = %q, want %q", k.Fingerprint, want)
+	}
+	signer, err := k.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := signer.PublicKey().(*ssh.Certificate); !ok {
+		t.Errorf("Signer() did not present the certificate")
+	}
+
+	otherCert := *cert
+	otherCert.Key = caSigner.PublicKey()
+	_, err = NewKeyCredential("test", readTestKey(t), nil, ssh.MarshalAuthorizedKey(&otherCert), "", 7)
+	if err == nil {
+		t.Errorf("NewKeyCredential with a mismatched certificate did not return an error")
+	}
+}
+
+func mustReadFile(t *testing.T, path string) []byte {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,177 @@
+package sshauditor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/inconshreveable/log15/term"
+	"github.com/pkg/errors"
+)
+
+//Log formats
+const (
+	LogFormatTerminal = "terminal"
+	LogFormatLogfmt   = "logfmt"
+	LogFormatJSON     = "json"
+)
+
+//LogConfig is where log messages are written and in what format
+type LogConfig struct {
+	Debug bool
+	//Format is terminal, logfmt or json.  Terminal falls back to logfmt
+	//when stderr is not a terminal, and files always use logfmt instead.
+	Format string
+	//File is a path to also write the log to
+	File string
+	//MaxSize is the size in bytes at which File is rotated, 0 to never
+	//rotate it.  Backups is how many rotated files are kept.
+	MaxSize int64
+	Backups int
+	//Syslog is local for the local syslog daemon, or udp://host:port or
+	//tcp://host:port to also send the log to a remote one
+	Syslog string
+}
+
+//format returns the log15 format for a destination.  Only stderr can use
+//the colored terminal format.
+func (c LogConfig) format(stderr bool) (log.Format, error) {
+	switch c.Format {
+	case LogFormatTerminal, "":
+		if stderr && term.IsTty(os.Stderr.Fd()) {
+			return log.TerminalFormat(), nil
+		}
+		return log.LogfmtFormat(), nil
+	case LogFormatLogfmt:
+		return log.LogfmtFormat(), nil
+	case LogFormatJSON:
+		return log.JsonFormat(), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", c.Format)
+}
+
+//Handler returns a log15 handler that writes to stderr and any other
+//configured destinations.  The log file stays open for the life of the
+//process.
+func (c LogConfig) Handler() (log.Handler, error) {
+	stderrFormat, err := c.format(true)
+	if err != nil {
+		return nil, err
+	}
+	format, _ := c.format(false)
+	handlers := []log.Handler{log.StreamHandler(os.Stderr, stderrFormat)}
+	if c.File != "" {
+		f, err := OpenRotatingFile(c.File, c.MaxSize, c.Backups)
+		if err != nil {
+			return nil, err
+		}
+		handlers = append(handlers, log.StreamHandler(f, format))
+	}
+	if c.Syslog != "" {
+		network, addr := "", ""
+		if c.Syslog != "local" {
+			parts := strings.SplitN(c.Syslog, "://", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid syslog destination %q, expected local or udp://host:port", c.Syslog)
+			}
+			network, addr = parts[0], parts[1]
+		}
+		h, err := syslogHandler(network, addr, format)
+		if err != nil {
+			return nil, errors.Wrap(err, "syslog")
+		}
+		handlers = append(handlers, h)
+	}
+	lvl := log.LvlInfo
+	if c.Debug {
+		lvl = log.LvlDebug
+	}
+	return log.LvlFilterHandler(lvl, log.MultiHandler(handlers...)), nil
+}
+
+//RotatingFile is a log file that is renamed to path.1 when it grows past
+//MaxSize.  Older backups are shifted to path.2 and so on, and the oldest is
+//removed.
+type RotatingFile struct {
+	path    string
+	maxSize int64
+	backups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+//OpenRotatingFile opens path for appending
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return errors.Wrap(err, "OpenRotatingFile")
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return errors.Wrap(err, "OpenRotatingFile")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "OpenRotatingFile")
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+//rotate shifts the backups and starts a new file
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.backups > 0 {
+		os.Remove(r.backup(r.backups))
+		for n := r.backups - 1; n > 0; n-- {
+			os.Rename(r.backup(n), r.backup(n+1))
+		}
+		if err := os.Rename(r.path, r.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+//Write writes p to the file, rotating it first if p would make it too big
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, errors.Wrap(err, "rotate log file")
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+//Close closes the file
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
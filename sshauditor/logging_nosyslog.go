@@ -0,0 +1,13 @@
+// +build windows plan9
+
+package sshauditor
+
+import (
+	"github.com/pkg/errors"
+
+	log "github.com/inconshreveable/log15"
+)
+
+func syslogHandler(network, addr string, format log.Format) (log.Handler, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,19 @@
+// +build !windows,!plan9
+
+package sshauditor
+
+import (
+	"log/syslog"
+
+	log "github.com/inconshreveable/log15"
+)
+
+//syslogHandler returns a handler for the local syslog daemon when network
+//is empty, or a remote one otherwise
+func syslogHandler(network, addr string, format log.Format) (log.Handler, error) {
+	priority := syslog.LOG_LOCAL4 | syslog.LOG_INFO
+	if network == "" {
+		return log.SyslogHandler(priority, "ssh-auditor", format)
+	}
+	return log.SyslogNetHandler(network, addr, priority, "ssh-auditor", format)
+}
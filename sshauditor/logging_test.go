@@ -0,0 +1,165 @@
+package sshauditor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	log "github.com/inconshreveable/log15"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh-auditor-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "logs", "ssh-auditor.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+	expected := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+	for p, want := range expected {
+		got, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s => %q, want %q", p, got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("kept more than 2 backups")
+	}
+
+	//Reopening appends and keeps track of the existing size
+	f, err = OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("fifth\n"))
+	f.Close()
+	if got, _ := ioutil.ReadFile(path + ".1"); string(got) != "fourth\n" {
+		t.Errorf("reopened file did not rotate, %s.1 => %q", path, got)
+	}
+}
+
+func TestLogConfigHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ssh-auditor-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ssh-auditor.log")
+	h, err := LogConfig{Format: LogFormatJSON, File: path}.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := log.New()
+	l.SetHandler(h)
+	l.Debug("hidden")
+	l.Info("discovered host", "hostport", "192.0.2.1:22")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("log file => %q, want one line", data)
+	}
+	var record map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["msg"] != "discovered host" || record["hostport"] != "192.0.2.1:22" {
+		t.Errorf("log record => %v", record)
+	}
+
+	for _, c := range []LogConfig{{Format: "xml"}, {Syslog: "127.0.0.1:514"}} {
+		if _, err := c.Handler(); err == nil {
+			t.Errorf("%#v.Handler() succeeded", c)
+		}
+	}
+}
+
+//TestBruteLogRedacted checks that passwords tried against a host never end up
+//in a log file, where they would be persisted or shipped off the host
+func TestBruteLogRedacted(t *testing.T) {
+	server := testSSHServer(t, false)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr().String())
+	portInt, _ := strconv.Atoi(port)
+
+	dir, err := ioutil.TempDir("", "ssh-auditor-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ssh-auditor.log")
+	h, err := LogConfig{Debug: true, Format: LogFormatJSON, File: path}.Handler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer log.Root().SetHandler(log.Root().GetHandler())
+	log.Root().SetHandler(h)
+
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Credential{{User: "root", Password: "test"}, {User: "root", Password: "Wr0ng-Secret"}} {
+		if _, err := store.AddCredential(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imp := newImport()
+	imp.add("127.0.0.1", portInt, "")
+	auditor := New(store)
+	cfg := ScanConfiguration{Concurrency: 1, AuthOptions: AuthOptions{TunnelVerification: TunnelVerifyDial}}
+	if err := auditor.DiscoverImport(imp, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auditor.Scan(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positive := false
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["msg"] == "positive brute force result" {
+			positive = true
+		}
+		if record["password"] == "test" || strings.Contains(line, "Wr0ng-Secret") {
+			t.Errorf("log record contains a plaintext password: %s", line)
+		}
+	}
+	if !positive {
+		t.Errorf("no positive brute force result was logged: %s", data)
+	}
+}
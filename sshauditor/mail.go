@@ -0,0 +1,233 @@
+package sshauditor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+)
+
+//OwnerReport is the part of an AuditReport that one owner is responsible for
+type OwnerReport struct {
+	Owner string
+	AuditReport
+}
+
+//ByOwner splits the vulnerabilities, duplicate keys and weak crypto findings
+//in r by the owner of each host.  Findings for hosts without an owner are
+//under the empty owner.  A duplicate key cluster goes to the owner of every
+//host in it, since each of them has to replace the key.  Owners without any
+//findings are left out.
+func (r AuditReport) ByOwner() map[string]OwnerReport {
+	reports := make(map[string]OwnerReport)
+	get := func(owner string) OwnerReport {
+		rep, ok := reports[owner]
+		if !ok {
+			rep = OwnerReport{Owner: owner}
+			rep.DuplicateKeys = make(map[string][]Host)
+		}
+		return rep
+	}
+	for _, v := range r.Vulnerabilities {
+		rep := get(v.Host.Metadata.Owner)
+		rep.Vulnerabilities = append(rep.Vulnerabilities, v)
+		rep.VulnerabilitiesCount++
+		reports[rep.Owner] = rep
+	}
+	for fp, hosts := range r.DuplicateKeys {
+		owners := make(map[string]bool)
+		for _, h := range hosts {
+			owners[h.Metadata.Owner] = true
+		}
+		for owner := range owners {
+			rep := get(owner)
+			rep.DuplicateKeys[fp] = hosts
+			rep.DuplicateKeysCount++
+			reports[owner] = rep
+		}
+	}
+	for _, f := range r.WeakCrypto {
+		rep := get(f.Host.Metadata.Owner)
+		rep.WeakCrypto = append(rep.WeakCrypto, f)
+		rep.WeakCryptoCount++
+		reports[rep.Owner] = rep
+	}
+	return reports
+}
+
+//DefaultOwnerReportTemplate is the body of the email sent to each owner
+const DefaultOwnerReportTemplate = `The following ssh findings are for hosts you are listed as the owner of.
+{{if .Vulnerabilities}}
+Vulnerabilities: {{.VulnerabilitiesCount}}
+{{range .Vulnerabilities}}
+	Host {{.Host.Hostport}}
+	{{- if .Host.Names}}
+	Name {{.Host.Names}}
+	{{- end}}
+	User {{.HostCredential.User}}
+	Password {{.HostCredential.Password}}
+	Result {{.HostCredential.Result}}
+	Severity {{.Evidence.Severity}}
+	Last Tested {{.HostCredential.LastTested}}
+{{end}}
+{{- end}}
+{{- if .DuplicateKeys}}
+Duplicate Keys: {{.DuplicateKeysCount}}
+{{range $key, $hosts := .DuplicateKeys}}
+{{$key}}:
+{{- range $hosts}}
+	Host {{.Hostport}} {{.Names}}
+{{- end}}
+{{end}}
+{{- end}}
+{{- if .WeakCrypto}}
+Weak Crypto: {{.WeakCryptoCount}}
+{{range .WeakCrypto}}
+	Host {{.Host.Hostport}} {{.Host.Names}}
+	Issue {{.Issue}}
+{{end}}
+{{- end}}`
+
+//DefaultOwnerReportSubject is the subject of the email sent to each owner
+const DefaultOwnerReportSubject = "ssh-auditor findings for {{.Owner}}"
+
+//Mailer sends each owner their part of the report by SMTP
+type Mailer struct {
+	//Server is the host:port of the SMTP server
+	Server string
+	Auth   smtp.Auth
+	From   string
+	//Subject and Template are executed with an OwnerReport
+	Subject  *template.Template
+	Template *template.Template
+}
+
+//NewMailer returns a Mailer using the default subject and template
+func NewMailer(server, from string) Mailer {
+	return Mailer{
+		Server:   server,
+		From:     from,
+		Subject:  template.Must(template.New("subject").Parse(DefaultOwnerReportSubject)),
+		Template: template.Must(template.New("body").Parse(DefaultOwnerReportTemplate)),
+	}
+}
+
+//recipients returns the addresses in a comma separated owner.  Owners come
+//from metadata that anyone can edit, so anything that doesn't parse as an
+//address, like a value with a line break that would add headers, is skipped.
+func recipients(owner string) []string {
+	var to []string
+	for _, addr := range strings.Split(owner, ",") {
+		if addr = strings.TrimSpace(addr); addr == "" {
+			continue
+		}
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil || strings.ContainsAny(parsed.Address, "\r\n") {
+			log.Warn("skipping invalid owner address", "owner", owner, "address", addr, "err", err)
+			continue
+		}
+		to = append(to, parsed.Address)
+	}
+	return to
+}
+
+//WriteMessage writes the email for rep to w
+func (m Mailer) WriteMessage(w io.Writer, to []string, rep OwnerReport) error {
+	for _, addr := range append([]string{m.From}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return errors.Errorf("WriteMessage: invalid address %q", addr)
+		}
+	}
+	var subject, body bytes.Buffer
+	if err := m.Subject.Execute(&subject, rep); err != nil {
+		return errors.Wrap(err, "WriteMessage")
+	}
+	if err := m.Template.Execute(&body, rep); err != nil {
+		return errors.Wrap(err, "WriteMessage")
+	}
+	_, err := fmt.Fprintf(w, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n"+
+		"MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		m.From, strings.Join(to, ", "),
+		mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())),
+		time.Now().Format(time.RFC1123Z), body.String())
+	return errors.Wrap(err, "WriteMessage")
+}
+
+//Send emails rep to its owner
+func (m Mailer) Send(to []string, rep OwnerReport) error {
+	var msg bytes.Buffer
+	if err := m.WriteMessage(&msg, to, rep); err != nil {
+		return err
+	}
+	err := smtp.SendMail(m.Server, m.Auth, m.From, to, msg.Bytes())
+	return errors.Wrapf(err, "Send %s", strings.Join(to, ","))
+}
+
+//SendOwnerReports emails each owner their report.  Findings for hosts
+//without an owner go to defaultTo, or are not sent if it is empty.  It
+//returns the owners that were sent a report.  It keeps going after a
+//failure and returns the first error.
+func (m Mailer) SendOwnerReports(reports map[string]OwnerReport, defaultTo string) ([]string, error) {
+	owners, to := ownerRecipients(reports, defaultTo)
+	var sent []string
+	var firstErr error
+	for _, owner := range owners {
+		if err := m.Send(to[owner], reports[owner]); err != nil {
+			log.Error("sending owner report failed", "owner", owner, "err", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		log.Info("sent owner report", "owner", owner, "to", strings.Join(to[owner], ","))
+		sent = append(sent, owner)
+	}
+	return sent, firstErr
+}
+
+//WriteOwnerReports writes the emails SendOwnerReports would send to w
+//instead of sending them
+func (m Mailer) WriteOwnerReports(w io.Writer, reports map[string]OwnerReport, defaultTo string) error {
+	owners, to := ownerRecipients(reports, defaultTo)
+	for _, owner := range owners {
+		if err := m.WriteMessage(w, to[owner], reports[owner]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//ownerRecipients returns the owners in reports that get an email, sorted,
+//and the addresses to send each of them to.  Findings for hosts without an
+//owner go to defaultTo, or are left out if it is empty.
+func ownerRecipients(reports map[string]OwnerReport, defaultTo string) ([]string, map[string][]string) {
+	var owners []string
+	to := make(map[string][]string)
+	for owner := range reports {
+		addrs := recipients(owner)
+		if owner == "" {
+			addrs = recipients(defaultTo)
+		}
+		if len(addrs) == 0 {
+			if owner == "" {
+				log.Warn("not sending findings for hosts without an owner", "vulnerabilities", reports[owner].VulnerabilitiesCount)
+			} else {
+				log.Warn("not sending findings, owner has no valid address", "owner", owner, "vulnerabilities", reports[owner].VulnerabilitiesCount)
+			}
+			continue
+		}
+		owners = append(owners, owner)
+		to[owner] = addrs
+	}
+	sort.Strings(owners)
+	return owners, to
+}
@@ -0,0 +1,268 @@
+package sshauditor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/dsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func authorizedKey(t *testing.T, pub interface{}) string {
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestWeakCrypto(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//ssh only checks the sizes of the DSA parameters
+	one := big.NewInt(1)
+	dsaKey := &dsa.PublicKey{
+		Parameters: dsa.Parameters{
+			P: new(big.Int).Add(new(big.Int).Lsh(one, 1023), one),
+			Q: new(big.Int).Add(new(big.Int).Lsh(one, 159), one),
+			G: big.NewInt(4),
+		},
+		Y: big.NewInt(8),
+	}
+	var tests = []struct {
+		host     Host
+		expected []string
+	}{
+		{Host{Version: "SSH-2.0-OpenSSH_7.4", HostKey: testHostKey}, nil},
+		{Host{Version: "SSH-1.5-Cisco-1.25"}, []string{"SSH-1 protocol (1.5)"}},
+		{Host{Version: "SSH-1.99-OpenSSH_3.9p1", HostKey: authorizedKey(t, dsaKey)}, []string{"SSH-1 protocol (1.99)", "ssh-dss host key"}},
+		{Host{Version: "SSH-2.0-OpenSSH_5.3", HostKey: authorizedKey(t, &small.PublicKey)}, []string{"1024 bit RSA host key"}},
+	}
+	for _, tt := range tests {
+		got := weakCryptoIssues(tt.host)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("weakCryptoIssues(%s) => %q, want %q", tt.host.Version, got, tt.expected)
+		}
+	}
+}
+
+func TestReportByOwner(t *testing.T) {
+	alice := Metadata{Owner: "alice@example.com"}
+	bob := Metadata{Owner: "bob@example.com"}
+	rep := AuditReport{
+		Vulnerabilities: []Vulnerability{
+			{Host: Host{Hostport: "192.0.2.1:22", Metadata: alice}},
+			{Host: Host{Hostport: "192.0.2.2:22", Metadata: bob}},
+			{Host: Host{Hostport: "192.0.2.3:22"}},
+		},
+		DuplicateKeys: map[string][]Host{
+			"fp": {{Hostport: "192.0.2.1:22", Metadata: alice}, {Hostport: "192.0.2.2:22", Metadata: bob}},
+		},
+		WeakCrypto: []CryptoFinding{
+			{Host: Host{Hostport: "192.0.2.1:22", Metadata: alice}, Issue: "ssh-dss host key"},
+		},
+	}
+	reports := rep.ByOwner()
+	if len(reports) != 3 {
+		t.Fatalf("ByOwner => %d owners, want 3", len(reports))
+	}
+	a := reports["alice@example.com"]
+	if a.VulnerabilitiesCount != 1 || a.DuplicateKeysCount != 1 || a.WeakCryptoCount != 1 {
+		t.Errorf("ByOwner alice => %#v", a)
+	}
+	b := reports["bob@example.com"]
+	if b.VulnerabilitiesCount != 1 || b.DuplicateKeysCount != 1 || b.WeakCryptoCount != 0 {
+		t.Errorf("ByOwner bob => %#v", b)
+	}
+	if reports[""].VulnerabilitiesCount != 1 {
+		t.Errorf("ByOwner unowned => %#v", reports[""])
+	}
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+//smtpStandIn is a minimal SMTP server that records the messages it receives
+func smtpStandIn(t *testing.T) (string, func() []smtpMessage, func()) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var messages []smtpMessage
+	handle := func(c net.Conn) {
+		defer c.Close()
+		tp := textproto.NewConn(c)
+		tp.PrintfLine("220 localhost ESMTP")
+		var msg smtpMessage
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch cmd {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL":
+				msg = smtpMessage{from: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+				tp.PrintfLine("250 OK")
+			case "RCPT":
+				msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				msg.data = string(data)
+				mu.Lock()
+				messages = append(messages, msg)
+				mu.Unlock()
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				return
+			default:
+				tp.PrintfLine("250 OK")
+			}
+		}
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(c)
+		}
+	}()
+	return l.Addr().String(), func() []smtpMessage {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]smtpMessage(nil), messages...)
+	}, func() { l.Close() }
+}
+
+func TestSendOwnerReports(t *testing.T) {
+	addr, received, stop := smtpStandIn(t)
+	defer stop()
+	rep := AuditReport{
+		Vulnerabilities: []Vulnerability{
+			{
+				HostCredential: HostCredential{User: "root", Password: "hash", Result: "exec"},
+				Host:           Host{Hostport: "192.0.2.1:22", Metadata: Metadata{Owner: "alice@example.com"}},
+			},
+			{Host: Host{Hostport: "192.0.2.2:22", Metadata: Metadata{Owner: "bob@example.com, carol@example.com"}}},
+			{Host: Host{Hostport: "192.0.2.3:22"}},
+		},
+	}
+	m := NewMailer(addr, "auditor@example.com")
+	sent, err := m.SendOwnerReports(rep.ByOwner(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(sent, "|") != "alice@example.com|bob@example.com, carol@example.com" {
+		t.Errorf("SendOwnerReports sent to %q", sent)
+	}
+	messages := received()
+	if len(messages) != 2 {
+		t.Fatalf("received %d messages, want 2", len(messages))
+	}
+	for _, msg := range messages {
+		if msg.from != "auditor@example.com" {
+			t.Errorf("message from %q", msg.from)
+		}
+		body := msg.data
+		switch strings.Join(msg.to, ",") {
+		case "alice@example.com":
+			if !strings.Contains(body, "Host 192.0.2.1:22") || strings.Contains(body, "192.0.2.2") {
+				t.Errorf("alice got:\n%s", body)
+			}
+			if !strings.Contains(body, "Subject: ssh-auditor findings for alice@example.com") {
+				t.Errorf("alice got subject:\n%s", body)
+			}
+		case "bob@example.com,carol@example.com":
+			if !strings.Contains(body, "Host 192.0.2.2:22") || strings.Contains(body, "192.0.2.1") {
+				t.Errorf("bob got:\n%s", body)
+			}
+		default:
+			t.Errorf("unexpected recipients %q", msg.to)
+		}
+	}
+
+	//Unowned findings go to the default recipient
+	sent, err = m.SendOwnerReports(rep.ByOwner(), "security@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 3 || len(received()) != 5 {
+		t.Errorf("SendOwnerReports with default recipient sent %q", sent)
+	}
+	r := bufio.NewReader(strings.NewReader(received()[4].data))
+	if _, err := textproto.NewReader(r).ReadMIMEHeader(); err != nil {
+		t.Errorf("message headers don't parse: %v", err)
+	}
+}
+
+func TestWriteOwnerReports(t *testing.T) {
+	rep := AuditReport{
+		Vulnerabilities: []Vulnerability{
+			{Host: Host{Hostport: "192.0.2.2:22", Metadata: Metadata{Owner: "bob@example.com, carol@example.com"}}},
+			{Host: Host{Hostport: "192.0.2.1:22", Metadata: Metadata{Owner: "alice@example.com"}}},
+			{Host: Host{Hostport: "192.0.2.3:22"}},
+		},
+	}
+	m := NewMailer("localhost:25", "auditor@example.com")
+	for i := 0; i < 5; i++ {
+		var buf bytes.Buffer
+		if err := m.WriteOwnerReports(&buf, rep.ByOwner(), "security@example.com"); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.String()
+		alice := strings.Index(out, "To: alice@example.com\r\n")
+		bob := strings.Index(out, "To: bob@example.com, carol@example.com\r\n")
+		security := strings.Index(out, "To: security@example.com\r\n")
+		//The unowned findings sort first, like SendOwnerReports sends them
+		if security == -1 || alice < security || bob < alice {
+			t.Fatalf("WriteOwnerReports wrote:\n%s", out)
+		}
+	}
+}
+
+func TestOwnerHeaderInjection(t *testing.T) {
+	if to := recipients("alice@example.com, Bob <bob@example.com>, not an address"); strings.Join(to, "|") != "alice@example.com|bob@example.com" {
+		t.Errorf("recipients => %q", to)
+	}
+	rep := AuditReport{
+		Vulnerabilities: []Vulnerability{
+			{Host: Host{Hostport: "192.0.2.1:22", Metadata: Metadata{Owner: "evil@example.com\r\nBcc: victim@example.com"}}},
+			{Host: Host{Hostport: "192.0.2.2:22", Metadata: Metadata{Owner: "alice@example.com"}}},
+		},
+	}
+	m := NewMailer("localhost:25", "auditor@example.com")
+	var buf bytes.Buffer
+	if err := m.WriteOwnerReports(&buf, rep.ByOwner(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Bcc:") || strings.Contains(buf.String(), "evil@example.com") || !strings.Contains(buf.String(), "To: alice@example.com\r\n") {
+		t.Errorf("WriteOwnerReports wrote:\n%s", buf.String())
+	}
+	if err := m.WriteMessage(&buf, []string{"a@example.com\r\nBcc: victim@example.com"}, OwnerReport{}); err == nil {
+		t.Errorf("expected WriteMessage to reject an address with a line break")
+	}
+}
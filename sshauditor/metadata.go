@@ -0,0 +1,251 @@
+package sshauditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Metadata is user maintained information about a host or a network, used to
+//know who to contact about a finding.
+//
+//Target is a host:port, an address, a hostname or a CIDR.  Tags are stored
+//comma separated.
+type Metadata struct {
+	Target     string
+	Owner      string
+	Department string
+	Tags       string
+	Notes      string
+}
+
+//TagList returns the tags as a list
+func (m Metadata) TagList() []string {
+	return splitTags(m.Tags)
+}
+
+//HasTag returns true if m has tag
+func (m Metadata) HasTag(tag string) bool {
+	for _, t := range m.TagList() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+//AddTags adds tags to m
+func (m *Metadata) AddTags(tags ...string) {
+	m.Tags = joinTags(append(m.TagList(), tags...))
+}
+
+//RemoveTags removes tags from m
+func (m *Metadata) RemoveTags(tags ...string) {
+	remove := make(map[string]bool)
+	for _, t := range tags {
+		remove[t] = true
+	}
+	var keep []string
+	for _, t := range m.TagList() {
+		if !remove[t] {
+			keep = append(keep, t)
+		}
+	}
+	m.Tags = joinTags(keep)
+}
+
+//splitTags splits a list of tags separated by commas, semicolons or spaces
+func splitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' '
+	})
+}
+
+//joinTags returns tags sorted, without duplicates, as stored in Metadata
+func joinTags(tags []string) string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, t := range tags {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			unique = append(unique, t)
+		}
+	}
+	sort.Strings(unique)
+	return strings.Join(unique, ",")
+}
+
+//NormalizeMetadataTarget checks that target is a host:port, address,
+//hostname or CIDR and returns it in the form it is stored in
+func NormalizeMetadataTarget(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", errors.New("empty metadata target")
+	}
+	if strings.ContainsRune(target, '/') {
+		_, ipnet, err := net.ParseCIDR(target)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid metadata target")
+		}
+		return ipnet.String(), nil
+	}
+	if ip := net.ParseIP(trimBrackets(target)); ip != nil {
+		return ip.String(), nil
+	}
+	if host, port, err := net.SplitHostPort(target); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			host = ip.String()
+		}
+		return net.JoinHostPort(host, port), nil
+	}
+	return strings.ToLower(target), nil
+}
+
+//metadataIndex finds the metadata that applies to a host
+type metadataIndex struct {
+	exact map[string]Metadata
+	nets  []struct {
+		ipnet *net.IPNet
+		m     Metadata
+	}
+}
+
+func newMetadataIndex(all []Metadata) metadataIndex {
+	idx := metadataIndex{exact: make(map[string]Metadata)}
+	for _, m := range all {
+		if _, ipnet, err := net.ParseCIDR(m.Target); err == nil {
+			idx.nets = append(idx.nets, struct {
+				ipnet *net.IPNet
+				m     Metadata
+			}{ipnet, m})
+			continue
+		}
+		idx.exact[m.Target] = m
+	}
+	//Most specific network first
+	sort.SliceStable(idx.nets, func(i, j int) bool {
+		a, _ := idx.nets[i].ipnet.Mask.Size()
+		b, _ := idx.nets[j].ipnet.Mask.Size()
+		return a > b
+	})
+	return idx
+}
+
+//lookup merges the metadata for h.  Each field comes from the most specific
+//target that sets it, from host:port, to address, to hostname, to the
+//smallest network containing the host.  Tags from every target are combined.
+func (idx metadataIndex) lookup(h Host) Metadata {
+	var matches []Metadata
+	if m, ok := idx.exact[h.Hostport]; ok {
+		matches = append(matches, m)
+	}
+	host, _, err := net.SplitHostPort(h.Hostport)
+	var ip net.IP
+	if err == nil {
+		ip = net.ParseIP(stripZone(host))
+		if ip != nil {
+			if m, ok := idx.exact[ip.String()]; ok {
+				matches = append(matches, m)
+			}
+		}
+	}
+	if h.Hostname != "" {
+		if m, ok := idx.exact[strings.ToLower(h.Hostname)]; ok {
+			matches = append(matches, m)
+		}
+	}
+	if ip != nil {
+		for _, n := range idx.nets {
+			if n.ipnet.Contains(ip) {
+				matches = append(matches, n.m)
+			}
+		}
+	}
+	var merged Metadata
+	var tags []string
+	for _, m := range matches {
+		if merged.Target == "" {
+			merged.Target = m.Target
+		}
+		if merged.Owner == "" {
+			merged.Owner = m.Owner
+		}
+		if merged.Department == "" {
+			merged.Department = m.Department
+		}
+		if merged.Notes == "" {
+			merged.Notes = m.Notes
+		}
+		tags = append(tags, m.TagList()...)
+	}
+	merged.Tags = joinTags(tags)
+	return merged
+}
+
+//attach fills in the Metadata of each host
+func (idx metadataIndex) attach(hosts []Host) {
+	for i := range hosts {
+		hosts[i].Metadata = idx.lookup(hosts[i])
+	}
+}
+
+//ParseMetadataCSV reads metadata from a CSV file with a header row.  The
+//target column may also be called host or cidr.  The owner, department,
+//tags and notes columns are all optional.  Tags may be separated by commas,
+//semicolons or spaces.
+func ParseMetadataCSV(r io.Reader) ([]Metadata, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "ParseMetadataCSV")
+	}
+	col := make(map[string]int)
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch h {
+		case "host", "cidr", "hostport":
+			h = "target"
+		}
+		col[h] = i
+	}
+	if _, ok := col["target"]; !ok {
+		return nil, errors.New("ParseMetadataCSV: header needs a target, host or cidr column")
+	}
+	get := func(rec []string, name string) string {
+		if i, ok := col[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	var all []Metadata
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "ParseMetadataCSV")
+		}
+		if get(rec, "target") == "" {
+			continue
+		}
+		target, err := NormalizeMetadataTarget(get(rec, "target"))
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("ParseMetadataCSV: line %d", line))
+		}
+		all = append(all, Metadata{
+			Target:     target,
+			Owner:      get(rec, "owner"),
+			Department: get(rec, "department"),
+			Tags:       joinTags(splitTags(get(rec, "tags"))),
+			Notes:      get(rec, "notes"),
+		})
+	}
+	return all, nil
+}
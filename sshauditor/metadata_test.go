@@ -0,0 +1,146 @@
+package sshauditor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeMetadataTarget(t *testing.T) {
+	var tests = []struct {
+		target   string
+		expected string
+	}{
+		{"10.1.2.3", "10.1.2.3"},
+		{"10.1.2.3:22", "10.1.2.3:22"},
+		{"10.1.2.9/24", "10.1.2.0/24"},
+		{"[2001:db8::0001]", "2001:db8::1"},
+		{"[2001:db8::0001]:2222", "[2001:db8::1]:2222"},
+		{"2001:db8::/64", "2001:db8::/64"},
+		{"Web01.Example.com", "web01.example.com"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeMetadataTarget(tt.target)
+		if err != nil {
+			t.Errorf("NormalizeMetadataTarget(%q) => error %v", tt.target, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("NormalizeMetadataTarget(%q) => %q, want %q", tt.target, got, tt.expected)
+		}
+	}
+	if _, err := NormalizeMetadataTarget("10.1.2.3/99"); err == nil {
+		t.Errorf("NormalizeMetadataTarget accepted an invalid CIDR")
+	}
+}
+
+func TestMetadataLookup(t *testing.T) {
+	idx := newMetadataIndex([]Metadata{
+		{Target: "10.0.0.0/8", Owner: "it@example.com", Department: "IT", Tags: "campus"},
+		{Target: "10.1.0.0/16", Owner: "netops@example.com", Tags: "dmz"},
+		{Target: "10.1.2.3", Notes: "jump host", Tags: "pci"},
+		{Target: "10.1.2.3:2222", Owner: "dev@example.com"},
+		{Target: "web01.example.com", Owner: "web@example.com"},
+	})
+	var tests = []struct {
+		host     Host
+		expected Metadata
+	}{
+		{Host{Hostport: "10.9.9.9:22"}, Metadata{Target: "10.0.0.0/8", Owner: "it@example.com", Department: "IT", Tags: "campus"}},
+		{Host{Hostport: "10.1.9.9:22"}, Metadata{Target: "10.1.0.0/16", Owner: "netops@example.com", Department: "IT", Tags: "campus,dmz"}},
+		{Host{Hostport: "10.1.2.3:22"}, Metadata{Target: "10.1.2.3", Owner: "netops@example.com", Department: "IT", Tags: "campus,dmz,pci", Notes: "jump host"}},
+		{Host{Hostport: "10.1.2.3:2222"}, Metadata{Target: "10.1.2.3:2222", Owner: "dev@example.com", Department: "IT", Tags: "campus,dmz,pci", Notes: "jump host"}},
+		{Host{Hostport: "10.1.2.4:22", Hostname: "WEB01.example.com"}, Metadata{Target: "web01.example.com", Owner: "web@example.com", Department: "IT", Tags: "campus,dmz"}},
+		{Host{Hostport: "192.0.2.1:22"}, Metadata{}},
+	}
+	for _, tt := range tests {
+		got := idx.lookup(tt.host)
+		if got != tt.expected {
+			t.Errorf("lookup(%v) => %#v, want %#v", tt.host.Hostport, got, tt.expected)
+		}
+	}
+}
+
+func TestMetadataTags(t *testing.T) {
+	m := Metadata{Tags: "pci"}
+	m.AddTags("dmz", "pci", "campus")
+	if m.Tags != "campus,dmz,pci" {
+		t.Errorf("AddTags => %q", m.Tags)
+	}
+	m.RemoveTags("dmz")
+	if m.Tags != "campus,pci" || m.HasTag("dmz") || !m.HasTag("pci") {
+		t.Errorf("RemoveTags => %q", m.Tags)
+	}
+}
+
+func TestParseMetadataCSV(t *testing.T) {
+	in := `cidr,owner,department,tags,notes
+10.1.0.0/16,netops@example.com,Networking,dmz;pci,
+10.1.2.3:22,dev@example.com,,"jump, bastion",shared jump host
+,ignored,,,
+`
+	all, err := ParseMetadataCSV(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Metadata{
+		{Target: "10.1.0.0/16", Owner: "netops@example.com", Department: "Networking", Tags: "dmz,pci"},
+		{Target: "10.1.2.3:22", Owner: "dev@example.com", Tags: "bastion,jump", Notes: "shared jump host"},
+	}
+	if len(all) != len(expected) {
+		t.Fatalf("ParseMetadataCSV => %#v, want %#v", all, expected)
+	}
+	for i := range expected {
+		if all[i] != expected[i] {
+			t.Errorf("ParseMetadataCSV[%d] => %#v, want %#v", i, all[i], expected[i])
+		}
+	}
+
+	_, err = ParseMetadataCSV(strings.NewReader("owner\nfoo@example.com\n"))
+	if err == nil {
+		t.Errorf("ParseMetadataCSV accepted a file without a target column")
+	}
+}
+
+func TestReportMetadata(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	check(s.Init())
+	check(s.addOrUpdateHost(SSHHost{hostport: "192.0.2.1:22", keyfp: "a", version: "SSH-2.0-OpenSSH_7.4"}))
+	check(s.ImportMetadata([]Metadata{
+		{Target: "192.0.2.0/24", Owner: "netops@example.com", Tags: "lab"},
+	}))
+	check(s.SetMetadata(Metadata{Target: "192.0.2.1", Department: "Research"}))
+
+	m, err := s.GetMetadata("192.0.2.1")
+	check(err)
+	if m.Department != "Research" {
+		t.Errorf("GetMetadata => %#v", m)
+	}
+	m, err = s.GetMetadata("192.0.2.99")
+	check(err)
+	if m != (Metadata{Target: "192.0.2.99"}) {
+		t.Errorf("GetMetadata for a target without metadata => %#v", m)
+	}
+
+	rep, err := New(s).GetReport()
+	check(err)
+	if len(rep.ActiveHosts) != 1 {
+		t.Fatalf("GetReport => %d active hosts, want 1", len(rep.ActiveHosts))
+	}
+	got := rep.ActiveHosts[0].Metadata
+	if got.Owner != "netops@example.com" || got.Department != "Research" || got.Tags != "lab" {
+		t.Errorf("GetReport host metadata => %#v", got)
+	}
+
+	check(s.DeleteMetadata("192.0.2.1"))
+	all, err := s.GetAllMetadata()
+	check(err)
+	if len(all) != 1 || all[0].Target != "192.0.2.0/24" {
+		t.Errorf("GetAllMetadata after delete => %#v", all)
+	}
+}
@@ -0,0 +1,272 @@
+package sshauditor
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+)
+
+//metric is a Prometheus metric family with a fixed set of label names
+type metric struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	//buckets are the upper bounds of a histogram
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+//series is one set of label values of a metric
+type series struct {
+	labelValues []string
+	value       float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func newMetric(typ, name, help string, labels ...string) *metric {
+	m := &metric{name: name, help: help, typ: typ, labels: labels, series: make(map[string]*series)}
+	//Metrics without labels are exported even before they are first set
+	if len(labels) == 0 {
+		m.get(nil)
+	}
+	return m
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *metric {
+	m := newMetric("histogram", name, help, labels...)
+	m.buckets = buckets
+	return m
+}
+
+//get returns the series for labelValues, creating it if needed.  m.mu must
+//be held.
+func (m *metric) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues, counts: make([]uint64, len(m.buckets))}
+		m.series[key] = s
+	}
+	return s
+}
+
+//Add adds v to a counter or gauge
+func (m *metric) Add(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(labelValues).value += v
+}
+
+//Set sets a gauge to v
+func (m *metric) Set(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(labelValues).value = v
+}
+
+//Replace sets a gauge with one label to values, removing the label values
+//that are no longer there
+func (m *metric) Replace(values map[string]float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series = make(map[string]*series)
+	for label, v := range values {
+		m.get([]string{label}).value = v
+	}
+}
+
+//Observe adds v to a histogram
+func (m *metric) Observe(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(labelValues)
+	for i, upper := range m.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//labelString returns the {name="value",...} part of a sample
+func (m *metric) labelString(values []string, extra ...string) string {
+	var pairs []string
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, m.labels[i], labelEscaper.Replace(v)))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, extra[i], extra[i+1]))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+//write writes m in the Prometheus text exposition format
+func (m *metric) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+	var keys []string
+	for k := range m.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := m.series[k]
+		if m.typ != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, m.labelString(s.labelValues), formatFloat(s.value))
+			continue
+		}
+		for i, upper := range m.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.labelString(s.labelValues, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, m.labelString(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", m.name, m.labelString(s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", m.name, m.labelString(s.labelValues), s.count)
+	}
+}
+
+//probeBuckets are the histogram buckets for banner and host key probes, in
+//seconds
+var probeBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+//auditorMetrics are the metrics kept by every auditor in the process.  The
+//banner and host key workers don't have an auditor to hang them off of.
+var auditorMetrics = struct {
+	hostsDiscovered *metric
+	hostsActive     *metric
+	probeDuration   *metric
+	authAttempts    *metric
+	scanQueueSize   *metric
+	vulnerabilities *metric
+	runDuration     *metric
+	runLastSuccess  *metric
+	runs            *metric
+}{
+	hostsDiscovered: newMetric("counter", "ssh_auditor_hosts_discovered_total", "Hosts found for the first time."),
+	hostsActive:     newMetric("gauge", "ssh_auditor_hosts_active", "Hosts seen in the last 2 days."),
+	probeDuration:   newHistogram("ssh_auditor_probe_duration_seconds", "Time taken by banner and host key probes.", probeBuckets, "probe"),
+	authAttempts:    newMetric("counter", "ssh_auditor_auth_attempts_total", "Credential attempts by result.", "result"),
+	scanQueueSize:   newMetric("gauge", "ssh_auditor_scan_queue_size", "Credentials due to be tried, as of the start and end of the last run."),
+	vulnerabilities: newMetric("gauge", "ssh_auditor_vulnerabilities", "Current vulnerabilities by result type.", "result"),
+	runDuration:     newMetric("gauge", "ssh_auditor_run_duration_seconds", "Duration of the last run.", "run"),
+	runLastSuccess:  newMetric("gauge", "ssh_auditor_run_last_success_timestamp_seconds", "Unix time the last successful run finished.", "run"),
+	runs:            newMetric("counter", "ssh_auditor_runs_total", "Runs by whether they succeeded.", "run", "status"),
+}
+
+func init() {
+	//Export the zero values so rate() works from the first scrape
+	for _, result := range []string{"neg", "pos", "err"} {
+		auditorMetrics.authAttempts.Add(0, result)
+	}
+}
+
+//Probe names for ssh_auditor_probe_duration_seconds
+const (
+	probeBanner      = "banner"
+	probeFingerprint = "fingerprint"
+)
+
+//observeProbe records how long a probe that started at start took
+func observeProbe(probe string, start time.Time) {
+	auditorMetrics.probeDuration.Observe(time.Since(start).Seconds(), probe)
+}
+
+//observeRun records a run that started at start.  It is meant to be
+//deferred with a pointer to the run's named error result.
+func observeRun(run string, start time.Time, err *error) {
+	auditorMetrics.runDuration.Set(time.Since(start).Seconds(), run)
+	if *err != nil {
+		auditorMetrics.runs.Add(1, run, "failure")
+		return
+	}
+	auditorMetrics.runs.Add(1, run, "success")
+	auditorMetrics.runLastSuccess.Set(float64(time.Now().Unix()), run)
+}
+
+//WriteMetrics writes every metric in the Prometheus text format
+func WriteMetrics(w io.Writer) {
+	m := auditorMetrics
+	for _, metric := range []*metric{m.hostsDiscovered, m.hostsActive, m.probeDuration, m.authAttempts,
+		m.scanQueueSize, m.vulnerabilities, m.runDuration, m.runLastSuccess, m.runs} {
+		metric.write(w)
+	}
+}
+
+//MetricsHandler serves the metrics to Prometheus
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteMetrics(w)
+	})
+}
+
+//ServeMetrics serves the metrics on /metrics at addr for the life of the
+//process, and keeps the metrics that come from the store up to date.  The
+//store isn't safe to use from the http handler, so those are refreshed at
+//the start and end of each run instead of when they are scraped.
+func (a *SSHAuditor) ServeMetrics(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return errors.Wrap(err, "ServeMetrics")
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", MetricsHandler())
+	go func() {
+		err := http.Serve(l, mux)
+		log.Error("metrics listener stopped", "err", err)
+	}()
+	log.Info("serving metrics", "addr", l.Addr().String())
+	a.storeMetrics = true
+	return a.updateStoreMetrics()
+}
+
+//updateStoreMetrics refreshes the metrics that are counted in the store
+func (a *SSHAuditor) updateStoreMetrics() error {
+	if !a.storeMetrics {
+		return nil
+	}
+	active, err := a.store.countActiveHosts(2)
+	if err != nil {
+		return err
+	}
+	queuesize, err := a.store.getScanQueueSize()
+	if err != nil {
+		return err
+	}
+	vulns, err := a.store.countVulnerabilitiesByResult()
+	if err != nil {
+		return err
+	}
+	auditorMetrics.hostsActive.Set(float64(active))
+	auditorMetrics.scanQueueSize.Set(float64(queuesize))
+	values := make(map[string]float64)
+	for result, count := range vulns {
+		values[result] = float64(count)
+	}
+	auditorMetrics.vulnerabilities.Replace(values)
+	return nil
+}
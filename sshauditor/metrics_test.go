@@ -0,0 +1,98 @@
+package sshauditor
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMetricFormat(t *testing.T) {
+	h := newHistogram("test_seconds", "A test histogram.", []float64{0.5, 1}, "probe")
+	h.Observe(0.2, "banner")
+	h.Observe(0.7, "banner")
+	h.Observe(3, "banner")
+	c := newMetric("counter", "test_total", "A test counter.", "result")
+	c.Add(2, `a"b`)
+	g := newMetric("gauge", "test_gauge", "A test gauge.")
+	g.Set(1.5)
+
+	var buf bytes.Buffer
+	for _, m := range []*metric{h, c, g} {
+		m.write(&buf)
+	}
+	expected := `# HELP test_seconds A test histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{probe="banner",le="0.5"} 1
+test_seconds_bucket{probe="banner",le="1"} 2
+test_seconds_bucket{probe="banner",le="+Inf"} 3
+test_seconds_sum{probe="banner"} 3.9
+test_seconds_count{probe="banner"} 3
+# HELP test_total A test counter.
+# TYPE test_total counter
+test_total{result="a\"b"} 2
+# HELP test_gauge A test gauge.
+# TYPE test_gauge gauge
+test_gauge 1.5
+`
+	if buf.String() != expected {
+		t.Errorf("metrics =>\n%s\nwant\n%s", buf.String(), expected)
+	}
+
+	g.Replace(nil)
+	c.Replace(map[string]float64{"exec": 1})
+	buf.Reset()
+	c.write(&buf)
+	if !strings.Contains(buf.String(), `test_total{result="exec"} 1`) || strings.Contains(buf.String(), "a\\\"b") {
+		t.Errorf("Replace left %s", buf.String())
+	}
+}
+
+func TestScanMetrics(t *testing.T) {
+	server := testSSHServer(t, true)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr().String())
+	portInt, _ := strconv.Atoi(port)
+
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Credential{{User: "root", Password: "test"}, {User: "root", Password: "wrong"}} {
+		if _, err := store.AddCredential(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imp := newImport()
+	imp.add("127.0.0.1", portInt, "")
+	auditor := New(store)
+	auditor.storeMetrics = true
+	cfg := ScanConfiguration{Concurrency: 1, AuthOptions: AuthOptions{TunnelVerification: TunnelVerifyDial}}
+	if err := auditor.DiscoverImport(imp, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auditor.Scan(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		"ssh_auditor_hosts_active 1\n",
+		"ssh_auditor_scan_queue_size 0\n",
+		`ssh_auditor_vulnerabilities{result="tunnel"} 1` + "\n",
+		`ssh_auditor_runs_total{run="scan",status="success"} `,
+		`ssh_auditor_run_last_success_timestamp_seconds{run="discover"} `,
+		`ssh_auditor_probe_duration_seconds_count{probe="fingerprint"} `,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics are missing %q:\n%s", line, body)
+		}
+	}
+}
@@ -1,10 +1,20 @@
 package sshauditor
 
 import (
+	"fmt"
 	"net"
 	"strings"
 )
 
+//DefaultMinIPv6Prefix is the shortest IPv6 prefix that will be enumerated.
+//A /112 is 65536 addresses.  Anything larger can't be swept and should be
+//discovered from explicit lists or seeds instead.
+const DefaultMinIPv6Prefix = 112
+
+//MinIPv6PrefixLimit is the lowest MinIPv6Prefix that can be set, as a /64
+//has more addresses than can be counted.
+const MinIPv6PrefixLimit = 65
+
 func inc(ip net.IP) {
 	for j := len(ip) - 1; j >= 0; j-- {
 		ip[j]++
@@ -14,18 +24,49 @@ func inc(ip net.IP) {
 	}
 }
 
+//trimBrackets removes the brackets from an address like [2001:db8::1]
+func trimBrackets(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+//stripZone removes the zone from a link local address like fe80::1%eth0
+func stripZone(host string) string {
+	if i := strings.IndexByte(host, '%'); i != -1 {
+		return host[:i]
+	}
+	return host
+}
+
+//checkPrefixSize returns an error if ipnet is an IPv6 prefix shorter than
+//minIPv6Prefix
+func checkPrefixSize(ipnet *net.IPNet, minIPv6Prefix int) error {
+	ones, bits := ipnet.Mask.Size()
+	if bits == 128 && ones < minIPv6Prefix {
+		return fmt.Errorf("IPv6 prefix %s is larger than a /%d, list the addresses or use seeds instead", ipnet, minIPv6Prefix)
+	}
+	return nil
+}
+
+//ExpandCIDRs returns every address in netblocks.  Use a HostEnumerator for
+//anything large.
 func ExpandCIDRs(netblocks []string) ([]string, error) {
 	var hosts []string
 	for _, netblock := range netblocks {
 		//If there's no slash, just treat as a single host
 		if !strings.ContainsRune(netblock, '/') {
-			hosts = append(hosts, netblock)
+			hosts = append(hosts, trimBrackets(netblock))
 			continue
 		}
 		ip, ipnet, err := net.ParseCIDR(netblock)
 		if err != nil {
 			return hosts, err
 		}
+		if err := checkPrefixSize(ipnet, DefaultMinIPv6Prefix); err != nil {
+			return hosts, err
+		}
 		for h := ip.Mask(ipnet.Mask); ipnet.Contains(h); inc(h) {
 			hosts = append(hosts, h.String())
 		}
@@ -33,26 +74,48 @@ func ExpandCIDRs(netblocks []string) ([]string, error) {
 	return hosts, nil
 }
 
-func EnumerateHosts(netblocks []string, exclude []string) ([]string, error) {
-	var hosts []string
-	allHosts, err := ExpandCIDRs(netblocks)
-	if err != nil {
-		return hosts, err
+//addressList matches hosts against a list of subnets and single hosts
+//without expanding the subnets, so any size of IPv6 prefix can be used
+type addressList struct {
+	nets  []*net.IPNet
+	hosts map[string]bool
+}
+
+func parseAddressList(list []string) (addressList, error) {
+	el := addressList{hosts: make(map[string]bool)}
+	for _, e := range list {
+		if !strings.ContainsRune(e, '/') {
+			e = trimBrackets(e)
+			if ip := net.ParseIP(e); ip != nil {
+				e = ip.String()
+			}
+			el.hosts[e] = true
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(e)
+		if err != nil {
+			return el, err
+		}
+		el.nets = append(el.nets, ipnet)
 	}
+	return el, nil
+}
 
-	allExcludeHosts, err := ExpandCIDRs(exclude)
-	if err != nil {
-		return hosts, err
+func (el addressList) contains(host string) bool {
+	if el.hosts[host] {
+		return true
 	}
-	excludeHosts := make(map[string]bool)
-	for _, ip := range allExcludeHosts {
-		excludeHosts[ip] = true
+	ip := net.ParseIP(stripZone(host))
+	if ip == nil {
+		return false
 	}
-
-	for _, ip := range allHosts {
-		if _, excluded := excludeHosts[ip]; !excluded {
-			hosts = append(hosts, ip)
+	if el.hosts[ip.String()] {
+		return true
+	}
+	for _, n := range el.nets {
+		if n.Contains(ip) {
+			return true
 		}
 	}
-	return hosts, nil
+	return false
 }
@@ -13,6 +13,13 @@ var testCases = []struct {
 	{[]string{"192.168.1.0/24"}, []string{"192.168.1.30/30"}, 252, false},
 	{[]string{"192.168.1.0/33"}, []string{}, 0, true},
 	{[]string{"192.168.1.1"}, []string{}, 1, false},
+	{[]string{"192.168.1.0/24"}, []string{"192.168.1.30"}, 255, false},
+	{[]string{"2001:db8::/120"}, []string{}, 256, false},
+	{[]string{"2001:db8::/120"}, []string{"2001:db8::/126"}, 252, false},
+	{[]string{"2001:db8::/120"}, []string{"2001:db8::/64"}, 0, false},
+	{[]string{"2001:db8::/120"}, []string{"[2001:db8::1]"}, 255, false},
+	{[]string{"2001:db8::/64"}, []string{}, 0, true},
+	{[]string{"2001:db8::1", "[2001:db8::2]"}, []string{}, 2, false},
 }
 
 func TestEnumerateHosts(t *testing.T) {
@@ -29,3 +36,22 @@ func TestEnumerateHosts(t *testing.T) {
 		}
 	}
 }
+
+func TestLogcheckUser(t *testing.T) {
+	for _, tt := range []struct {
+		host string
+		user string
+	}{
+		{"192.168.1.1", "logcheck-192.168.1.1"},
+		{"2001:db8::1", "logcheck-2001-db8--1"},
+		{"fe80::1%eth0", "logcheck-fe80--1"},
+	} {
+		user := logcheckUser(tt.host)
+		if user != tt.user {
+			t.Errorf("logcheckUser(%q) => %q, want %q", tt.host, user, tt.user)
+		}
+		if host := logcheckHost(user[len("logcheck-"):]); host != stripZone(tt.host) {
+			t.Errorf("logcheckHost(%q) => %q, want %q", user, host, stripZone(tt.host))
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package sshauditor
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+)
+
+//Event types sent to notifiers
+const (
+	//EventFinding is a credential that did not work before and works now
+	EventFinding = "finding"
+	//EventRemediation is a credential that worked before and doesn't now
+	EventRemediation = "remediation"
+	//EventHostChange is a change to the host key or version of a host
+	EventHostChange = "host change"
+	//EventDiscovery is a host that was found for the first time
+	EventDiscovery = "discovery"
+)
+
+//EventTypes is every event type
+var EventTypes = []string{EventFinding, EventRemediation, EventHostChange, EventDiscovery}
+
+//SeverityInfo is the severity of events that are not a problem by themselves
+const SeverityInfo = "info"
+
+//Event is something a notifier is told about.  Passwords are never included.
+type Event struct {
+	Type     string    `json:"type"`
+	Time     time.Time `json:"time"`
+	Hostport string    `json:"hostport"`
+	Hostname string    `json:"hostname,omitempty"`
+	Summary  string    `json:"summary"`
+	Severity string    `json:"severity,omitempty"`
+
+	User      string `json:"user,omitempty"`
+	Result    string `json:"result,omitempty"`
+	Privilege string `json:"privilege,omitempty"`
+
+	Version        string `json:"version,omitempty"`
+	Fingerprint    string `json:"fingerprint,omitempty"`
+	OldFingerprint string `json:"old_fingerprint,omitempty"`
+	NewFingerprint string `json:"new_fingerprint,omitempty"`
+	OldVersion     string `json:"old_version,omitempty"`
+	NewVersion     string `json:"new_version,omitempty"`
+	Classification string `json:"classification,omitempty"`
+}
+
+//findingEvent returns the event for a new positive brute force result
+func findingEvent(br BruteForceResult) Event {
+	return Event{
+		Type:      EventFinding,
+		Time:      time.Now(),
+		Hostport:  br.hostport,
+		Summary:   fmt.Sprintf("%s severity %s login as %s on %s", br.evidence.Severity, br.result, br.cred.User, br.hostport),
+		Severity:  br.evidence.Severity,
+		User:      br.cred.User,
+		Result:    br.result,
+		Privilege: br.evidence.Privilege,
+	}
+}
+
+//remediationEvent returns the event for a credential that stopped working.
+//previous is the result it had before.
+func remediationEvent(br BruteForceResult, previous string) Event {
+	return Event{
+		Type:     EventRemediation,
+		Time:     time.Now(),
+		Hostport: br.hostport,
+		Summary:  fmt.Sprintf("%s login as %s on %s no longer works", previous, br.cred.User, br.hostport),
+		Severity: SeverityLow,
+		User:     br.cred.User,
+		Result:   previous,
+	}
+}
+
+//hostChangeEvent returns the event for a classified host change.  Only
+//suspicious changes are more than informational.
+func hostChangeEvent(c HostChange) Event {
+	severity := SeverityInfo
+	if c.Classification == ChangeSuspicious {
+		severity = SeverityHigh
+	}
+	return Event{
+		Type:           EventHostChange,
+		Time:           time.Now(),
+		Hostport:       c.Hostport,
+		Summary:        fmt.Sprintf("host key or version change on %s classified as %s", c.Hostport, c.Classification),
+		Severity:       severity,
+		OldFingerprint: c.OldFingerprint,
+		NewFingerprint: c.NewFingerprint,
+		OldVersion:     c.OldVersion,
+		NewVersion:     c.NewVersion,
+		Classification: c.Classification,
+	}
+}
+
+//discoveryEvent returns the event for a newly discovered host
+func discoveryEvent(h SSHHost) Event {
+	return Event{
+		Type:        EventDiscovery,
+		Time:        time.Now(),
+		Hostport:    h.hostport,
+		Hostname:    h.hostname,
+		Summary:     fmt.Sprintf("discovered new host %s running %s", h.hostport, h.version),
+		Severity:    SeverityInfo,
+		Version:     h.version,
+		Fingerprint: h.keyfp,
+	}
+}
+
+//Notifier delivers events somewhere outside of the log
+type Notifier interface {
+	Notify(e Event) error
+}
+
+//EventFilter selects the events a notifier is told about
+type EventFilter func(e Event) bool
+
+//OfType returns a filter that passes events of the given types
+func OfType(types ...string) EventFilter {
+	return func(e Event) bool {
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+//AlertEvents passes new findings and suspicious host key changes, the events
+//that need someone to look at them
+func AlertEvents(e Event) bool {
+	return e.Type == EventFinding || (e.Type == EventHostChange && e.Classification == ChangeSuspicious)
+}
+
+type filteredNotifier struct {
+	Notifier
+	filter EventFilter
+}
+
+func (f filteredNotifier) Notify(e Event) error {
+	if !f.filter(e) {
+		return nil
+	}
+	return f.Notifier.Notify(e)
+}
+
+//Filter returns a notifier that only passes the events that match filter
+//on to n
+func Filter(n Notifier, filter EventFilter) Notifier {
+	return filteredNotifier{Notifier: n, filter: filter}
+}
+
+//Webhook payload formats
+const (
+	WebhookJSON       = "json"
+	WebhookSlack      = "slack"
+	WebhookMattermost = "mattermost"
+)
+
+//WebhookSignatureHeader is the header that holds the HMAC-SHA256 of the
+//request body when a webhook has a secret
+const WebhookSignatureHeader = "X-SSH-Auditor-Signature"
+
+//WebhookNotifier posts events to an HTTP endpoint
+type WebhookNotifier struct {
+	URL string
+	//Format is json for the Event itself, or slack or mattermost for an
+	//incoming webhook message
+	Format string
+	//Secret, if set, is used to sign the body.  The signature is sent in
+	//WebhookSignatureHeader as sha256=<hex>.
+	Secret []byte
+	//Retries is how many times a failed delivery is retried.  The delay
+	//starts at RetryDelay and doubles after every attempt.
+	Retries    int
+	RetryDelay time.Duration
+	Client     *http.Client
+}
+
+//NewWebhookNotifier returns a WebhookNotifier with the default retries and
+//timeouts
+func NewWebhookNotifier(url, format string, secret []byte) (*WebhookNotifier, error) {
+	switch format {
+	case WebhookJSON, WebhookSlack, WebhookMattermost:
+	default:
+		return nil, fmt.Errorf("unknown webhook format %q", format)
+	}
+	return &WebhookNotifier{
+		URL:        url,
+		Format:     format,
+		Secret:     secret,
+		Retries:    3,
+		RetryDelay: time.Second,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+//chatMessage is an incoming webhook message, which Slack and Mattermost both
+//accept.  They differ in how they mark up bold text.
+type chatMessage struct {
+	Text     string `json:"text"`
+	Username string `json:"username,omitempty"`
+}
+
+//payload returns the request body for e
+func (w *WebhookNotifier) payload(e Event) ([]byte, error) {
+	bold := "*"
+	switch w.Format {
+	case WebhookJSON:
+		return json.Marshal(e)
+	case WebhookMattermost:
+		bold = "**"
+	}
+	text := fmt.Sprintf("%sssh-auditor %s%s: %s", bold, e.Type, bold, e.Summary)
+	if e.NewFingerprint != "" {
+		text += fmt.Sprintf("\nold key %s\nnew key %s", e.OldFingerprint, e.NewFingerprint)
+	}
+	return json.Marshal(chatMessage{Text: text, Username: "ssh-auditor"})
+}
+
+//sign returns the signature header value for body
+func (w *WebhookNotifier) sign(body []byte) string {
+	mac := hmac.New(sha256.New, w.Secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+//post makes one delivery attempt.  retry is false for errors that won't go
+//away by trying again.
+func (w *WebhookNotifier) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ssh-auditor")
+	if len(w.Secret) != 0 {
+		req.Header.Set(WebhookSignatureHeader, w.sign(body))
+	}
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
+
+//Notify posts e, retrying on network errors and server errors
+func (w *WebhookNotifier) Notify(e Event) error {
+	body, err := w.payload(e)
+	if err != nil {
+		return errors.Wrap(err, "Notify")
+	}
+	delay := w.RetryDelay
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.Retries {
+			return errors.Wrapf(err, "Notify %s", w.URL)
+		}
+		log.Debug("webhook delivery failed, retrying", "url", w.URL, "err", err, "delay", delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+//AddNotifier adds a notifier that is told about every event.  Use Filter to
+//only send it some of them.
+func (a *SSHAuditor) AddNotifier(n Notifier) {
+	a.notifiers = append(a.notifiers, n)
+}
+
+//Notification queue limits
+const (
+	//notifyQueueSize is how many events can wait to be delivered before new
+	//ones are dropped
+	notifyQueueSize = 1000
+	//notifyFlushTimeout is how long the end of a run waits for the queued
+	//events to be delivered
+	notifyFlushTimeout = 30 * time.Second
+)
+
+//notificationQueue holds the events waiting to be delivered.  A goroutine
+//delivers them so a slow or dead notifier doesn't hold up the workers.
+type notificationQueue struct {
+	events chan Event
+	//flushTimeout is how long flush waits, notifyFlushTimeout if 0
+	flushTimeout time.Duration
+
+	mu      sync.Mutex
+	pending int
+	dropped int
+	//idle is closed when the last pending event has been delivered
+	idle chan struct{}
+}
+
+//notify queues e to be sent to every notifier.  If the queue is full the
+//event is dropped and counted, so the run never blocks on a notifier.
+func (a *SSHAuditor) notify(e Event) {
+	if len(a.notifiers) == 0 {
+		return
+	}
+	q := &a.notifications
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.events == nil {
+		q.events = make(chan Event, notifyQueueSize)
+		go a.deliverNotifications(q.events)
+	}
+	select {
+	case q.events <- e:
+		if q.pending == 0 {
+			q.idle = make(chan struct{})
+		}
+		q.pending++
+	default:
+		q.dropped++
+		log.Debug("notification queue is full, dropping event", "type", e.Type, "host", e.Hostport)
+	}
+}
+
+//deliverNotifications sends each queued event to every notifier.  A
+//notifier that fails is logged and does not stop the scan.
+func (a *SSHAuditor) deliverNotifications(events <-chan Event) {
+	q := &a.notifications
+	for e := range events {
+		for _, n := range a.notifiers {
+			if err := n.Notify(e); err != nil {
+				log.Error("notification failed", "type", e.Type, "host", e.Hostport, "err", err)
+			}
+		}
+		q.mu.Lock()
+		q.pending--
+		if q.pending == 0 {
+			close(q.idle)
+		}
+		q.mu.Unlock()
+	}
+}
+
+//flushNotifications waits for the queued events to be delivered, giving up
+//after the flush timeout.  It is called at the end of every run.
+func (a *SSHAuditor) flushNotifications() {
+	q := &a.notifications
+	q.mu.Lock()
+	pending, dropped, idle := q.pending, q.dropped, q.idle
+	q.dropped = 0
+	timeout := q.flushTimeout
+	q.mu.Unlock()
+	if dropped != 0 {
+		log.Warn("notification queue was full, events were dropped", "dropped", dropped, "queue", notifyQueueSize)
+	}
+	if pending == 0 {
+		return
+	}
+	if timeout == 0 {
+		timeout = notifyFlushTimeout
+	}
+	select {
+	case <-idle:
+	case <-time.After(timeout):
+		q.mu.Lock()
+		pending = q.pending
+		q.mu.Unlock()
+		log.Warn("gave up waiting for notifications to be delivered", "pending", pending, "timeout", timeout)
+	}
+}
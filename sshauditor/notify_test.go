@@ -0,0 +1,247 @@
+package sshauditor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type webhookRequest struct {
+	body      []byte
+	signature string
+}
+
+//webhookReceiver records the requests it gets.  The first failures requests
+//are answered with status instead.
+func webhookReceiver(failures int, status int) (*httptest.Server, func() []webhookRequest) {
+	var mu sync.Mutex
+	var requests []webhookRequest
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		requests = append(requests, webhookRequest{body: body, signature: r.Header.Get(WebhookSignatureHeader)})
+	}))
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]webhookRequest(nil), requests...)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	server, received := webhookReceiver(2, http.StatusServiceUnavailable)
+	defer server.Close()
+	n, err := NewWebhookNotifier(server.URL, WebhookJSON, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.RetryDelay = time.Millisecond
+	e := Event{Type: EventFinding, Hostport: "192.0.2.1:22", User: "root", Result: "exec", Summary: "test"}
+	if err := n.Notify(e); err != nil {
+		t.Fatal(err)
+	}
+	requests := received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	var got Event
+	if err := json.Unmarshal(requests[0].body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Hostport != e.Hostport || got.User != "root" || got.Type != EventFinding {
+		t.Errorf("received %#v", got)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(requests[0].body)
+	if requests[0].signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("bad signature %q", requests[0].signature)
+	}
+}
+
+func TestWebhookNotifierGivesUp(t *testing.T) {
+	server, received := webhookReceiver(10, http.StatusServiceUnavailable)
+	defer server.Close()
+	n, _ := NewWebhookNotifier(server.URL, WebhookJSON, nil)
+	n.RetryDelay = time.Millisecond
+	if err := n.Notify(Event{}); err == nil {
+		t.Errorf("Notify succeeded against a failing receiver")
+	}
+	if len(received()) != 0 {
+		t.Errorf("received requests from a failing receiver")
+	}
+
+	//Client errors are not retried
+	server, _ = webhookReceiver(1, http.StatusBadRequest)
+	defer server.Close()
+	n, _ = NewWebhookNotifier(server.URL, WebhookJSON, nil)
+	n.RetryDelay = time.Millisecond
+	if err := n.Notify(Event{}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Notify => %v, want a 400 error", err)
+	}
+}
+
+func TestWebhookChatFormats(t *testing.T) {
+	e := Event{Type: EventHostChange, Summary: "suspicious host key change on 192.0.2.1:22", OldFingerprint: "a", NewFingerprint: "b"}
+	for format, prefix := range map[string]string{WebhookSlack: "*ssh-auditor host change*", WebhookMattermost: "**ssh-auditor host change**"} {
+		n, err := NewWebhookNotifier("http://localhost/", format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := n.payload(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg chatMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(msg.Text, prefix) || !strings.Contains(msg.Text, "new key b") {
+			t.Errorf("%s payload => %q", format, msg.Text)
+		}
+	}
+	if _, err := NewWebhookNotifier("http://localhost/", "xml", nil); err == nil {
+		t.Errorf("NewWebhookNotifier accepted an unknown format")
+	}
+}
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) Notify(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestNotifyNewFindings(t *testing.T) {
+	server := testSSHServer(t, true)
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Addr().String())
+	portInt, _ := strconv.Atoi(port)
+
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []Credential{{User: "root", Password: "test"}, {User: "root", Password: "wrong"}} {
+		if _, err := store.AddCredential(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imp := newImport()
+	imp.add("127.0.0.1", portInt, "")
+	auditor := New(store)
+	rec := &eventRecorder{}
+	auditor.AddNotifier(rec)
+	cfg := ScanConfiguration{Concurrency: 1, AuthOptions: AuthOptions{TunnelVerification: TunnelVerifyDial}}
+	if err := auditor.DiscoverImport(imp, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) != 1 || rec.events[0].Type != EventDiscovery {
+		t.Fatalf("DiscoverImport notified %#v, want one discovery", rec.events)
+	}
+	if _, err := auditor.Scan(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) != 2 || rec.events[1].Type != EventFinding || rec.events[1].User != "root" || rec.events[1].Hostport != server.Addr().String() {
+		t.Fatalf("Scan notified %#v, want one finding", rec.events[1:])
+	}
+	//A credential that still works is not new, one that worked before and
+	//doesn't now is remediated
+	_, err = store.Exec("UPDATE host_creds SET result='exec' WHERE password=$1", "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auditor.Rescan(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.events) != 3 || rec.events[2].Type != EventRemediation || rec.events[2].Result != "exec" {
+		t.Errorf("Rescan notified %#v, want one remediation", rec.events[2:])
+	}
+}
+
+//blockingNotifier records events once release is closed
+type blockingNotifier struct {
+	eventRecorder
+	release chan struct{}
+}
+
+func (b *blockingNotifier) Notify(e Event) error {
+	<-b.release
+	return b.eventRecorder.Notify(e)
+}
+
+func TestNotifyDoesNotBlock(t *testing.T) {
+	auditor := New(nil)
+	b := &blockingNotifier{release: make(chan struct{})}
+	auditor.AddNotifier(b)
+	auditor.notifications.flushTimeout = 10 * time.Millisecond
+
+	start := time.Now()
+	for i := 0; i < notifyQueueSize+10; i++ {
+		auditor.notify(Event{Type: EventDiscovery})
+	}
+	auditor.flushNotifications()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("notify and flush with a stuck notifier took %s", elapsed)
+	}
+
+	close(b.release)
+	auditor.notifications.flushTimeout = 10 * time.Second
+	auditor.flushNotifications()
+	b.mu.Lock()
+	delivered := len(b.events)
+	b.mu.Unlock()
+	//One event may have been taken off the queue before it filled up
+	if delivered != notifyQueueSize && delivered != notifyQueueSize+1 {
+		t.Errorf("delivered %d events, want the %d that fit in the queue", delivered, notifyQueueSize)
+	}
+}
+
+func TestAlertEvents(t *testing.T) {
+	var tests = []struct {
+		e        Event
+		expected bool
+	}{
+		{Event{Type: EventFinding}, true},
+		{Event{Type: EventRemediation}, false},
+		{Event{Type: EventDiscovery}, false},
+		{Event{Type: EventHostChange, Classification: ChangeSuspicious}, true},
+		{Event{Type: EventHostChange, Classification: ChangeVersionUpgrade}, false},
+	}
+	rec := &eventRecorder{}
+	n := Filter(rec, AlertEvents)
+	want := 0
+	for _, tt := range tests {
+		n.Notify(tt.e)
+		if tt.expected {
+			want++
+		}
+	}
+	if len(rec.events) != want {
+		t.Errorf("Filter(AlertEvents) passed %#v", rec.events)
+	}
+}
@@ -0,0 +1,116 @@
+package sshauditor
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//Severity levels for a finding, from most to least urgent
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+//Severities lists every severity level, most urgent first
+var Severities = []string{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow}
+
+//SeverityRank returns the position of severity in Severities.  Unknown
+//severities sort last.
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+//adminGroups are groups whose members can normally become root
+var adminGroups = map[string]bool{
+	"root":  true,
+	"wheel": true,
+	"sudo":  true,
+	"admin": true,
+}
+
+var (
+	idUIDRegex   = regexp.MustCompile(`uid=(\d+)`)
+	idGroupRegex = regexp.MustCompile(`(\d+)(?:\(([^)]*)\))?`)
+	idFieldRegex = regexp.MustCompile(`(?:^|\s)(\w+)=`)
+)
+
+//idFields splits the output of id into its name=value fields.  Values run up
+//to the next field, since group names from a directory can contain spaces.
+func idFields(output string) map[string]string {
+	fields := make(map[string]string)
+	matches := idFieldRegex.FindAllStringSubmatchIndex(output, -1)
+	for i, m := range matches {
+		end := len(output)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		fields[output[m[2]:m[3]]] = strings.TrimSpace(output[m[1]:end])
+	}
+	return fields
+}
+
+//parseIDOutput extracts the uid and group names from the output of id.  ok is
+//false if the output doesn't look like id output at all.
+func parseIDOutput(output string) (uid int, groups []string, ok bool) {
+	m := idUIDRegex.FindStringSubmatch(output)
+	if m == nil {
+		return 0, nil, false
+	}
+	uid, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, nil, false
+	}
+	//gid= and groups= both list id(name) pairs.  Other fields, like the
+	//SELinux context=, are ignored since they can contain bare numbers.
+	fields := idFields(output)
+	for _, field := range []string{"gid", "groups"} {
+		for _, g := range idGroupRegex.FindAllStringSubmatch(fields[field], -1) {
+			if g[1] == "0" {
+				groups = append(groups, "root")
+			} else {
+				groups = append(groups, g[2])
+			}
+		}
+	}
+	return uid, groups, true
+}
+
+//ClassifyPrivilege determines what level of access a successful login
+//provides and how severe the finding is.  Logging in as root, being able to
+//sudo without a password or being in an admin group are all root equivalent.
+func ClassifyPrivilege(result string, ev Evidence) (privilege string, severity string) {
+	switch result {
+	case "":
+		return "", ""
+	case "tunnel", "auth":
+		//An interactive shell is as good as exec, just harder to automate
+		if ev.HasCapability(CapabilityShell) {
+			return "", SeverityHigh
+		}
+		if result == "tunnel" || ev.HasCapability(CapabilitySFTP) || ev.HasCapability(CapabilityRemoteForward) {
+			return "", SeverityMedium
+		}
+		return "", SeverityLow
+	}
+	uid, groups, ok := parseIDOutput(ev.IDOutput)
+	if ok && uid == 0 {
+		return "root", SeverityCritical
+	}
+	if ev.SudoNoPassword {
+		return "sudo", SeverityCritical
+	}
+	for _, g := range groups {
+		if adminGroups[g] {
+			return "admin", SeverityCritical
+		}
+	}
+	return "user", SeverityHigh
+}
@@ -0,0 +1,37 @@
+package sshauditor
+
+import "testing"
+
+var privilegeTestCases = []struct {
+	result    string
+	ev        Evidence
+	privilege string
+	severity  string
+}{
+	{"exec", Evidence{IDOutput: "uid=0(root) gid=0(root) groups=0(root),1(bin)\n"}, "root", SeverityCritical},
+	{"exec", Evidence{IDOutput: "uid=1000(test) gid=1000(test) groups=1000(test),10(wheel)\n"}, "admin", SeverityCritical},
+	{"exec", Evidence{IDOutput: "uid=1000(test) gid=1000(test) groups=1000(test),27(sudo)\n"}, "admin", SeverityCritical},
+	{"exec", Evidence{IDOutput: "uid=1000(test) gid=0 groups=0\n"}, "admin", SeverityCritical},
+	{"exec", Evidence{IDOutput: "uid=1000(test) gid=1000(test) groups=1000(test)\n", SudoNoPassword: true}, "sudo", SeverityCritical},
+	{"exec", Evidence{IDOutput: "uid=1000(test) gid=1000(test) groups=1000(test),100(users)\n"}, "user", SeverityHigh},
+	{"exec", Evidence{IDOutput: "uid=1000(test) gid=1000(test) groups=1000(test),1001(wheelers)\n"}, "user", SeverityHigh},
+	{"exec", Evidence{IDOutput: "uid=1000(alice) gid=1000(alice) groups=1000(alice) context=unconfined_u:unconfined_r:unconfined_t:s0-s0:c0.c1023\n"}, "user", SeverityHigh},
+	{"exec", Evidence{IDOutput: "uid=1000(alice) gid=513(domain users) groups=513(domain users),10(wheel) context=unconfined_u:unconfined_r:unconfined_t:s0\n"}, "admin", SeverityCritical},
+	{"exec", Evidence{}, "user", SeverityHigh},
+	{"tunnel", Evidence{}, "", SeverityMedium},
+	{"auth", Evidence{}, "", SeverityLow},
+	{"auth", Evidence{Capabilities: "sftp"}, "", SeverityMedium},
+	{"auth", Evidence{Capabilities: "shell,sftp"}, "", SeverityHigh},
+	{"tunnel", Evidence{Capabilities: "shell,tunnel"}, "", SeverityHigh},
+	{"auth", Evidence{Capabilities: "agent-forward,x11"}, "", SeverityLow},
+	{"", Evidence{}, "", ""},
+}
+
+func TestClassifyPrivilege(t *testing.T) {
+	for _, tt := range privilegeTestCases {
+		privilege, severity := ClassifyPrivilege(tt.result, tt.ev)
+		if privilege != tt.privilege || severity != tt.severity {
+			t.Errorf("ClassifyPrivilege(%q, %#v) => %q, %q, want %q, %q", tt.result, tt.ev, privilege, severity, tt.privilege, tt.severity)
+		}
+	}
+}
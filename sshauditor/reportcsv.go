@@ -0,0 +1,113 @@
+package sshauditor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+)
+
+//csvOwner is the owner columns of every CSV section
+func csvOwner(m Metadata) []string {
+	return []string{m.Owner, m.Department, m.Tags}
+}
+
+//csvRows returns the header and rows of one section of r
+func (r AuditReport) csvRows(section string) ([]string, [][]string, error) {
+	owner := []string{"owner", "department", "tags"}
+	var header []string
+	var rows [][]string
+	add := func(fields ...[]string) {
+		var row []string
+		for _, f := range fields {
+			row = append(row, f...)
+		}
+		rows = append(rows, row)
+	}
+	switch section {
+	case SectionVulnerabilities:
+		header = append(append([]string{"hostport", "names"}, owner...),
+			"user", "password", "result", "severity", "privilege", "last_tested", "version", "auth_method", "host_key")
+		for _, v := range r.Vulnerabilities {
+			add([]string{v.Host.Hostport, v.Host.Names()}, csvOwner(v.Host.Metadata),
+				[]string{v.User, v.Password, v.Result, v.Evidence.Severity, v.Evidence.Privilege, v.LastTested,
+					v.Host.Version, v.Evidence.AuthMethod, v.Evidence.HostKeyFingerprint})
+		}
+	case SectionDuplicateKeys:
+		header = append(append([]string{"fingerprint", "hostport", "names"}, owner...), "version", "seen_first", "seen_last")
+		var fps []string
+		for fp := range r.DuplicateKeys {
+			fps = append(fps, fp)
+		}
+		sort.Strings(fps)
+		for _, fp := range fps {
+			for _, h := range r.DuplicateKeys[fp] {
+				add([]string{fp, h.Hostport, h.Names()}, csvOwner(h.Metadata), []string{h.Version, h.SeenFirst, h.SeenLast})
+			}
+		}
+	case SectionAnomalousHosts:
+		header = append(append([]string{"hostport", "names"}, owner...), "anomaly", "reason", "version", "seen_last")
+		for _, h := range r.AnomalousHosts {
+			add([]string{h.Hostport, h.Names()}, csvOwner(h.Metadata), []string{h.Anomaly, h.AnomalyReason, h.Version, h.SeenLast})
+		}
+	case SectionInventoryFindings:
+		header = append(append([]string{"hostport"}, owner...), "finding", "expected", "observed", "seen_first", "seen_last")
+		for _, f := range r.InventoryFindings {
+			add([]string{f.Hostport}, csvOwner(f.Metadata), []string{f.Type, f.Expected, f.Observed, f.SeenFirst, f.SeenLast})
+		}
+	case SectionHostChanges:
+		header = append(append([]string{"hostport"}, owner...),
+			"time", "classification", "old_fingerprint", "new_fingerprint", "old_version", "new_version")
+		for _, c := range r.HostChanges {
+			add([]string{c.Hostport}, csvOwner(c.Metadata),
+				[]string{c.Time, c.Classification, c.OldFingerprint, c.NewFingerprint, c.OldVersion, c.NewVersion})
+		}
+	case SectionWeakCrypto:
+		header = append(append([]string{"hostport", "names"}, owner...), "issue", "version")
+		for _, c := range r.WeakCrypto {
+			add([]string{c.Host.Hostport, c.Host.Names()}, csvOwner(c.Host.Metadata), []string{c.Issue, c.Host.Version})
+		}
+	case SectionActiveHosts:
+		header = append(append([]string{"hostport", "names"}, owner...), "version", "fingerprint", "seen_first", "seen_last", "notes")
+		for _, h := range r.ActiveHosts {
+			add([]string{h.Hostport, h.Names()}, csvOwner(h.Metadata),
+				[]string{h.Version, h.Fingerprint, h.SeenFirst, h.SeenLast, h.Metadata.Notes})
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown report section %q", section)
+	}
+	return header, rows, nil
+}
+
+//csvSafe returns v so that a spreadsheet doesn't evaluate it as a formula.
+//Banners and evidence come from the scanned hosts, so a value starting with
+//=, +, -, @ or a control character gets a ' in front of it.
+func csvSafe(v string) string {
+	if v == "" {
+		return v
+	}
+	switch v[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + v
+	}
+	return v
+}
+
+//WriteCSV writes one section of r as CSV with a header row.  Values that a
+//spreadsheet would run as a formula are escaped by csvSafe.
+func (r AuditReport) WriteCSV(w io.Writer, section string) error {
+	header, rows, err := r.csvRows(section)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = csvSafe(row[i])
+		}
+	}
+	return cw.WriteAll(rows)
+}
@@ -0,0 +1,229 @@
+package sshauditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+//Report sections, as selected with AuditReport.Select
+const (
+	SectionVulnerabilities   = "vulns"
+	SectionDuplicateKeys     = "dupes"
+	SectionAnomalousHosts    = "anomalies"
+	SectionInventoryFindings = "inventory"
+	SectionHostChanges       = "changes"
+	SectionWeakCrypto        = "crypto"
+	SectionActiveHosts       = "hosts"
+)
+
+//ReportSections is every section in the order they are reported
+var ReportSections = []string{
+	SectionVulnerabilities,
+	SectionDuplicateKeys,
+	SectionAnomalousHosts,
+	SectionInventoryFindings,
+	SectionHostChanges,
+	SectionWeakCrypto,
+	SectionActiveHosts,
+}
+
+//sectionFields are the AuditReport fields that make up each section
+var sectionFields = map[string][]string{
+	SectionVulnerabilities:   {"Vulnerabilities", "VulnerabilitiesCount"},
+	SectionDuplicateKeys:     {"DuplicateKeys", "DuplicateKeysCount"},
+	SectionAnomalousHosts:    {"AnomalousHosts", "AnomalousHostsCount"},
+	SectionInventoryFindings: {"InventoryFindings", "InventoryFindingsCount"},
+	SectionHostChanges:       {"HostChanges", "HostChangesCount"},
+	SectionWeakCrypto:        {"WeakCrypto", "WeakCryptoCount"},
+	SectionActiveHosts:       {"ActiveHosts", "ActiveHostsCount"},
+}
+
+//Includes returns true if section is part of the report
+func (r AuditReport) Includes(section string) bool {
+	if r.Sections == nil {
+		return true
+	}
+	for _, s := range r.Sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+//Select returns r with only the given sections.  The rest are left empty
+//and are not output.
+func (r AuditReport) Select(sections []string) (AuditReport, error) {
+	for _, s := range sections {
+		if _, ok := sectionFields[s]; !ok {
+			return r, fmt.Errorf("unknown report section %q, expected one of %s", s, strings.Join(ReportSections, ", "))
+		}
+	}
+	r.Sections = sections
+	if !r.Includes(SectionVulnerabilities) {
+		r.Vulnerabilities, r.VulnerabilitiesCount = nil, 0
+	}
+	if !r.Includes(SectionDuplicateKeys) {
+		r.DuplicateKeys, r.DuplicateKeysCount = nil, 0
+	}
+	if !r.Includes(SectionAnomalousHosts) {
+		r.AnomalousHosts, r.AnomalousHostsCount = nil, 0
+	}
+	if !r.Includes(SectionInventoryFindings) {
+		r.InventoryFindings, r.InventoryFindingsCount = nil, 0
+	}
+	if !r.Includes(SectionHostChanges) {
+		r.HostChanges, r.HostChangesCount = nil, 0
+	}
+	if !r.Includes(SectionWeakCrypto) {
+		r.WeakCrypto, r.WeakCryptoCount = nil, 0
+	}
+	if !r.Includes(SectionActiveHosts) {
+		r.ActiveHosts, r.ActiveHostsCount = nil, 0
+	}
+	return r, nil
+}
+
+//WriteJSON writes r as indented JSON.  The sections that weren't selected
+//are left out, so they can't be mistaken for empty ones.
+func (r AuditReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if r.Sections == nil {
+		return enc.Encode(r)
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for section, names := range sectionFields {
+		if !r.Includes(section) {
+			for _, name := range names {
+				delete(fields, name)
+			}
+		}
+	}
+	return enc.Encode(fields)
+}
+
+//ReportFilter limits a report to some of the hosts and vulnerabilities.
+//Empty fields match everything.
+type ReportFilter struct {
+	//Networks are CIDRs or addresses the host has to be in
+	Networks []string
+	//Tags are metadata tags, the host has to have one of them
+	Tags []string
+	//Results are the vulnerability results to keep, like exec or tunnel
+	Results []string
+}
+
+//matcher returns a function that tells if a host matches the network and
+//tag parts of f
+func (f ReportFilter) matcher() (func(hostport string, m Metadata) bool, error) {
+	networks, err := parseAddressList(f.Networks)
+	if err != nil {
+		return nil, err
+	}
+	return func(hostport string, m Metadata) bool {
+		if len(f.Networks) != 0 {
+			host, _, err := net.SplitHostPort(hostport)
+			if err != nil {
+				host = hostport
+			}
+			if !networks.contains(host) {
+				return false
+			}
+		}
+		if len(f.Tags) == 0 {
+			return true
+		}
+		for _, t := range f.Tags {
+			if m.HasTag(t) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+//Filter returns r with only the hosts and findings that match f.  Duplicate
+//key clusters keep the matching hosts, and are left out if none match.
+func (r AuditReport) Filter(f ReportFilter) (AuditReport, error) {
+	match, err := f.matcher()
+	if err != nil {
+		return r, err
+	}
+	matchHosts := func(hosts []Host) []Host {
+		var kept []Host
+		for _, h := range hosts {
+			if match(h.Hostport, h.Metadata) {
+				kept = append(kept, h)
+			}
+		}
+		return kept
+	}
+	results := make(map[string]bool)
+	for _, res := range f.Results {
+		results[res] = true
+	}
+
+	var vulns []Vulnerability
+	for _, v := range r.Vulnerabilities {
+		if match(v.Host.Hostport, v.Host.Metadata) && (len(results) == 0 || results[v.Result]) {
+			vulns = append(vulns, v)
+		}
+	}
+	r.Vulnerabilities = vulns
+	r.VulnerabilitiesCount = len(vulns)
+
+	if r.DuplicateKeys != nil {
+		dupes := make(map[string][]Host)
+		for fp, hosts := range r.DuplicateKeys {
+			if kept := matchHosts(hosts); len(kept) != 0 {
+				dupes[fp] = kept
+			}
+		}
+		r.DuplicateKeys = dupes
+		r.DuplicateKeysCount = len(dupes)
+	}
+
+	r.AnomalousHosts = matchHosts(r.AnomalousHosts)
+	r.AnomalousHostsCount = len(r.AnomalousHosts)
+	r.ActiveHosts = matchHosts(r.ActiveHosts)
+	r.ActiveHostsCount = len(r.ActiveHosts)
+
+	var findings []InventoryFinding
+	for _, f := range r.InventoryFindings {
+		if match(f.Hostport, f.Metadata) {
+			findings = append(findings, f)
+		}
+	}
+	r.InventoryFindings = findings
+	r.InventoryFindingsCount = len(findings)
+
+	var changes []HostChange
+	for _, c := range r.HostChanges {
+		if match(c.Hostport, c.Metadata) {
+			changes = append(changes, c)
+		}
+	}
+	r.HostChanges = changes
+	r.HostChangesCount = len(changes)
+
+	var crypto []CryptoFinding
+	for _, c := range r.WeakCrypto {
+		if match(c.Host.Hostport, c.Host.Metadata) {
+			crypto = append(crypto, c)
+		}
+	}
+	r.WeakCrypto = crypto
+	r.WeakCryptoCount = len(crypto)
+	return r, nil
+}
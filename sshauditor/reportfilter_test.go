@@ -0,0 +1,143 @@
+package sshauditor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+)
+
+func testFilterReport() AuditReport {
+	web := Host{Hostport: "10.0.0.1:22", Fingerprint: "a", Metadata: Metadata{Owner: "web@example.com", Tags: "prod,web"}}
+	db := Host{Hostport: "10.1.0.1:22", Fingerprint: "a", Metadata: Metadata{Tags: "prod"}}
+	lab := Host{Hostport: "192.168.1.1:22", Fingerprint: "b"}
+	return AuditReport{
+		ActiveHosts:      []Host{web, db, lab},
+		ActiveHostsCount: 3,
+		DuplicateKeys:    map[string][]Host{"a": {web, db}},
+		Vulnerabilities: []Vulnerability{
+			{HostCredential: HostCredential{User: "root", Result: "exec"}, Host: web},
+			{HostCredential: HostCredential{User: "git", Result: "tunnel"}, Host: db},
+			{HostCredential: HostCredential{User: "pi", Result: "exec"}, Host: lab},
+		},
+		VulnerabilitiesCount: 3,
+		HostChanges:          []HostChange{{Hostport: lab.Hostport}},
+		WeakCrypto:           []CryptoFinding{{Host: db, Issue: "ssh-dss host key"}},
+	}
+}
+
+func TestReportFilter(t *testing.T) {
+	var tests = []struct {
+		filter      ReportFilter
+		hosts       int
+		vulns       int
+		dupeHosts   int
+		changes     int
+		weakCrypto  int
+		description string
+	}{
+		{ReportFilter{}, 3, 3, 2, 1, 1, "no filter"},
+		{ReportFilter{Networks: []string{"10.0.0.0/8"}}, 2, 2, 2, 0, 1, "cidr"},
+		{ReportFilter{Networks: []string{"10.0.0.1"}}, 1, 1, 1, 0, 0, "address"},
+		{ReportFilter{Tags: []string{"web", "lab"}}, 1, 1, 1, 0, 0, "tag"},
+		{ReportFilter{Results: []string{"exec"}}, 3, 2, 2, 1, 1, "result"},
+		{ReportFilter{Networks: []string{"10.0.0.0/8"}, Results: []string{"exec"}}, 2, 1, 2, 0, 1, "cidr and result"},
+	}
+	for _, tt := range tests {
+		rep, err := testFilterReport().Filter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rep.ActiveHostsCount != tt.hosts || rep.VulnerabilitiesCount != tt.vulns || len(rep.DuplicateKeys["a"]) != tt.dupeHosts ||
+			rep.HostChangesCount != tt.changes || rep.WeakCryptoCount != tt.weakCrypto {
+			t.Errorf("%s: got %d hosts, %d vulns, %d duplicate key hosts, %d changes, %d weak crypto; want %d, %d, %d, %d, %d",
+				tt.description, rep.ActiveHostsCount, rep.VulnerabilitiesCount, len(rep.DuplicateKeys["a"]), rep.HostChangesCount, rep.WeakCryptoCount,
+				tt.hosts, tt.vulns, tt.dupeHosts, tt.changes, tt.weakCrypto)
+		}
+	}
+	if _, err := testFilterReport().Filter(ReportFilter{Networks: []string{"10.0.0.0/33"}}); err == nil {
+		t.Errorf("Filter accepted an invalid CIDR")
+	}
+}
+
+func TestReportSelect(t *testing.T) {
+	rep, err := testFilterReport().Select([]string{SectionVulnerabilities, SectionDuplicateKeys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.Includes(SectionVulnerabilities) || rep.Includes(SectionActiveHosts) || rep.ActiveHosts != nil {
+		t.Errorf("Select kept the wrong sections: %#v", rep)
+	}
+	var buf bytes.Buffer
+	if err := rep.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 4 || fields["VulnerabilitiesCount"] == nil || fields["DuplicateKeys"] == nil {
+		t.Errorf("WriteJSON => %s", buf.String())
+	}
+	if _, err := testFilterReport().Select([]string{"everything"}); err == nil {
+		t.Errorf("Select accepted an unknown section")
+	}
+}
+
+func TestReportCSV(t *testing.T) {
+	rep := testFilterReport()
+	for _, section := range ReportSections {
+		var buf bytes.Buffer
+		if err := rep.WriteCSV(&buf, section); err != nil {
+			t.Fatalf("%s: %v", section, err)
+		}
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatalf("%s: %v", section, err)
+		}
+		if len(records) == 0 {
+			t.Errorf("%s: no header", section)
+		}
+	}
+	var buf bytes.Buffer
+	rep.WriteCSV(&buf, SectionVulnerabilities)
+	records, _ := csv.NewReader(&buf).ReadAll()
+	if len(records) != 4 || records[1][0] != "10.0.0.1:22" || records[1][2] != "web@example.com" ||
+		records[1][4] != "prod,web" || records[1][5] != "root" || records[1][7] != "exec" {
+		t.Errorf("vulns csv => %q", records)
+	}
+	buf.Reset()
+	rep.WriteCSV(&buf, SectionDuplicateKeys)
+	records, _ = csv.NewReader(&buf).ReadAll()
+	if len(records) != 3 || records[2][0] != "a" || records[2][1] != "10.1.0.1:22" {
+		t.Errorf("dupes csv => %q", records)
+	}
+	if err := rep.WriteCSV(&buf, "everything"); err == nil {
+		t.Errorf("WriteCSV accepted an unknown section")
+	}
+}
+
+func TestReportCSVFormulas(t *testing.T) {
+	rep := testFilterReport()
+	rep.ActiveHosts[0].Version = `=HYPERLINK("http://example.com","x")`
+	rep.ActiveHosts[1].Version = "+1"
+	rep.ActiveHosts[2].Version = "SSH-2.0-=not a formula"
+	rep.ActiveHosts[2].Metadata.Notes = "@SUM(A1)"
+	var buf bytes.Buffer
+	if err := rep.WriteCSV(&buf, SectionActiveHosts); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`'=HYPERLINK("http://example.com","x")`, "'+1", "SSH-2.0-=not a formula"}
+	for i, want := range expected {
+		if got := records[i+1][5]; got != want {
+			t.Errorf("version => %q, want %q", got, want)
+		}
+	}
+	if got := records[3][9]; got != "'@SUM(A1)" {
+		t.Errorf("notes => %q, want '@SUM(A1)", got)
+	}
+}
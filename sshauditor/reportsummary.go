@@ -0,0 +1,110 @@
+package sshauditor
+
+import "sort"
+
+//summaryVersions is how many versions the summary lists before grouping the
+//rest as other
+const summaryVersions = 10
+
+//summaryTrendRuns is how many past runs the summary trend covers
+const summaryTrendRuns = 30
+
+//ReportSummary is the statistics shown at the top of the HTML report
+type ReportSummary struct {
+	Hosts           int
+	Vulnerabilities int
+	VulnerableHosts int
+	//Severities counts the vulnerabilities at every severity, most urgent
+	//first
+	Severities []ReportCount
+
+	DuplicateKeyClusters int
+	DuplicateKeyHosts    int
+
+	//Versions counts the active hosts by version, most common first
+	Versions []ReportCount
+
+	//Trend are the last successful runs, oldest first, and TrendMax the
+	//largest count in them, for scaling charts
+	Trend    []Run
+	TrendMax int
+}
+
+//Summary returns the statistics of r.  runs are the recorded runs from the
+//store, used for the trend.
+func (r AuditReport) Summary(runs []Run) ReportSummary {
+	s := ReportSummary{
+		Hosts:                len(r.ActiveHosts),
+		Vulnerabilities:      len(r.Vulnerabilities),
+		DuplicateKeyClusters: len(r.DuplicateKeys),
+	}
+
+	severities := make(map[string]int)
+	vulnerable := make(map[string]bool)
+	for _, v := range r.Vulnerabilities {
+		severities[v.Evidence.Severity]++
+		vulnerable[v.Host.Hostport] = true
+	}
+	s.VulnerableHosts = len(vulnerable)
+	for _, sev := range Severities {
+		s.Severities = append(s.Severities, ReportCount{Key: sev, Count: severities[sev]})
+		delete(severities, sev)
+	}
+	unknown := 0
+	for _, count := range severities {
+		unknown += count
+	}
+	if unknown != 0 {
+		s.Severities = append(s.Severities, ReportCount{Key: "unknown", Count: unknown})
+	}
+
+	for _, hosts := range r.DuplicateKeys {
+		s.DuplicateKeyHosts += len(hosts)
+	}
+
+	versions := make(map[string]int)
+	for _, h := range r.ActiveHosts {
+		versions[h.Version]++
+	}
+	for v, count := range versions {
+		s.Versions = append(s.Versions, ReportCount{Key: v, Count: count})
+	}
+	sort.Slice(s.Versions, func(i, j int) bool {
+		if s.Versions[i].Count != s.Versions[j].Count {
+			return s.Versions[i].Count > s.Versions[j].Count
+		}
+		return s.Versions[i].Key < s.Versions[j].Key
+	})
+	if len(s.Versions) > summaryVersions {
+		other := ReportCount{Key: "other"}
+		for _, v := range s.Versions[summaryVersions-1:] {
+			other.Count += v.Count
+		}
+		s.Versions = append(s.Versions[:summaryVersions-1], other)
+	}
+
+	for _, run := range runs {
+		if run.Status == RunSuccess {
+			s.Trend = append(s.Trend, run)
+		}
+	}
+	if len(s.Trend) > summaryTrendRuns {
+		s.Trend = s.Trend[len(s.Trend)-summaryTrendRuns:]
+	}
+	for _, run := range s.Trend {
+		for _, count := range []int{run.ActiveHosts, run.Vulnerabilities, run.DuplicateKeys} {
+			if count > s.TrendMax {
+				s.TrendMax = count
+			}
+		}
+	}
+	return s
+}
+
+//percent returns part as a whole percentage of total, or 0 if total is 0
+func percent(part, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return part * 100 / total
+}
@@ -0,0 +1,56 @@
+package sshauditor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestReportSummary(t *testing.T) {
+	rep := testFilterReport()
+	rep.ActiveHosts[0].Version = "SSH-2.0-OpenSSH_7.4"
+	rep.ActiveHosts[1].Version = "SSH-2.0-OpenSSH_7.4"
+	rep.ActiveHosts[2].Version = "SSH-2.0-dropbear"
+	rep.Vulnerabilities[0].Evidence.Severity = SeverityCritical
+	rep.Vulnerabilities[1].Evidence.Severity = SeverityMedium
+	rep.Vulnerabilities[2].Evidence.Severity = SeverityCritical
+
+	runs := []Run{
+		{ID: 1, Status: RunSuccess, ActiveHosts: 2, Vulnerabilities: 1},
+		{ID: 2, Status: RunFailure},
+		{ID: 3, Status: RunSuccess, ActiveHosts: 3, Vulnerabilities: 4, DuplicateKeys: 1},
+	}
+	s := rep.Summary(runs)
+	if s.Hosts != 3 || s.Vulnerabilities != 3 || s.VulnerableHosts != 3 || s.DuplicateKeyClusters != 1 || s.DuplicateKeyHosts != 2 {
+		t.Errorf("Summary counts => %+v", s)
+	}
+	severities := []ReportCount{{SeverityCritical, 2}, {SeverityHigh, 0}, {SeverityMedium, 1}, {SeverityLow, 0}}
+	if !reflect.DeepEqual(s.Severities, severities) {
+		t.Errorf("Summary severities => %v, want %v", s.Severities, severities)
+	}
+	versions := []ReportCount{{"SSH-2.0-OpenSSH_7.4", 2}, {"SSH-2.0-dropbear", 1}}
+	if !reflect.DeepEqual(s.Versions, versions) {
+		t.Errorf("Summary versions => %v, want %v", s.Versions, versions)
+	}
+	if len(s.Trend) != 2 || s.Trend[0].ID != 1 || s.Trend[1].ID != 3 || s.TrendMax != 4 {
+		t.Errorf("Summary trend => %v, max %d", s.Trend, s.TrendMax)
+	}
+}
+
+func TestReportSummaryOtherVersions(t *testing.T) {
+	var rep AuditReport
+	for i := 0; i < summaryVersions+2; i++ {
+		rep.ActiveHosts = append(rep.ActiveHosts, Host{Version: fmt.Sprintf("SSH-2.0-v%02d", i)})
+	}
+	rep.ActiveHosts = append(rep.ActiveHosts, Host{Version: "SSH-2.0-v00"})
+	s := rep.Summary(nil)
+	if len(s.Versions) != summaryVersions {
+		t.Fatalf("Summary listed %d versions, want %d", len(s.Versions), summaryVersions)
+	}
+	if s.Versions[0] != (ReportCount{"SSH-2.0-v00", 2}) {
+		t.Errorf("most common version => %v", s.Versions[0])
+	}
+	if other := s.Versions[summaryVersions-1]; other != (ReportCount{"other", 3}) {
+		t.Errorf("other versions => %v", other)
+	}
+}
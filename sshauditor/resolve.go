@@ -0,0 +1,103 @@
+package sshauditor
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+)
+
+//lookupHost and lookupAddr are variables so tests can replace them
+var (
+	lookupHost = net.LookupHost
+	lookupAddr = net.DefaultResolver.LookupAddr
+)
+
+//reverseDNSTimeout is how long a PTR lookup can take before the host is
+//stored without reverse DNS names
+const reverseDNSTimeout = 5 * time.Second
+
+//isAddressOrNetwork returns true if target is an IP address or a CIDR, and
+//so doesn't need to be resolved
+func isAddressOrNetwork(target string) bool {
+	if strings.ContainsRune(target, '/') {
+		return true
+	}
+	return net.ParseIP(stripZone(trimBrackets(target))) != nil
+}
+
+//resolveHostnames replaces every hostname in targets with all of its A and
+//AAAA records.  It returns the new targets and a map of each resolved
+//address to the name it came from, so the same machine isn't stored under
+//both its name and its address.  Names that don't resolve are dropped.
+func resolveHostnames(targets []string) ([]string, map[string]string) {
+	var resolved []string
+	names := make(map[string]string)
+	for _, t := range targets {
+		if isAddressOrNetwork(t) {
+			resolved = append(resolved, t)
+			continue
+		}
+		addrs, err := lookupHost(t)
+		if err != nil {
+			log.Warn("unable to resolve host", "host", t, "error", err)
+			continue
+		}
+		for _, addr := range addrs {
+			if _, seen := names[addr]; !seen {
+				names[addr] = t
+				resolved = append(resolved, addr)
+			}
+		}
+	}
+	return resolved, names
+}
+
+//resolveTargets resolves the hostnames in the includes and excludes of cfg.
+//Excluded names are replaced by their addresses so they also exclude hosts
+//found by a subnet, and kept so they still exclude the same name given as an
+//include.  It returns the names of the included addresses like
+//resolveHostnames.
+func resolveTargets(cfg *ScanConfiguration) map[string]string {
+	var names map[string]string
+	cfg.Include, names = resolveHostnames(cfg.Include)
+	exclude, _ := resolveHostnames(cfg.Exclude)
+	for _, x := range cfg.Exclude {
+		if !isAddressOrNetwork(x) {
+			exclude = append(exclude, x)
+		}
+	}
+	cfg.Exclude = exclude
+	return names
+}
+
+//reverseDNS returns the PTR records for the address in hostport
+func reverseDNS(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil || net.ParseIP(stripZone(host)) == nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reverseDNSTimeout)
+	defer cancel()
+	names, err := lookupAddr(ctx, stripZone(host))
+	if err != nil {
+		return ""
+	}
+	for i := range names {
+		names[i] = strings.TrimSuffix(names[i], ".")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+//hostnameFor returns the name that hostport was discovered under
+func hostnameFor(hostport string, names map[string]string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return ""
+	}
+	return names[host]
+}
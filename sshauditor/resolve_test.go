@@ -0,0 +1,95 @@
+package sshauditor
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestResolveHostnames(t *testing.T) {
+	defer func(h func(string) ([]string, error)) { lookupHost = h }(lookupHost)
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "web.example.com":
+			return []string{"192.0.2.10", "2001:db8::10"}, nil
+		case "www.example.com":
+			return []string{"192.0.2.10"}, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	targets, names := resolveHostnames([]string{"192.0.2.0/30", "web.example.com", "www.example.com", "missing.example.com", "[2001:db8::1]"})
+	expectedTargets := []string{"192.0.2.0/30", "192.0.2.10", "2001:db8::10", "[2001:db8::1]"}
+	if !reflect.DeepEqual(targets, expectedTargets) {
+		t.Errorf("resolveHostnames targets => %v, want %v", targets, expectedTargets)
+	}
+	expectedNames := map[string]string{"192.0.2.10": "web.example.com", "2001:db8::10": "web.example.com"}
+	if !reflect.DeepEqual(names, expectedNames) {
+		t.Errorf("resolveHostnames names => %v, want %v", names, expectedNames)
+	}
+	if n := hostnameFor("[2001:db8::10]:22", names); n != "web.example.com" {
+		t.Errorf("hostnameFor => %q, want web.example.com", n)
+	}
+}
+
+func TestHostNames(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	check(s.Init())
+
+	h := SSHHost{hostport: "192.0.2.10:22", version: "v", keyfp: "fp", hostname: "web.example.com", reverseDNS: "web.example.com,www.example.com"}
+	check(s.addOrUpdateHost(h))
+	//Found again by a subnet scan, without a name
+	h.hostname = ""
+	h.reverseDNS = "web.example.com"
+	check(s.setHostNames(h))
+
+	hosts, err := s.getKnownHosts()
+	check(err)
+	got := hosts["192.0.2.10:22"]
+	if got.Hostname != "web.example.com" || got.ReverseDNS != "web.example.com" {
+		t.Errorf("unexpected names %q %q", got.Hostname, got.ReverseDNS)
+	}
+	got.ReverseDNS = "web.example.com,www.example.com"
+	if got.Names() != "web.example.com, www.example.com" {
+		t.Errorf("Names() => %q", got.Names())
+	}
+}
+
+func TestResolveTargetsExclude(t *testing.T) {
+	defer func(h func(string) ([]string, error)) { lookupHost = h }(lookupHost)
+	lookupHost = func(host string) ([]string, error) {
+		switch host {
+		case "web.example.com":
+			return []string{"192.0.2.10"}, nil
+		case "db.example.com":
+			return []string{"192.0.2.20"}, nil
+		}
+		return nil, fmt.Errorf("no such host %s", host)
+	}
+	cfg := ScanConfiguration{
+		Include: []string{"web.example.com", "db.example.com", "192.0.2.8/30"},
+		Exclude: []string{"web.example.com", "missing.example.com"},
+	}
+	names := resolveTargets(&cfg)
+	if names["192.0.2.20"] != "db.example.com" {
+		t.Errorf("resolveTargets names => %v", names)
+	}
+	e, err := NewHostEnumerator(cfg.Include, cfg.Exclude, DefaultMinIPv6Prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hosts []string
+	it := e.Iterator(false)
+	for h, ok := it.Next(); ok; h, ok = it.Next() {
+		hosts = append(hosts, h)
+	}
+	expected := []string{"192.0.2.8", "192.0.2.9", "192.0.2.11", "192.0.2.20"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("hosts => %v, want %v", hosts, expected)
+	}
+}
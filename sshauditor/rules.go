@@ -0,0 +1,113 @@
+package sshauditor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//Rule matches a pattern against something observed during a login.
+//
+//Field is one of
+//	output  - the output of the id command
+//	banner  - the pre-authentication banner sent by the server
+//	version - the server version string
+//	hostkey - the SHA256 fingerprint of the server host key
+//	uname   - the output of uname -a, only collected for honeypot detection
+//Match is either "substring" (the default) or "regex".
+type Rule struct {
+	Name    string
+	Field   string
+	Match   string
+	Pattern string
+
+	re *regexp.Regexp
+}
+
+//DefaultFalsePositiveRules are devices known to accept any password and then
+//print an error instead of running the command
+var DefaultFalsePositiveRules = []Rule{
+	{Name: "ps-auth-fail", Field: "output", Pattern: "Auth User/Pass with PS...fail...Please reconnect"},
+}
+
+func (r *Rule) compile() error {
+	switch r.Field {
+	case "output", "banner", "version", "hostkey", "uname":
+	default:
+		return fmt.Errorf("rule %q: unknown field %q", r.Name, r.Field)
+	}
+	switch r.Match {
+	case "", "substring":
+	case "regex":
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return errors.Wrapf(err, "rule %q", r.Name)
+		}
+		r.re = re
+	default:
+		return fmt.Errorf("rule %q: unknown match type %q", r.Name, r.Match)
+	}
+	return nil
+}
+
+func (r Rule) matchString(s string) bool {
+	if r.re != nil {
+		return r.re.MatchString(s)
+	}
+	return strings.Contains(s, r.Pattern)
+}
+
+//Matches returns true if the rule matches the evidence from a login
+func (r Rule) Matches(ev Evidence) bool {
+	switch r.Field {
+	case "output":
+		return r.matchString(ev.IDOutput)
+	case "banner":
+		return r.matchString(ev.Banner)
+	case "version":
+		return r.matchString(ev.Version)
+	case "hostkey":
+		return r.matchString(ev.HostKeyFingerprint)
+	case "uname":
+		return r.matchString(ev.Uname)
+	}
+	return false
+}
+
+//matchRules returns the first rule that matches ev
+func matchRules(rules []Rule, ev Evidence) (Rule, bool) {
+	for _, r := range rules {
+		if r.Matches(ev) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+//LoadRules reads a JSON list of rules from a file, for example
+//	[
+//	  {"Name": "acme", "Field": "output", "Pattern": "Login incorrect"},
+//	  {"Name": "widget", "Field": "version", "Match": "regex", "Pattern": "^SSH-2.0-Widget_[0-9.]+$"}
+//	]
+func LoadRules(path string) ([]Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "LoadRules")
+	}
+	defer f.Close()
+	var rules []Rule
+	err = json.NewDecoder(f).Decode(&rules)
+	if err != nil {
+		return nil, errors.Wrapf(err, "LoadRules: %s", path)
+	}
+	for i := range rules {
+		if err := rules[i].compile(); err != nil {
+			return nil, errors.Wrapf(err, "LoadRules: %s", path)
+		}
+	}
+	return rules, nil
+}
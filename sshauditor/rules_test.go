@@ -0,0 +1,66 @@
+package sshauditor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules.json")
+	err = ioutil.WriteFile(path, []byte(`[
+		{"Name": "acme", "Field": "output", "Pattern": "Login incorrect"},
+		{"Name": "widget", "Field": "version", "Match": "regex", "Pattern": "^SSH-2.0-Widget_[0-9.]+$"},
+		{"Name": "motd", "Field": "banner", "Pattern": "Appliance"}
+	]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		ev       Evidence
+		expected string
+	}{
+		{Evidence{IDOutput: "uid=0(root) gid=0(root)"}, ""},
+		{Evidence{IDOutput: "Login incorrect\n"}, "acme"},
+		{Evidence{Version: "SSH-2.0-Widget_1.2"}, "widget"},
+		{Evidence{Version: "SSH-2.0-Widget_1.2 extra"}, ""},
+		{Evidence{Banner: "Welcome to the Appliance\n"}, "motd"},
+	}
+	for _, tt := range tests {
+		rule, ok := matchRules(rules, tt.ev)
+		if ok != (tt.expected != "") || rule.Name != tt.expected {
+			t.Errorf("matchRules(%#v) => %q, %v, want %q", tt.ev, rule.Name, ok, tt.expected)
+		}
+	}
+
+	for _, bad := range []string{
+		`[{"Name": "bad", "Field": "nope", "Pattern": "x"}]`,
+		`[{"Name": "bad", "Field": "output", "Match": "regex", "Pattern": "("}]`,
+		`[{"Name": "bad", "Field": "output", "Match": "glob", "Pattern": "x"}]`,
+	} {
+		if err := ioutil.WriteFile(path, []byte(bad), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRules(path); err == nil {
+			t.Errorf("LoadRules(%s) did not return an error", bad)
+		}
+	}
+}
+
+func TestDefaultFalsePositiveRules(t *testing.T) {
+	ev := Evidence{IDOutput: "Auth User/Pass with PS...fail...Please reconnect"}
+	if _, ok := matchRules(AuthOptions{}.falsePositiveRules(), ev); !ok {
+		t.Errorf("Default rules did not match %q", ev.IDOutput)
+	}
+}
@@ -0,0 +1,273 @@
+package sshauditor
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/pkg/errors"
+)
+
+//Run statuses
+const (
+	RunSuccess = "success"
+	RunFailure = "failure"
+)
+
+//storeTimeFormat is how the store writes datetime('now', 'localtime')
+const storeTimeFormat = "2006-01-02 15:04:05"
+
+//Run is a discover, scan or rescan that was recorded in the store.  The
+//counts are of the state at the end of a successful run.
+type Run struct {
+	ID              int64
+	Type            string
+	Started         string
+	Finished        string
+	Status          string
+	ActiveHosts     int `db:"active_hosts"`
+	Vulnerabilities int
+	DuplicateKeys   int `db:"duplicate_keys"`
+	//Pruned is set once the saved hosts and vulnerabilities were deleted
+	Pruned bool
+}
+
+//RunVulnerability is a vulnerability as it is saved at the end of a run.
+//Password is redacted in a DiffReport.
+type RunVulnerability struct {
+	Hostport string
+	User     string
+	Password string
+	Result   string
+	Severity string
+	Metadata Metadata `db:"-"`
+}
+
+func (v RunVulnerability) key() string {
+	return v.Hostport + "\x00" + v.User + "\x00" + v.Password
+}
+
+//recordRun records a run that started at start.  It is meant to be deferred
+//with a pointer to the run's named error result.  Failing to record the run
+//is logged and doesn't fail the run.
+func (a *SSHAuditor) recordRun(run string, start time.Time, err *error) {
+	status := RunSuccess
+	if *err != nil {
+		status = RunFailure
+	}
+	id, rerr := a.store.addRun(Run{Type: run, Started: start.Format(storeTimeFormat), Status: status})
+	if rerr != nil {
+		log.Error("failed to record run", "run", run, "err", rerr)
+		return
+	}
+	log.Debug("recorded run", "run", run, "id", id, "status", status)
+}
+
+//DiffReport is what changed between a past run and now
+type DiffReport struct {
+	Since Run
+
+	NewHosts              []Host
+	NewHostsCount         int
+	DisappearedHosts      []Host
+	DisappearedHostsCount int
+
+	NewVulnerabilities             []RunVulnerability
+	NewVulnerabilitiesCount        int
+	RemediatedVulnerabilities      []RunVulnerability
+	RemediatedVulnerabilitiesCount int
+
+	NewDuplicateKeys      map[string][]Host
+	NewDuplicateKeysCount int
+
+	HostChanges      []HostChange
+	HostChangesCount int
+}
+
+//parseDate parses a date, which can have a time of day
+func parseDate(date string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", storeTimeFormat, "2006-01-02T15:04:05", time.RFC3339} {
+		t, err := time.ParseInLocation(layout, date, time.Local)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+//parseSince parses a run id or a date
+func parseSince(since string) (id int64, t time.Time, err error) {
+	if id, err := strconv.ParseInt(since, 10, 64); err == nil {
+		return id, t, nil
+	}
+	if t, ok := parseDate(since); ok {
+		return 0, t, nil
+	}
+	return 0, t, errors.Errorf("%q is not a run id or a date like 2006-01-02", since)
+}
+
+//PruneRuns deletes the hosts and vulnerabilities saved with old runs, so the
+//store doesn't keep growing.  Runs that finished before olderThan, a date,
+//and runs that are not among the newest keep successful runs are pruned.
+//Either can be left empty or 0.  It returns the number of runs pruned.
+func (a *SSHAuditor) PruneRuns(keep int, olderThan string) (int, error) {
+	if keep <= 0 && olderThan == "" {
+		return 0, errors.New("PruneRuns: nothing to prune, keep or olderThan is required")
+	}
+	before := ""
+	if olderThan != "" {
+		t, ok := parseDate(olderThan)
+		if !ok {
+			return 0, errors.Errorf("%q is not a date like 2006-01-02", olderThan)
+		}
+		before = t.Format(storeTimeFormat)
+	}
+	n, err := a.store.PruneRuns(keep, before)
+	if err != nil {
+		return n, err
+	}
+	log.Info("pruned runs", "runs", n, "keep", keep, "older_than", before)
+	return n, nil
+}
+
+//GetDiffReport compares the current hosts and vulnerabilities with those at
+//the end of a past run.  since is a run id, or a date to use the last run
+//before it.
+func (a *SSHAuditor) GetDiffReport(since string) (DiffReport, error) {
+	var rep DiffReport
+	id, t, err := parseSince(since)
+	if err != nil {
+		return rep, err
+	}
+	if id != 0 {
+		rep.Since, err = a.store.GetRun(id)
+	} else {
+		rep.Since, err = a.store.getSuccessfulRunBefore(t.Format(storeTimeFormat))
+	}
+	if err != nil {
+		return rep, err
+	}
+	if rep.Since.Status != RunSuccess {
+		return rep, errors.Errorf("run %d did not succeed and has nothing to compare with", rep.Since.ID)
+	}
+	if rep.Since.Pruned {
+		return rep, errors.Errorf("run %d was pruned and has nothing to compare with", rep.Since.ID)
+	}
+	if id == 0 && rep.Since.Finished > t.Format(storeTimeFormat) {
+		log.Warn("no run before the date, comparing with the first run after it", "since", since, "run", rep.Since.ID, "finished", rep.Since.Finished)
+	}
+
+	oldHosts, err := a.store.getRunHosts(rep.Since.ID)
+	if err != nil {
+		return rep, err
+	}
+	hosts, err := a.store.getRunHosts(0)
+	if err != nil {
+		return rep, err
+	}
+	oldByHostport := make(map[string]bool)
+	for _, h := range oldHosts {
+		oldByHostport[h.Hostport] = true
+	}
+	byHostport := make(map[string]bool)
+	for _, h := range hosts {
+		byHostport[h.Hostport] = true
+		if !oldByHostport[h.Hostport] {
+			rep.NewHosts = append(rep.NewHosts, h)
+		}
+	}
+	for _, h := range oldHosts {
+		if !byHostport[h.Hostport] {
+			rep.DisappearedHosts = append(rep.DisappearedHosts, h)
+		}
+	}
+
+	oldVulns, err := a.store.getRunVulnerabilities(rep.Since.ID)
+	if err != nil {
+		return rep, err
+	}
+	vulns, err := a.store.getRunVulnerabilities(0)
+	if err != nil {
+		return rep, err
+	}
+	oldByKey := make(map[string]bool)
+	for _, v := range oldVulns {
+		oldByKey[v.key()] = true
+	}
+	byKey := make(map[string]bool)
+	for _, v := range vulns {
+		byKey[v.key()] = true
+		if !oldByKey[v.key()] {
+			rep.NewVulnerabilities = append(rep.NewVulnerabilities, v)
+		}
+	}
+	for _, v := range oldVulns {
+		if !byKey[v.key()] {
+			rep.RemediatedVulnerabilities = append(rep.RemediatedVulnerabilities, v)
+		}
+	}
+	for _, vs := range [][]RunVulnerability{rep.NewVulnerabilities, rep.RemediatedVulnerabilities} {
+		for i := range vs {
+			vs[i].Password = RedactSecret(vs[i].Password)
+		}
+		sort.SliceStable(vs, func(i, j int) bool {
+			return SeverityRank(vs[i].Severity) < SeverityRank(vs[j].Severity)
+		})
+	}
+
+	oldDupes := duplicateKeys(oldHosts)
+	rep.NewDuplicateKeys = duplicateKeys(hosts)
+	for fp := range rep.NewDuplicateKeys {
+		if _, existed := oldDupes[fp]; existed {
+			delete(rep.NewDuplicateKeys, fp)
+		}
+	}
+
+	rep.HostChanges, err = a.store.getHostChangesSince(rep.Since.Finished)
+	if err != nil {
+		return rep, err
+	}
+
+	all, err := a.store.GetAllMetadata()
+	if err != nil {
+		return rep, err
+	}
+	rep.attachMetadata(newMetadataIndex(all), hosts)
+
+	rep.NewHostsCount = len(rep.NewHosts)
+	rep.DisappearedHostsCount = len(rep.DisappearedHosts)
+	rep.NewVulnerabilitiesCount = len(rep.NewVulnerabilities)
+	rep.RemediatedVulnerabilitiesCount = len(rep.RemediatedVulnerabilities)
+	rep.NewDuplicateKeysCount = len(rep.NewDuplicateKeys)
+	rep.HostChangesCount = len(rep.HostChanges)
+	return rep, nil
+}
+
+//attachMetadata fills in the metadata of every host in the report.  hosts
+//are the current active hosts, used to also match on hostnames.
+func (r *DiffReport) attachMetadata(idx metadataIndex, hosts []Host) {
+	idx.attach(r.NewHosts)
+	idx.attach(r.DisappearedHosts)
+	for _, hosts := range r.NewDuplicateKeys {
+		idx.attach(hosts)
+	}
+	known := make(map[string]Host)
+	for _, h := range hosts {
+		known[h.Hostport] = h
+	}
+	host := func(hostport string) Host {
+		if h, ok := known[hostport]; ok {
+			return h
+		}
+		return Host{Hostport: hostport}
+	}
+	for _, vs := range [][]RunVulnerability{r.NewVulnerabilities, r.RemediatedVulnerabilities} {
+		for i := range vs {
+			vs[i].Metadata = idx.lookup(host(vs[i].Hostport))
+		}
+	}
+	for i := range r.HostChanges {
+		r.HostChanges[i].Metadata = idx.lookup(host(r.HostChanges[i].Hostport))
+	}
+}
@@ -0,0 +1,172 @@
+package sshauditor
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiffReport(t *testing.T) {
+	server := testSSHServer(t, true)
+	defer server.Close()
+	hostport := server.Addr().String()
+	_, port, _ := net.SplitHostPort(hostport)
+	portInt, _ := strconv.Atoi(port)
+
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddCredential(Credential{User: "root", Password: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	imp := newImport()
+	imp.add("127.0.0.1", portInt, "")
+	auditor := New(store)
+	cfg := ScanConfiguration{Concurrency: 1, AuthOptions: AuthOptions{TunnelVerification: TunnelVerifyDial}}
+	if err := auditor.DiscoverImport(imp, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auditor.Scan(cfg); err != nil {
+		t.Fatal(err)
+	}
+	runs, err := store.GetRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Type != "discover" || runs[1].Type != "scan" ||
+		runs[0].ActiveHosts != 1 || runs[0].Vulnerabilities != 0 || runs[1].Vulnerabilities != 1 {
+		t.Fatalf("GetRuns => %#v", runs)
+	}
+
+	rep, err := auditor.GetDiffReport("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.NewVulnerabilitiesCount != 1 || rep.NewVulnerabilities[0].User != "root" ||
+		rep.NewVulnerabilities[0].Password != RedactSecret("test") || rep.NewHostsCount != 0 {
+		t.Errorf("diff since the discovery => %#v", rep)
+	}
+
+	//A second host with the same key shows up and the credential stops working
+	var fp string
+	if err := store.Get(&fp, "SELECT fingerprint FROM hosts"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Exec(`INSERT INTO hosts (hostport, version, fingerprint, seen_first, seen_last)
+		VALUES ('192.0.2.1:22', 'SSH-2.0-test', $1, datetime('now', 'localtime'), datetime('now', 'localtime'))`, fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Exec("UPDATE host_creds SET result=''"); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := time.Now().Add(24 * time.Hour).Format("2006-01-02")
+	rep, err = auditor.GetDiffReport(tomorrow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Since.ID != 2 {
+		t.Errorf("diff since %s compared with run %d, want 2", tomorrow, rep.Since.ID)
+	}
+	if rep.NewVulnerabilitiesCount != 0 || rep.RemediatedVulnerabilitiesCount != 1 {
+		t.Errorf("diff => %d new and %d remediated vulnerabilities, want 0 and 1",
+			rep.NewVulnerabilitiesCount, rep.RemediatedVulnerabilitiesCount)
+	}
+	if rep.NewHostsCount != 1 || rep.NewHosts[0].Hostport != "192.0.2.1:22" || len(rep.NewDuplicateKeys[fp]) != 2 {
+		t.Errorf("diff => new hosts %#v, new duplicate keys %#v", rep.NewHosts, rep.NewDuplicateKeys)
+	}
+
+	if _, err := store.Exec("UPDATE hosts SET seen_last='2000-01-01 00:00:00' WHERE hostport=$1", hostport); err != nil {
+		t.Fatal(err)
+	}
+	rep, err = auditor.GetDiffReport("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.DisappearedHostsCount != 1 || rep.DisappearedHosts[0].Hostport != hostport {
+		t.Errorf("diff => disappeared hosts %#v", rep.DisappearedHosts)
+	}
+
+	for _, since := range []string{"42", "last week"} {
+		if _, err := auditor.GetDiffReport(since); err == nil {
+			t.Errorf("GetDiffReport(%q) succeeded", since)
+		}
+	}
+}
+
+func TestPruneRuns(t *testing.T) {
+	store, err := NewSQLiteStore(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.Exec(`INSERT INTO hosts (hostport, version, fingerprint, seen_first, seen_last)
+		VALUES ('192.0.2.1:22', 'SSH-2.0-test', 'fp', datetime('now', 'localtime'), datetime('now', 'localtime'))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, status := range []string{RunSuccess, RunSuccess, RunFailure, RunSuccess, RunSuccess} {
+		id, err := store.addRun(Run{Type: "scan", Started: "2020-01-01 00:00:00", Status: status})
+		if err != nil {
+			t.Fatal(err)
+		}
+		finished := time.Date(2020, 1, i+1, 0, 0, 0, 0, time.Local).Format(storeTimeFormat)
+		if _, err := store.Exec("UPDATE runs SET finished=$1 WHERE id=$2", finished, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	auditor := New(store)
+	if _, err := auditor.PruneRuns(0, ""); err == nil {
+		t.Errorf("PruneRuns without limits succeeded")
+	}
+
+	n, err := auditor.PruneRuns(0, "2020-01-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("PruneRuns older than 2020-01-02 pruned %d runs, want 1", n)
+	}
+	n, err = auditor.PruneRuns(2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("PruneRuns keeping 2 pruned %d runs, want 1", n)
+	}
+
+	runs, err := store.GetRuns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range runs {
+		want := r.ID <= 2
+		if r.Pruned != want || r.ActiveHosts != 1 && r.Status == RunSuccess {
+			t.Errorf("run %d pruned %v with %d hosts, want pruned %v", r.ID, r.Pruned, r.ActiveHosts, want)
+		}
+	}
+	var snapshots int
+	if err := store.Get(&snapshots, "SELECT count(*) FROM run_hosts"); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots != 2 {
+		t.Errorf("%d run_hosts rows left, want 2", snapshots)
+	}
+	if _, err := auditor.GetDiffReport("1"); err == nil {
+		t.Errorf("GetDiffReport against a pruned run succeeded")
+	}
+	rep, err := auditor.GetDiffReport("2020-01-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Since.ID != 4 {
+		t.Errorf("diff since 2020-01-03 compared with run %d, want 4", rep.Since.ID)
+	}
+}
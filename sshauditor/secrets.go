@@ -0,0 +1,115 @@
+package sshauditor
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//encryptedPrefix marks a column value as encrypted by a SecretBox
+const encryptedPrefix = "enc:v1:"
+
+//ErrNoSecretKey is returned when an encrypted value is read from the store
+//but no secret key was configured
+var ErrNoSecretKey = errors.New("credential is encrypted but no secret key was configured")
+
+//SecretBox encrypts credential secrets before they are written to the store.
+//The nonce is derived from the plaintext so the same secret always encrypts
+//to the same value.  This lets encrypted passwords remain part of primary
+//keys and WHERE clauses.
+type SecretBox struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+//NewSecretBox returns a SecretBox using key.  Any key of at least 16 bytes is
+//accepted, the actual encryption keys are derived from it.
+func NewSecretBox(key []byte) (*SecretBox, error) {
+	if len(key) < 16 {
+		return nil, errors.New("secret key must be at least 16 bytes")
+	}
+	block, err := aes.NewCipher(deriveKey(key, "ssh-auditor encrypt"))
+	if err != nil {
+		return nil, errors.Wrap(err, "NewSecretBox")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewSecretBox")
+	}
+	return &SecretBox{
+		aead:   aead,
+		macKey: deriveKey(key, "ssh-auditor nonce"),
+	}, nil
+}
+
+//LoadSecretKey reads a secret key from a file, ignoring surrounding whitespace
+func LoadSecretKey(path string) ([]byte, error) {
+	key, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "LoadSecretKey")
+	}
+	return []byte(strings.TrimSpace(string(key))), nil
+}
+
+func (b *SecretBox) Encrypt(plaintext string) string {
+	mac := hmac.New(sha256.New, b.macKey)
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:b.aead.NonceSize()]
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed)
+}
+
+func (b *SecretBox) Decrypt(s string) (string, error) {
+	if !isEncrypted(s) {
+		return s, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, encryptedPrefix))
+	if err != nil {
+		return "", errors.Wrap(err, "Decrypt")
+	}
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("Decrypt: ciphertext too short")
+	}
+	plaintext, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Decrypt: wrong secret key?")
+	}
+	return string(plaintext), nil
+}
+
+func isEncrypted(s string) bool {
+	return strings.HasPrefix(s, encryptedPrefix)
+}
+
+//RedactSecret returns a form of a password that is safe to show in reports:
+//its length and, for passwords of at least 8 characters, the first and last
+//character, like p******d (len 8).  Key credentials are already displayed as
+//their fingerprint and are returned unchanged.
+func RedactSecret(s string) string {
+	if s == "" {
+		return "(blank)"
+	}
+	if isKeyReference(s) {
+		return s
+	}
+	r := []rune(s)
+	masked := strings.Repeat("*", len(r))
+	if len(r) >= 8 {
+		masked = string(r[0]) + strings.Repeat("*", len(r)-2) + string(r[len(r)-1])
+	}
+	return fmt.Sprintf("%s (len %d)", masked, len(r))
+}
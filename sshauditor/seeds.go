@@ -0,0 +1,113 @@
+package sshauditor
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+//IPv6 networks are far too large to sweep, so IPv6 hosts are discovered from
+//addresses that are already known: explicit lists, known_hosts files and the
+//neighbor cache of the scanning host.
+
+//appendSeed adds host to seeds if it is an IP address that isn't already
+//present
+func appendSeed(seeds []string, seen map[string]bool, host string) []string {
+	ip := net.ParseIP(stripZone(host))
+	if ip == nil || seen[host] {
+		return seeds
+	}
+	seen[host] = true
+	return append(seeds, host)
+}
+
+//parseKnownHostsSeeds returns the IP addresses listed in a known_hosts file.
+//Hashed entries can't be reversed and are skipped.
+func parseKnownHostsSeeds(r io.Reader) []string {
+	var seeds []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "@") {
+			//@cert-authority and @revoked lines
+			continue
+		}
+		for _, h := range strings.Split(fields[0], ",") {
+			if strings.HasPrefix(h, "|") {
+				continue
+			}
+			//[host]:port entries
+			if strings.HasPrefix(h, "[") {
+				if host, _, err := net.SplitHostPort(h); err == nil {
+					h = host
+				} else {
+					h = trimBrackets(h)
+				}
+			}
+			seeds = appendSeed(seeds, seen, h)
+		}
+	}
+	return seeds
+}
+
+//KnownHostsSeeds returns the IP addresses listed in a known_hosts file
+func KnownHostsSeeds(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "KnownHostsSeeds")
+	}
+	defer f.Close()
+	return parseKnownHostsSeeds(f), nil
+}
+
+//parseNeighbors returns the addresses in the output of 'ip -6 neigh show'.
+//Link local addresses get the interface as their zone so they can be dialed.
+func parseNeighbors(r io.Reader) []string {
+	var seeds []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		state := fields[len(fields)-1]
+		if state == "FAILED" || state == "INCOMPLETE" {
+			continue
+		}
+		host := fields[0]
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		if ip.IsLinkLocalUnicast() {
+			for i := 1; i < len(fields)-1; i++ {
+				if fields[i] == "dev" {
+					host = host + "%" + fields[i+1]
+					break
+				}
+			}
+		}
+		seeds = appendSeed(seeds, seen, host)
+	}
+	return seeds
+}
+
+//NeighborSeeds returns the IPv6 neighbors of the scanning host
+func NeighborSeeds() ([]string, error) {
+	out, err := exec.Command("ip", "-6", "neigh", "show").Output()
+	if err != nil {
+		return nil, errors.Wrap(err, "NeighborSeeds")
+	}
+	return parseNeighbors(bytes.NewReader(out)), nil
+}
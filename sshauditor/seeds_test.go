@@ -0,0 +1,35 @@
+package sshauditor
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseKnownHostsSeeds(t *testing.T) {
+	knownHosts := `# comment
+server.example.com,192.0.2.10 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample
+[2001:db8::5]:2222 ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample
+2001:db8::6,192.0.2.10 ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQExample
+|1|c2FsdA==|aGFzaA== ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample
+@cert-authority *.example.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIExample
+`
+	seeds := parseKnownHostsSeeds(strings.NewReader(knownHosts))
+	expected := []string{"192.0.2.10", "2001:db8::5", "2001:db8::6"}
+	if !reflect.DeepEqual(seeds, expected) {
+		t.Errorf("parseKnownHostsSeeds => %v, want %v", seeds, expected)
+	}
+}
+
+func TestParseNeighbors(t *testing.T) {
+	neigh := `fe80::1 dev eth0 lladdr 52:54:00:12:34:56 router REACHABLE
+2001:db8::7 dev eth0 lladdr 52:54:00:12:34:57 STALE
+2001:db8::8 dev eth0 FAILED
+2001:db8::9 dev eth0  INCOMPLETE
+`
+	seeds := parseNeighbors(strings.NewReader(neigh))
+	expected := []string{"fe80::1%eth0", "2001:db8::7"}
+	if !reflect.DeepEqual(seeds, expected) {
+		t.Errorf("parseNeighbors => %v, want %v", seeds, expected)
+	}
+}
@@ -1,7 +1,6 @@
 package sshauditor
 
 import (
-	"fmt"
 	"net"
 	"strings"
 	"time"
@@ -10,22 +9,6 @@ import (
 	"golang.org/x/crypto/ssh"
 )
 
-var falsePositiveBanners = [...]string{
-	"Auth User/Pass with PS...fail...Please reconnect",
-}
-
-//isFalsePositiveBanner returns true if the ssh login banner
-//appears to be a false positive.  This could probably just check
-//for the presense of 'uid=' but for now, check for known banners
-func isFalsePositiveBanner(output string) bool {
-	for _, b := range falsePositiveBanners {
-		if strings.Contains(output, b) {
-			return true
-		}
-	}
-	return false
-}
-
 //isPrivateKey returns true if the passed string is a ssh private key instead
 //of a password
 func isPrivateKey(s string) bool {
@@ -49,20 +32,42 @@ func DialWithDeadline(network, addr string, config *ssh.ClientConfig) (*ssh.Clie
 	return ssh.NewClient(c, chans, reqs), nil
 }
 
+//logcheckUser returns the username used to mark auth attempts against host
+//in its logs.  sshd and log parsers don't cope well with colons in usernames,
+//so the colons in IPv6 addresses are replaced with dashes and any zone is
+//dropped.
+func logcheckUser(host string) string {
+	return "logcheck-" + strings.Replace(stripZone(host), ":", "-", -1)
+}
+
+//logcheckHost is the inverse of logcheckUser, it returns the address from the
+//part of a logcheck username after "logcheck-"
+func logcheckHost(s string) string {
+	return strings.Replace(s, "-", ":", -1)
+}
+
 func FetchSSHKeyFingerprint(hostport string) string {
+	fp, _ := FetchSSHHostKey(hostport)
+	return fp
+}
+
+//FetchSSHHostKey returns the SHA256 fingerprint of the host key of hostport
+//and the key itself in authorized_keys format
+func FetchSSHHostKey(hostport string) (string, string) {
 
-	var keyFingerprint string
+	var keyFingerprint, hostKey string
 
 	DumpHostkey := func(hostname string, remote net.Addr, key ssh.PublicKey) error {
 		fp := ssh.FingerprintSHA256(key)
 		keyFingerprint = fp
+		hostKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
 		return nil
 	}
 
 	user := "security"
 	host, _, err := net.SplitHostPort(hostport)
 	if err == nil {
-		user = fmt.Sprintf("logcheck-%s", host)
+		user = logcheckUser(host)
 	}
 
 	config := &ssh.ClientConfig{
@@ -81,50 +86,147 @@ func FetchSSHKeyFingerprint(hostport string) string {
 		client.Close()
 		log.Error("initial probe worked!?!?", "host", hostport, "user", user, "password", "security")
 	}
-	return keyFingerprint
+	return keyFingerprint, hostKey
 }
 
-func SSHExecAttempt(client *ssh.Client, hostport string) bool {
+//Evidence is what was observed during a successful login.  It is stored with
+//the finding so it can be shown to be real without logging in again.
+type Evidence struct {
+	Collected          string
+	AuthMethod         string `db:"auth_method"`
+	Version            string
+	Banner             string
+	HostKeyFingerprint string `db:"host_key_fingerprint"`
+	IDOutput           string `db:"id_output"`
+	TunnelDestination  string `db:"tunnel_destination"`
+	SudoNoPassword     bool   `db:"sudo_nopasswd"`
+	Privilege          string
+	Severity           string
+	Capabilities       string
+	Uname              string `db:"-" json:",omitempty"`
+}
+
+//AuthOptions control the optional checks done after a successful login
+type AuthOptions struct {
+	//SudoProbe runs 'sudo -n true' to see if the user can become root
+	//without a password
+	SudoProbe bool
+	//AcceptsAnythingCheck tries a random user and password against any
+	//host that a credential worked on
+	AcceptsAnythingCheck bool
+	//FalsePositiveRules identify devices that appear to run commands but
+	//don't.  DefaultFalsePositiveRules is used if this is nil.
+	FalsePositiveRules []Rule
+	//HoneypotCheck looks for signs that a host is a honeypot on the first
+	//successful login to each host
+	HoneypotCheck bool
+	//HoneypotRules identify known honeypots.  DefaultHoneypotRules is used
+	//if this is nil.
+	HoneypotRules []Rule
+	//CapabilityProbes checks for sftp, port forwarding, agent forwarding,
+	//x11 and interactive shell access after a successful login
+	CapabilityProbes bool
+	//TunnelVerification is TunnelVerifyDial (the default) or
+	//TunnelVerifyCanary
+	TunnelVerification string
+	//TunnelCanaries are the host:port canary listeners to reach when
+	//TunnelVerification is TunnelVerifyCanary
+	TunnelCanaries []string
+
+	//unames caches the uname output of each host during a run
+	unames *unameCache
+}
+
+func (o AuthOptions) honeypotRules() []Rule {
+	if o.HoneypotRules == nil {
+		return DefaultHoneypotRules
+	}
+	return o.HoneypotRules
+}
+
+func (o AuthOptions) falsePositiveRules() []Rule {
+	if o.FalsePositiveRules == nil {
+		return DefaultFalsePositiveRules
+	}
+	return o.FalsePositiveRules
+}
+
+//SSHExecAttempt runs id and returns its output and whether the command
+//appeared to actually run
+func SSHExecAttempt(client *ssh.Client, hostport string) (string, bool) {
 	session, err := client.NewSession()
 	if err != nil {
 		log.Error("successful login but failed to open session", "host", hostport)
-		return false
+		return "", false
 	}
 	defer session.Close()
 	out, err := session.CombinedOutput("id")
 	if err != nil {
 		log.Error("successful login but failed to run id", "host", hostport)
-		return false
+		return string(out), false
 	}
-	if isFalsePositiveBanner(string(out)) {
-		log.Error("successful login but unexpected id command output", "host", hostport, "output", string(out))
+	return string(out), true
+}
+
+//SSHSudoAttempt returns true if 'sudo -n true' succeeds, meaning the user can
+//run commands as root without a password.  -n makes sudo fail instead of
+//prompting.
+func SSHSudoAttempt(client *ssh.Client, hostport string) bool {
+	session, err := client.NewSession()
+	if err != nil {
+		log.Error("failed to open session for sudo probe", "host", hostport)
 		return false
 	}
-	return true
+	defer session.Close()
+	return session.Run("sudo -n true") == nil
 }
 
-func SSHDialAttempt(client *ssh.Client, dest string) bool {
+//SSHUnameAttempt returns the output of uname -a.  Honeypots return canned
+//output that identifies them.
+func SSHUnameAttempt(client *ssh.Client, hostport string) string {
+	session, err := client.NewSession()
+	if err != nil {
+		log.Error("failed to open session for uname", "host", hostport)
+		return ""
+	}
+	defer session.Close()
+	out, _ := session.CombinedOutput("uname -a")
+	return string(out)
+}
+
+//SSHDialAttempt tries to tunnel a connection to dest, or to the same port on
+//localhost.  It returns the destination that worked.
+func SSHDialAttempt(client *ssh.Client, dest string) (string, bool) {
+	return sshDialAttempt(client, dest, log.Error)
+}
+
+//sshDialAttempt is SSHDialAttempt with failed dials logged using logFailure
+func sshDialAttempt(client *ssh.Client, dest string, logFailure func(string, ...interface{})) (string, bool) {
 	//If there was no error, the dial worked and this is vulnerable!
 	conn, err := client.Dial("tcp", dest)
 	if err == nil {
 		conn.Close()
-		return true
+		return dest, true
 	}
 	//It may only allow local forwarding, so try replacing the ip with localhost
-	log.Error("tunnel attempt failed", "error", err)
+	logFailure("tunnel attempt failed", "host", dest, "error", err)
 	_, port, err := net.SplitHostPort(dest)
 	if err != nil {
 		log.Error("Invalid host port in SSHDialAttempt, should not happen", "error", err)
-		return false
+		return "", false
 	}
-	newDest := fmt.Sprintf("127.0.0.1:%s", port)
+	localhost := "127.0.0.1"
+	if host, _, _ := net.SplitHostPort(dest); strings.Contains(host, ":") {
+		localhost = "::1"
+	}
+	newDest := net.JoinHostPort(localhost, port)
 	conn, err = client.Dial("tcp", newDest)
 	if err == nil {
 		conn.Close()
-		return true
+		return newDest, true
 	}
-	log.Error("tunnel attempt failed", "error", err)
-	return false
+	logFailure("tunnel attempt failed", "host", dest, "error", err)
+	return "", false
 }
 
 func challengeReponder(password string) ssh.KeyboardInteractiveChallenge {
@@ -137,56 +239,112 @@ func challengeReponder(password string) ssh.KeyboardInteractiveChallenge {
 	}
 }
 
-func genAuthMethod(password string) ([]ssh.AuthMethod, error) {
-	if isPrivateKey(password) {
-		signer, err := ssh.ParsePrivateKey([]byte(password))
-		if err != nil {
-			return []ssh.AuthMethod{}, err
-		}
-
+//genAuthMethod returns the auth methods to try for cred.  Each method records
+//its name in ev.AuthMethod when it is attempted, so after a successful login
+//it holds the method that worked.
+func genAuthMethod(cred Credential, ev *Evidence) ([]ssh.AuthMethod, error) {
+	password := cred.Password
+	var signer ssh.Signer
+	var err error
+	if cred.Key != nil {
+		signer, err = cred.Key.Signer()
+	} else if isPrivateKey(password) {
+		signer, err = ssh.ParsePrivateKey([]byte(password))
+	}
+	if err != nil {
+		return []ssh.AuthMethod{}, err
+	}
+	if signer != nil {
 		return []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
+			ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+				ev.AuthMethod = "publickey"
+				return []ssh.Signer{signer}, nil
+			}),
 		}, nil
-
 	}
+
+	responder := challengeReponder(password)
 	return []ssh.AuthMethod{
-		ssh.Password(password),
-		ssh.KeyboardInteractive(challengeReponder(password)),
+		ssh.PasswordCallback(func() (string, error) {
+			ev.AuthMethod = "password"
+			return password, nil
+		}),
+		ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			ev.AuthMethod = "keyboard-interactive"
+			return responder(user, instruction, questions, echos)
+		}),
 	}, nil
 }
 
 func SSHAuthAttempt(hostport, user, password string) (string, error) {
-	authMethods, err := genAuthMethod(password)
+	result, _, err := SSHCredentialAuthAttempt(hostport, Credential{User: user, Password: password}, AuthOptions{})
+	return result, err
+}
+
+//SSHCredentialAuthAttempt is like SSHAuthAttempt but also supports key
+//credentials and returns the evidence collected after a successful login
+func SSHCredentialAuthAttempt(hostport string, cred Credential, opts AuthOptions) (string, Evidence, error) {
+	var ev Evidence
+	authMethods, err := genAuthMethod(cred, &ev)
 	if err != nil {
-		return "", err
+		return "", ev, err
 	}
 	config := &ssh.ClientConfig{
-		User:            user,
-		Auth:            authMethods,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         4 * time.Second,
-		ClientVersion:   "SSH-2.0-Go-ssh-auditor",
+		User: cred.User,
+		Auth: authMethods,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			ev.HostKeyFingerprint = ssh.FingerprintSHA256(key)
+			return nil
+		},
+		BannerCallback: func(message string) error {
+			ev.Banner += message
+			return nil
+		},
+		Timeout:       4 * time.Second,
+		ClientVersion: "SSH-2.0-Go-ssh-auditor",
 	}
 	client, err := DialWithDeadline("tcp", hostport, config)
 	if err != nil {
 		//FIXME: better way?
 		if strings.Contains(err.Error(), "unable to authenticate") {
-			return "", nil
+			return "", ev, nil
 		}
-		return "", err
+		return "", ev, err
 	}
 	//Found a potential weak password!
 	defer client.Close()
+	ev.Version = string(client.ServerVersion())
 
-	execSuccess := SSHExecAttempt(client, hostport)
+	result := "auth"
+	var execSuccess, tcpSuccess bool
+	ev.IDOutput, execSuccess = SSHExecAttempt(client, hostport)
 	if execSuccess {
-		return "exec", nil
+		if rule, fp := matchRules(opts.falsePositiveRules(), ev); fp {
+			log.Error("successful login but output matched a false positive rule", "host", hostport, "rule", rule.Name, "output", ev.IDOutput)
+			execSuccess = false
+		}
+	}
+	if execSuccess {
+		result = "exec"
+		if opts.SudoProbe {
+			ev.SudoNoPassword = SSHSudoAttempt(client, hostport)
+		}
+		if opts.HoneypotCheck {
+			ev.Uname = opts.unames.get(hostport, func() string {
+				return SSHUnameAttempt(client, hostport)
+			})
+		}
 	}
 	//If I was able to authenticate but was unable to run a command, see if port forwarding works
-
-	tcpSuccess := SSHDialAttempt(client, hostport)
-	if tcpSuccess {
-		return "tunnel", nil
+	if !execSuccess || opts.CapabilityProbes {
+		ev.TunnelDestination, tcpSuccess = tunnelAttempt(client, hostport, opts, execSuccess)
+		if tcpSuccess && !execSuccess {
+			result = "tunnel"
+		}
+	}
+	if opts.CapabilityProbes {
+		ev.Capabilities = strings.Join(probeCapabilities(client, execSuccess, tcpSuccess), ",")
 	}
-	return "auth", nil
+	ev.Privilege, ev.Severity = ClassifyPrivilege(result, ev)
+	return result, ev, nil
 }
@@ -1,23 +1,44 @@
 package sshauditor
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type SSHHost struct {
-	hostport string
-	version  string
-	keyfp    string
+	hostport      string
+	version       string
+	keyfp         string
+	anomaly       string
+	anomalyReason string
+	hostname      string
+	reverseDNS    string
+	hostKey       string
 }
 
 func keyworker(jobs <-chan ScanResult, results chan<- SSHHost) {
 	for host := range jobs {
+		//The other scanner found the port open but didn't grab the banner
+		if host.imported && host.banner == "" {
+			host = ScanPort(host.hostport)
+		}
 		if !host.success {
 			continue
 		}
 		res := SSHHost{
 			hostport: host.hostport,
 			version:  host.banner,
-			keyfp:    FetchSSHKeyFingerprint(host.hostport),
 		}
+		//Don't waste a worker waiting on a tarpit for a key it won't send
+		if reason, tarpit := detectTarpit(host); tarpit {
+			res.anomaly = AnomalyTarpit
+			res.anomalyReason = reason
+		} else {
+			start := time.Now()
+			res.keyfp, res.hostKey = FetchSSHHostKey(host.hostport)
+			observeProbe(probeFingerprint, start)
+		}
+		res.reverseDNS = reverseDNS(host.hostport)
 		results <- res
 	}
 }
@@ -3,6 +3,8 @@ package sshauditor
 import (
 	"database/sql"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
@@ -16,6 +18,11 @@ CREATE TABLE IF NOT EXISTS hosts (
 	fingerprint character varying,
 	seen_first REAL,
 	seen_last REAL,
+	anomaly character varying DEFAULT '',
+	anomaly_reason character varying DEFAULT '',
+	hostname character varying DEFAULT '',
+	reverse_dns character varying DEFAULT '',
+	host_key character varying DEFAULT '',
 
 	PRIMARY KEY (hostport)
 );
@@ -28,6 +35,18 @@ CREATE TABLE IF NOT EXISTS credentials (
 	PRIMARY KEY (user, password)
 );
 
+CREATE TABLE IF NOT EXISTS key_credentials (
+	user character varying,
+	fingerprint character varying,
+	comment character varying,
+	private_key character varying,
+	passphrase character varying,
+	certificate character varying,
+	scan_interval DEFAULT 14,
+
+	PRIMARY KEY (user, fingerprint)
+);
+
 CREATE TABLE IF NOT EXISTS host_creds (
 	hostport character varying,
 	user character varying,
@@ -39,12 +58,98 @@ CREATE TABLE IF NOT EXISTS host_creds (
 	PRIMARY KEY (hostport, user, password)
 );
 
+CREATE TABLE IF NOT EXISTS host_cred_evidence (
+	hostport character varying,
+	user character varying,
+	password character varying,
+	collected REAL,
+	auth_method character varying,
+	version character varying,
+	banner character varying,
+	host_key_fingerprint character varying,
+	id_output character varying,
+	tunnel_destination character varying,
+	sudo_nopasswd DEFAULT 0,
+	privilege character varying DEFAULT '',
+	severity character varying DEFAULT '',
+	capabilities character varying DEFAULT '',
+
+	PRIMARY KEY (hostport, user, password)
+);
+
 CREATE TABLE IF NOT EXISTS host_changes (
 	time REAL,
 	hostport character varying,
 	type character varying,
 	old character varying,
-	new character varying
+	new character varying,
+	change_id INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS expected_host_keys (
+	name character varying,
+	key_type character varying,
+	fingerprint character varying,
+	source character varying,
+
+	PRIMARY KEY (name, key_type, fingerprint)
+);
+
+CREATE TABLE IF NOT EXISTS inventory_findings (
+	hostport character varying,
+	type character varying,
+	expected character varying,
+	observed character varying,
+	seen_first REAL,
+	seen_last REAL,
+
+	PRIMARY KEY (hostport, type)
+);
+
+CREATE TABLE IF NOT EXISTS metadata (
+	target character varying,
+	owner character varying DEFAULT '',
+	department character varying DEFAULT '',
+	tags character varying DEFAULT '',
+	notes character varying DEFAULT '',
+
+	PRIMARY KEY (target)
+);
+
+CREATE TABLE IF NOT EXISTS runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	type character varying,
+	started REAL,
+	finished REAL,
+	status character varying,
+	active_hosts INTEGER DEFAULT 0,
+	vulnerabilities INTEGER DEFAULT 0,
+	duplicate_keys INTEGER DEFAULT 0,
+	pruned INTEGER DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS run_hosts (
+	run_id INTEGER,
+	hostport character varying,
+	version character varying,
+	fingerprint character varying,
+	hostname character varying,
+	reverse_dns character varying,
+	seen_first REAL,
+	seen_last REAL,
+
+	PRIMARY KEY (run_id, hostport)
+);
+
+CREATE TABLE IF NOT EXISTS run_vulns (
+	run_id INTEGER,
+	hostport character varying,
+	user character varying,
+	password character varying,
+	result character varying,
+	severity character varying,
+
+	PRIMARY KEY (run_id, hostport, user, password)
 );
 
 -- Migrate
@@ -57,18 +162,65 @@ PRAGMA writable_schema=0;
 CREATE INDEX IF NOT EXISTS host_creds_vulnerable ON host_creds (result) WHERE result != '';
 `
 
+//columnMigrations lists columns added to tables after they were first
+//released.  CREATE TABLE IF NOT EXISTS does not add them to an existing
+//database, so Init adds any that are missing.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"host_cred_evidence", "sudo_nopasswd", "DEFAULT 0"},
+	{"host_cred_evidence", "privilege", "character varying DEFAULT ''"},
+	{"host_cred_evidence", "severity", "character varying DEFAULT ''"},
+	{"host_cred_evidence", "capabilities", "character varying DEFAULT ''"},
+	{"hosts", "anomaly", "character varying DEFAULT ''"},
+	{"hosts", "anomaly_reason", "character varying DEFAULT ''"},
+	{"hosts", "hostname", "character varying DEFAULT ''"},
+	{"hosts", "reverse_dns", "character varying DEFAULT ''"},
+	{"hosts", "host_key", "character varying DEFAULT ''"},
+	{"runs", "pruned", "INTEGER DEFAULT 0"},
+	{"host_changes", "change_id", "INTEGER DEFAULT 0"},
+}
+
 type Host struct {
-	Hostport    string
-	Version     string
-	Fingerprint string
-	SeenFirst   string `db:"seen_first"`
-	SeenLast    string `db:"seen_last"`
+	Hostport      string
+	Version       string
+	Fingerprint   string
+	SeenFirst     string `db:"seen_first"`
+	SeenLast      string `db:"seen_last"`
+	Anomaly       string
+	AnomalyReason string `db:"anomaly_reason"`
+	Hostname      string
+	ReverseDNS    string   `db:"reverse_dns"`
+	HostKey       string   `db:"host_key"`
+	Metadata      Metadata `db:"-"`
+}
+
+//Names returns the name the host was discovered under and its reverse DNS,
+//without repeating the same name twice
+func (h Host) Names() string {
+	return strings.Join(h.nameList(), ", ")
+}
+
+//nameList returns the names in Names as a list
+func (h Host) nameList() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, n := range strings.Split(h.Hostname+","+h.ReverseDNS, ",") {
+		if n != "" && !seen[n] {
+			seen[n] = true
+			names = append(names, n)
+		}
+	}
+	return names
 }
 
 type Credential struct {
 	User         string
 	Password     string
-	ScanInterval int `db:"scan_interval"`
+	ScanInterval int            `db:"scan_interval"`
+	Key          *KeyCredential `db:"-" json:",omitempty"`
 }
 
 func (c Credential) String() string {
@@ -86,13 +238,15 @@ type HostCredential struct {
 
 type Vulnerability struct {
 	HostCredential
-	Host `db:"host"`
+	Host     `db:"host"`
+	Evidence Evidence `db:"evidence"`
 }
 
 type SQLiteStore struct {
 	conn    *sqlx.DB
 	tx      *sqlx.Tx
 	txDepth int
+	secrets *SecretBox
 }
 
 func NewSQLiteStore(uri string) (*SQLiteStore, error) {
@@ -109,7 +263,62 @@ func (s *SQLiteStore) Close() error {
 
 func (s *SQLiteStore) Init() error {
 	_, err := s.conn.Exec(schema)
-	return errors.Wrap(err, "Init() failed")
+	if err != nil {
+		return errors.Wrap(err, "Init() failed")
+	}
+	return errors.Wrap(s.migrateColumns(), "Init() failed")
+}
+
+func (s *SQLiteStore) migrateColumns() error {
+	for _, m := range columnMigrations {
+		var count int
+		err := s.conn.Get(&count, "SELECT count(*) FROM pragma_table_info($1) WHERE name=$2", m.table, m.column)
+		if err != nil {
+			return errors.Wrap(err, "migrateColumns")
+		}
+		if count != 0 {
+			continue
+		}
+		_, err = s.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition))
+		if err != nil {
+			return errors.Wrapf(err, "migrateColumns: adding %s.%s", m.table, m.column)
+		}
+	}
+	return nil
+}
+
+//SetSecretBox enables encryption of credential secrets written to the store.
+//Secrets stored before the key was configured are encrypted right away, since
+//results are looked up by the encrypted password and would no longer match
+//them.  It returns the number of rows encrypted.
+func (s *SQLiteStore) SetSecretBox(b *SecretBox) (int, error) {
+	s.secrets = b
+	if b == nil {
+		return 0, nil
+	}
+	updated, err := s.EncryptSecrets()
+	return updated, errors.Wrap(err, "SetSecretBox")
+}
+
+//seal encrypts a secret before it is written to the store.  Key references
+//are only a fingerprint and are left as is.
+func (s *SQLiteStore) seal(secret string) string {
+	if s.secrets == nil || isKeyReference(secret) {
+		return secret
+	}
+	return s.secrets.Encrypt(secret)
+}
+
+//unseal decrypts a secret read from the store.  Values written before a
+//secret key was configured are returned unchanged.
+func (s *SQLiteStore) unseal(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+	if s.secrets == nil {
+		return "", ErrNoSecretKey
+	}
+	return s.secrets.Decrypt(value)
 }
 
 func (s *SQLiteStore) Begin() (*sqlx.Tx, error) {
@@ -169,6 +378,7 @@ func (s *SQLiteStore) Get(dest interface{}, query string, args ...interface{}) e
 }
 
 func (s *SQLiteStore) AddCredential(c Credential) (bool, error) {
+	c.Password = s.seal(c.Password)
 	res, err := s.Exec(
 		"INSERT OR IGNORE INTO credentials (user, password, scan_interval) VALUES ($1, $2, $3)",
 		c.User, c.Password, c.ScanInterval)
@@ -187,6 +397,62 @@ func (s *SQLiteStore) AddCredential(c Credential) (bool, error) {
 	return added, errors.Wrap(err, "AddCredential")
 }
 
+func (s *SQLiteStore) AddKeyCredential(k KeyCredential) (bool, error) {
+	k.PrivateKey = s.seal(k.PrivateKey)
+	k.Passphrase = s.seal(k.Passphrase)
+	res, err := s.Exec(
+		`INSERT OR IGNORE INTO key_credentials (user, fingerprint, comment, private_key, passphrase, certificate, scan_interval)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		k.User, k.Fingerprint, k.Comment, k.PrivateKey, k.Passphrase, k.Certificate, k.ScanInterval)
+	if err != nil {
+		return false, errors.Wrap(err, "AddKeyCredential")
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "AddKeyCredential")
+	}
+	added := affected == 1
+	_, err = s.Exec(
+		`UPDATE key_credentials SET comment=$1, private_key=$2, passphrase=$3, certificate=$4, scan_interval=$5
+			WHERE user=$6 AND fingerprint=$7`,
+		k.Comment, k.PrivateKey, k.Passphrase, k.Certificate, k.ScanInterval, k.User, k.Fingerprint)
+
+	return added, errors.Wrap(err, "AddKeyCredential")
+}
+
+func (s *SQLiteStore) GetAllKeyCreds() ([]KeyCredential, error) {
+	keys := []KeyCredential{}
+	err := s.Select(&keys, "SELECT * from key_credentials")
+	if err != nil {
+		return keys, errors.Wrap(err, "GetAllKeyCreds")
+	}
+	for i := range keys {
+		keys[i].PrivateKey, err = s.unseal(keys[i].PrivateKey)
+		if err != nil {
+			return keys, errors.Wrap(err, "GetAllKeyCreds")
+		}
+		keys[i].Passphrase, err = s.unseal(keys[i].Passphrase)
+		if err != nil {
+			return keys, errors.Wrap(err, "GetAllKeyCreds")
+		}
+	}
+	return keys, nil
+}
+
+//getKeyCredsByReference returns all key credentials indexed by the value
+//stored in the host_creds password column
+func (s *SQLiteStore) getKeyCredsByReference() (map[string]KeyCredential, error) {
+	keyMap := make(map[string]KeyCredential)
+	keys, err := s.GetAllKeyCreds()
+	if err != nil {
+		return keyMap, errors.Wrap(err, "getKeyCredsByReference")
+	}
+	for _, k := range keys {
+		keyMap[k.reference()] = k
+	}
+	return keyMap, nil
+}
+
 func (s *SQLiteStore) getKnownHosts() (map[string]Host, error) {
 	hostList := []Host{}
 
@@ -217,7 +483,15 @@ func (s *SQLiteStore) ResetCreds() error {
 	if err != nil {
 		return err
 	}
+	_, err = s.Exec("DELETE from host_cred_evidence")
+	if err != nil {
+		return err
+	}
 	_, err = s.Exec("DELETE from credentials")
+	if err != nil {
+		return err
+	}
+	_, err = s.Exec("DELETE from key_credentials")
 	return err
 }
 
@@ -227,9 +501,9 @@ func (s *SQLiteStore) addOrUpdateHost(h SSHHost) error {
 		return errors.Wrap(err, "addOrUpdateHost")
 	}
 	res, err := s.Exec(
-		`UPDATE hosts SET version=$1,fingerprint=$2,seen_last=datetime('now', 'localtime')
-			WHERE hostport=$3`,
-		h.version, h.keyfp, h.hostport)
+		`UPDATE hosts SET version=$1,fingerprint=$2,seen_last=datetime('now', 'localtime'),anomaly='',anomaly_reason='',host_key=$3
+			WHERE hostport=$4`,
+		h.version, h.keyfp, h.hostKey, h.hostport)
 	if err != nil {
 		return err
 	}
@@ -238,12 +512,30 @@ func (s *SQLiteStore) addOrUpdateHost(h SSHHost) error {
 		return errors.Wrap(err, "addOrUpdateHost")
 	}
 	_, err = s.Exec(
-		`INSERT INTO hosts (hostport, version, fingerprint, seen_first, seen_last) VALUES
-			($1, $2, $3, datetime('now', 'localtime'), datetime('now', 'localtime'))`,
-		h.hostport, h.version, h.keyfp)
+		`INSERT INTO hosts (hostport, version, fingerprint, seen_first, seen_last, hostname, reverse_dns, host_key) VALUES
+			($1, $2, $3, datetime('now', 'localtime'), datetime('now', 'localtime'), $4, $5, $6)`,
+		h.hostport, h.version, h.keyfp, h.hostname, h.reverseDNS, h.hostKey)
 	return err
 }
 
+//setHostKey records the full host key of a host whose fingerprint is
+//already known, for hosts discovered before keys were stored
+func (s *SQLiteStore) setHostKey(h SSHHost) error {
+	_, err := s.Exec("UPDATE hosts SET host_key=$1 WHERE hostport=$2", h.hostKey, h.hostport)
+	return errors.Wrap(err, "setHostKey")
+}
+
+//setHostNames records the name a host was discovered under and its reverse
+//DNS.  An empty hostname doesn't clear one that was set by an earlier
+//discovery, since a host listed by name may later be found by a subnet scan.
+func (s *SQLiteStore) setHostNames(h SSHHost) error {
+	_, err := s.Exec(
+		`UPDATE hosts SET hostname=CASE WHEN $1 != '' THEN $1 ELSE hostname END, reverse_dns=$2
+			WHERE hostport=$3`,
+		h.hostname, h.reverseDNS, h.hostport)
+	return errors.Wrap(err, "setHostNames")
+}
+
 func (s *SQLiteStore) setLastSeen(h SSHHost) error {
 	_, err := s.Exec(
 		"UPDATE hosts SET seen_last=datetime('now', 'localtime') WHERE hostport=$1",
@@ -251,31 +543,150 @@ func (s *SQLiteStore) setLastSeen(h SSHHost) error {
 	return errors.Wrap(err, "setLastSeen")
 }
 
-func (s *SQLiteStore) addHostChange(h SSHHost, changeType, old, new string) error {
-	q := `INSERT INTO host_changes (time, hostport, type, old, new) VALUES
-			(datetime('now', 'localtime'), $1, $2, $3, $4)`
-	_, err := s.Exec(q, h.hostport, changeType, old, new)
+//setHostAnomaly marks a host as behaving in a way that makes its brute force
+//results meaningless
+func (s *SQLiteStore) setHostAnomaly(hostport, anomaly, reason string) error {
+	_, err := s.Exec("UPDATE hosts SET anomaly=$1, anomaly_reason=$2 WHERE hostport=$3", anomaly, reason, hostport)
+	return errors.Wrap(err, "setHostAnomaly")
+}
+
+//clearHostAnomaly removes the anomaly from a host if it is currently one of
+//anomalies
+func (s *SQLiteStore) clearHostAnomaly(hostport string, anomalies ...string) error {
+	for _, anomaly := range anomalies {
+		_, err := s.Exec("UPDATE hosts SET anomaly='', anomaly_reason='' WHERE hostport=$1 AND anomaly=$2", hostport, anomaly)
+		if err != nil {
+			return errors.Wrap(err, "clearHostAnomaly")
+		}
+	}
+	return nil
+}
+
+//GetAnomalousHosts returns the hosts that have an anomaly set
+func (s *SQLiteStore) GetAnomalousHosts() ([]Host, error) {
+	hostList := []Host{}
+	err := s.Select(&hostList, "SELECT * FROM hosts WHERE anomaly != '' ORDER BY hostport")
+	return hostList, errors.Wrap(err, "GetAnomalousHosts")
+}
+
+//hostChangeID identifies the rows written for one change to a host
+type hostChangeID struct {
+	ID   int64
+	Time string
+}
+
+func (s *SQLiteStore) addHostChange(h SSHHost, change hostChangeID, changeType, old, new string) error {
+	q := `INSERT INTO host_changes (time, hostport, type, old, new, change_id) VALUES
+			($1, $2, $3, $4, $5, $6)`
+	_, err := s.Exec(q, change.Time, h.hostport, changeType, old, new, change.ID)
 	return errors.Wrap(err, "addHostChanges failed")
 }
 
 func (s *SQLiteStore) addHostChanges(new SSHHost, old Host) error {
-	var err error
+	if old.Fingerprint == new.keyfp && old.Version == new.version {
+		return nil
+	}
+	//The fingerprint and version rows share an id and a time so they are
+	//read back as a single change
+	var change hostChangeID
+	err := s.Get(&change, `SELECT coalesce(max(change_id), 0) + 1 AS id,
+		datetime('now', 'localtime') AS time FROM host_changes`)
+	if err != nil {
+		return errors.Wrap(err, "addHostChange")
+	}
 	if old.Fingerprint != new.keyfp {
-		err = s.addHostChange(new, "fingerprint", old.Fingerprint, new.keyfp)
+		err = s.addHostChange(new, change, "fingerprint", old.Fingerprint, new.keyfp)
 		if err != nil {
 			return errors.Wrap(err, "addHostChange")
 		}
 	}
 	if old.Version != new.version {
-		err = s.addHostChange(new, "version", old.Version, new.version)
+		err = s.addHostChange(new, change, "version", old.Version, new.version)
 	}
 	return errors.Wrap(err, "addHostChange")
 }
 
+//GetHostChanges returns the changes to hosts in the last maxAgeDays days,
+//oldest first.  The fingerprint and version rows written by one discovery
+//are combined into a single HostChange.  Rows written before changes had an
+//id are combined by time instead.
+func (s *SQLiteStore) GetHostChanges(maxAgeDays int) ([]HostChange, error) {
+	dayInterval := fmt.Sprintf("-%d day", maxAgeDays)
+	changes, err := s.selectHostChanges(`time >= datetime('now', 'localtime', $1)`, dayInterval)
+	return changes, errors.Wrap(err, "GetHostChanges")
+}
+
+//getHostChangesSince returns the changes to hosts after t
+func (s *SQLiteStore) getHostChangesSince(t string) ([]HostChange, error) {
+	changes, err := s.selectHostChanges(`time > $1`, t)
+	return changes, errors.Wrap(err, "getHostChangesSince")
+}
+
+func (s *SQLiteStore) selectHostChanges(where string, arg interface{}) ([]HostChange, error) {
+	var rows []struct {
+		Time     string
+		Hostport string
+		Type     string
+		Old      string
+		New      string
+		ChangeID int64 `db:"change_id"`
+	}
+	var changes []HostChange
+	err := s.Select(&rows, `SELECT time, hostport, type, old, new, change_id FROM host_changes
+		WHERE `+where+` ORDER BY time, hostport, change_id`, arg)
+	if err != nil {
+		return changes, err
+	}
+	byID := make(map[int64]int)
+	var lastID int64
+	for _, r := range rows {
+		n := len(changes)
+		i, same := byID[r.ChangeID]
+		if r.ChangeID == 0 {
+			i = n - 1
+			same = n != 0 && lastID == 0 && changes[i].Time == r.Time && changes[i].Hostport == r.Hostport
+		}
+		if !same {
+			changes = append(changes, HostChange{Time: r.Time, Hostport: r.Hostport})
+			i = n
+			if r.ChangeID != 0 {
+				byID[r.ChangeID] = i
+			}
+		}
+		lastID = r.ChangeID
+		c := &changes[i]
+		switch r.Type {
+		case "fingerprint":
+			c.OldFingerprint, c.NewFingerprint = r.Old, r.New
+		case "version":
+			c.OldVersion, c.NewVersion = r.Old, r.New
+		}
+	}
+	//A change row only exists for what changed, so a missing fingerprint or
+	//version row means it stayed the same
+	var classified []HostChange
+	for _, c := range changes {
+		c.classify()
+		if c.Classification != "" {
+			classified = append(classified, c)
+		}
+	}
+	return classified, nil
+}
+
 func (s *SQLiteStore) GetAllCreds() ([]Credential, error) {
 	credentials := []Credential{}
 	err := s.Select(&credentials, "SELECT user, password, scan_interval from credentials")
-	return credentials, errors.Wrap(err, "getAllCreds")
+	if err != nil {
+		return credentials, errors.Wrap(err, "getAllCreds")
+	}
+	for i := range credentials {
+		credentials[i].Password, err = s.unseal(credentials[i].Password)
+		if err != nil {
+			return credentials, errors.Wrap(err, "getAllCreds")
+		}
+	}
+	return credentials, nil
 }
 
 func (s *SQLiteStore) initHostCreds() (int, error) {
@@ -288,6 +699,13 @@ func (s *SQLiteStore) initHostCreds() (int, error) {
 	if err != nil {
 		return 0, errors.Wrap(err, "initHostCreds")
 	}
+	keys, err := s.GetAllKeyCreds()
+	if err != nil {
+		return 0, errors.Wrap(err, "initHostCreds")
+	}
+	for _, k := range keys {
+		creds = append(creds, k.credential())
+	}
 
 	knownHosts, err := s.GetActiveHosts(7)
 	if err != nil {
@@ -309,7 +727,7 @@ func (s *SQLiteStore) initHostCredsForHost(creds []Credential, h Host) (int, err
 	for _, c := range creds {
 		res, err := s.Exec(`INSERT OR IGNORE INTO host_creds (hostport, user, password, last_tested, result, scan_interval) VALUES
 			($1, $2, $3, 0, '', $4)`,
-			h.Hostport, c.User, c.Password, c.ScanInterval)
+			h.Hostport, c.User, s.seal(c.Password), c.ScanInterval)
 		if err != nil {
 			return inserted, errors.Wrap(err, "initHostCredsForHost")
 		}
@@ -327,15 +745,32 @@ func (s *SQLiteStore) getScanQueueHelper(query string) ([]ScanRequest, error) {
 	if err != nil {
 		return requests, errors.Wrap(err, "getScanQueueHelper")
 	}
+	keys, err := s.getKeyCredsByReference()
+	if err != nil {
+		return requests, errors.Wrap(err, "getScanQueueHelper")
+	}
 
 	for _, hc := range credentials {
+		password, err := s.unseal(hc.Password)
+		if err != nil {
+			return requests, errors.Wrap(err, "getScanQueueHelper")
+		}
+		cred := Credential{User: hc.User, Password: password}
+		if isKeyReference(hc.Password) {
+			k, ok := keys[hc.Password]
+			if !ok {
+				//The key was removed after this host was queued
+				continue
+			}
+			cred.Key = &k
+		}
 		sr := requestMap[hc.Hostport]
 		if sr == nil {
 			sr = &ScanRequest{
 				hostport: hc.Hostport,
 			}
 		}
-		sr.credentials = append(sr.credentials, Credential{User: hc.User, Password: hc.Password})
+		sr.credentials = append(sr.credentials, cred)
 		requestMap[hc.Hostport] = sr
 	}
 
@@ -369,6 +804,18 @@ func (s *SQLiteStore) getRescanQueue() ([]ScanRequest, error) {
 	return s.getScanQueueHelper(q)
 }
 
+//getBruteResult returns the stored result for the host and credential of br,
+//which is empty if the credential did not work or was never tested
+func (s *SQLiteStore) getBruteResult(br BruteForceResult) (string, error) {
+	var result string
+	err := s.Get(&result, `SELECT result FROM host_creds WHERE hostport=$1 AND user=$2 AND password=$3`,
+		br.hostport, br.cred.User, s.seal(br.cred.Password))
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return result, errors.Wrap(err, "getBruteResult")
+}
+
 func (s *SQLiteStore) updateBruteResult(br BruteForceResult) error {
 	if br.err != nil {
 		//If this BruteForceResult was an error.. as in, not a positive or
@@ -376,12 +823,26 @@ func (s *SQLiteStore) updateBruteResult(br BruteForceResult) error {
 		//that the credential does or does not work.
 		return nil
 	}
+	password := s.seal(br.cred.Password)
 	_, err := s.Exec(`UPDATE host_creds set last_tested=datetime('now', 'localtime'), result=$1
 		WHERE hostport=$2 AND user=$3 AND password=$4`,
-		br.result, br.hostport, br.cred.User, br.cred.Password)
+		br.result, br.hostport, br.cred.User, password)
 	if err != nil {
 		return errors.Wrap(err, "updateBruteResult")
 	}
+	if br.result != "" {
+		ev := br.evidence
+		_, err = s.Exec(`INSERT OR REPLACE INTO host_cred_evidence
+			(hostport, user, password, collected, auth_method, version, banner, host_key_fingerprint, id_output, tunnel_destination,
+				sudo_nopasswd, privilege, severity, capabilities)
+			VALUES ($1, $2, $3, datetime('now', 'localtime'), $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			br.hostport, br.cred.User, password,
+			ev.AuthMethod, ev.Version, ev.Banner, ev.HostKeyFingerprint, ev.IDOutput, ev.TunnelDestination,
+			ev.SudoNoPassword, ev.Privilege, ev.Severity, ev.Capabilities)
+		if err != nil {
+			return errors.Wrap(err, "updateBruteResult")
+		}
+	}
 	//Also update the seen_last field on the hosts table, since a non-err
 	//BruteForceResult means the system was reachable.
 	_, err = s.Exec(
@@ -395,15 +856,49 @@ func (s *SQLiteStore) GetVulnerabilities() ([]Vulnerability, error) {
 	q := `select
 			hc.hostport, hc.user, hc.password, hc.result, hc.last_tested,
 			h.version "host.version", h.hostport "host.hostport",
-			h.seen_first "host.seen_first", h.seen_last "host.seen_last", h.fingerprint "host.fingerprint"
+			h.seen_first "host.seen_first", h.seen_last "host.seen_last", h.fingerprint "host.fingerprint",
+			h.anomaly "host.anomaly",
+			h.hostname "host.hostname", h.reverse_dns "host.reverse_dns",
+			coalesce(e.collected, '') "evidence.collected",
+			coalesce(e.auth_method, '') "evidence.auth_method",
+			coalesce(e.version, '') "evidence.version",
+			coalesce(e.banner, '') "evidence.banner",
+			coalesce(e.host_key_fingerprint, '') "evidence.host_key_fingerprint",
+			coalesce(e.id_output, '') "evidence.id_output",
+			coalesce(e.tunnel_destination, '') "evidence.tunnel_destination",
+			coalesce(e.sudo_nopasswd, 0) "evidence.sudo_nopasswd",
+			coalesce(e.privilege, '') "evidence.privilege",
+			coalesce(e.severity, '') "evidence.severity",
+			coalesce(e.capabilities, '') "evidence.capabilities"
 		from
-			host_creds hc, hosts h
+			host_creds hc
+			join hosts h on h.hostport = hc.hostport
+			left join host_cred_evidence e on
+				e.hostport = hc.hostport and e.user = hc.user and e.password = hc.password
 		where
-			h.hostport = hc.hostport
-		and result!='' order by last_tested asc`
+			result!='' and h.anomaly = '' order by last_tested asc`
 
 	err := s.Select(&creds, q)
-	return creds, errors.Wrap(err, "GetVulnerabilities")
+	if err != nil {
+		return creds, errors.Wrap(err, "GetVulnerabilities")
+	}
+	for i := range creds {
+		password, err := s.unseal(creds[i].Password)
+		if err != nil {
+			return creds, errors.Wrap(err, "GetVulnerabilities")
+		}
+		creds[i].Password = displayPassword(password)
+		//Findings from before privilege classification can still be
+		//classified by their result
+		if creds[i].Evidence.Severity == "" {
+			creds[i].Evidence.Privilege, creds[i].Evidence.Severity = ClassifyPrivilege(creds[i].Result, creds[i].Evidence)
+		}
+	}
+	//Most severe first, then oldest first
+	sort.SliceStable(creds, func(i, j int) bool {
+		return SeverityRank(creds[i].Evidence.Severity) < SeverityRank(creds[j].Evidence.Severity)
+	})
+	return creds, nil
 }
 
 //GetActiveHosts returns a list of hosts seen at most maxAgeDays ago
@@ -415,6 +910,32 @@ func (s *SQLiteStore) GetActiveHosts(maxAgeDays int) ([]Host, error) {
 	return hostList, errors.Wrap(err, "GetActiveHosts")
 }
 
+//countActiveHosts returns the number of hosts seen at most maxAgeDays ago
+func (s *SQLiteStore) countActiveHosts(maxAgeDays int) (int, error) {
+	var cnt int
+	dayInterval := fmt.Sprintf("-%d day", maxAgeDays)
+	err := s.Get(&cnt, `SELECT count(*) FROM hosts WHERE seen_last >= datetime('now', 'localtime', $1)`, dayInterval)
+	return cnt, errors.Wrap(err, "countActiveHosts")
+}
+
+//countVulnerabilitiesByResult returns the number of vulnerabilities of each
+//result type, leaving out anomalous hosts like GetVulnerabilities does
+func (s *SQLiteStore) countVulnerabilitiesByResult() (map[string]int, error) {
+	var rows []struct {
+		Result string
+		Count  int
+	}
+	q := `select hc.result, count(*) count from host_creds hc
+		join hosts h on h.hostport = hc.hostport
+		where hc.result != '' and h.anomaly = '' group by hc.result`
+	err := s.Select(&rows, q)
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[r.Result] = r.Count
+	}
+	return counts, errors.Wrap(err, "countVulnerabilitiesByResult")
+}
+
 func (s *SQLiteStore) DeleteHost(hostport string) error {
 	s.Begin()
 	defer s.Commit()
@@ -423,5 +944,345 @@ func (s *SQLiteStore) DeleteHost(hostport string) error {
 		return err
 	}
 	_, err = s.Exec("DELETE FROM host_creds where hostport=$1", hostport)
+	if err != nil {
+		return err
+	}
+	_, err = s.Exec("DELETE FROM host_cred_evidence where hostport=$1", hostport)
+	if err != nil {
+		return err
+	}
+	_, err = s.Exec("DELETE FROM inventory_findings where hostport=$1", hostport)
 	return err
 }
+
+//AddExpectedKeys stores host keys from an authoritative source.  If replace
+//is true the keys previously loaded from the same source are removed first.
+func (s *SQLiteStore) AddExpectedKeys(keys []ExpectedKey, source string, replace bool) error {
+	_, err := s.Begin()
+	if err != nil {
+		return errors.Wrap(err, "AddExpectedKeys")
+	}
+	defer s.Commit()
+	if replace {
+		_, err = s.Exec("DELETE FROM expected_host_keys WHERE source=$1", source)
+		if err != nil {
+			return errors.Wrap(err, "AddExpectedKeys")
+		}
+	}
+	for _, k := range keys {
+		_, err = s.Exec(`INSERT OR REPLACE INTO expected_host_keys (name, key_type, fingerprint, source)
+			VALUES ($1, $2, $3, $4)`, k.Name, k.KeyType, k.Fingerprint, source)
+		if err != nil {
+			return errors.Wrap(err, "AddExpectedKeys")
+		}
+	}
+	return nil
+}
+
+//GetExpectedKeys returns every expected host key
+func (s *SQLiteStore) GetExpectedKeys() ([]ExpectedKey, error) {
+	keys := []ExpectedKey{}
+	err := s.Select(&keys, "SELECT * FROM expected_host_keys ORDER BY name")
+	return keys, errors.Wrap(err, "GetExpectedKeys")
+}
+
+//setInventoryFinding records a finding for a host, replacing any other
+//inventory finding for it
+func (s *SQLiteStore) setInventoryFinding(hostport, findingType, expected, observed string) error {
+	_, err := s.Exec("DELETE FROM inventory_findings WHERE hostport=$1 AND type!=$2", hostport, findingType)
+	if err != nil {
+		return errors.Wrap(err, "setInventoryFinding")
+	}
+	res, err := s.Exec(`UPDATE inventory_findings SET expected=$1, observed=$2, seen_last=datetime('now', 'localtime')
+		WHERE hostport=$3 AND type=$4`, expected, observed, hostport, findingType)
+	if err != nil {
+		return errors.Wrap(err, "setInventoryFinding")
+	}
+	if rows, _ := res.RowsAffected(); rows != 0 {
+		return nil
+	}
+	_, err = s.Exec(`INSERT INTO inventory_findings (hostport, type, expected, observed, seen_first, seen_last)
+		VALUES ($1, $2, $3, $4, datetime('now', 'localtime'), datetime('now', 'localtime'))`,
+		hostport, findingType, expected, observed)
+	return errors.Wrap(err, "setInventoryFinding")
+}
+
+//clearInventoryFindings removes the findings for a host that now matches the
+//inventory
+func (s *SQLiteStore) clearInventoryFindings(hostport string) error {
+	_, err := s.Exec("DELETE FROM inventory_findings WHERE hostport=$1", hostport)
+	return errors.Wrap(err, "clearInventoryFindings")
+}
+
+//GetInventoryFindings returns the inventory findings for hosts that are
+//still present, mismatches first
+func (s *SQLiteStore) GetInventoryFindings() ([]InventoryFinding, error) {
+	findings := []InventoryFinding{}
+	err := s.Select(&findings, `SELECT f.* FROM inventory_findings f JOIN hosts h ON h.hostport = f.hostport
+		ORDER BY f.type = 'unknown host', f.hostport`)
+	return findings, errors.Wrap(err, "GetInventoryFindings")
+}
+
+//SetMetadata stores the metadata for m.Target, replacing what was there
+func (s *SQLiteStore) SetMetadata(m Metadata) error {
+	_, err := s.Exec(`INSERT OR REPLACE INTO metadata (target, owner, department, tags, notes)
+		VALUES ($1, $2, $3, $4, $5)`, m.Target, m.Owner, m.Department, m.Tags, m.Notes)
+	return errors.Wrap(err, "SetMetadata")
+}
+
+//ImportMetadata stores a list of metadata in one transaction
+func (s *SQLiteStore) ImportMetadata(all []Metadata) error {
+	_, err := s.Begin()
+	if err != nil {
+		return errors.Wrap(err, "ImportMetadata")
+	}
+	defer s.Commit()
+	for _, m := range all {
+		if err := s.SetMetadata(m); err != nil {
+			return errors.Wrap(err, "ImportMetadata")
+		}
+	}
+	return nil
+}
+
+//GetMetadata returns the metadata stored for target itself.  A target
+//without metadata returns an empty Metadata for that target.
+func (s *SQLiteStore) GetMetadata(target string) (Metadata, error) {
+	m := Metadata{Target: target}
+	err := s.Get(&m, "SELECT * FROM metadata WHERE target=$1", target)
+	if err == sql.ErrNoRows {
+		return m, nil
+	}
+	return m, errors.Wrap(err, "GetMetadata")
+}
+
+//GetAllMetadata returns the metadata for every target
+func (s *SQLiteStore) GetAllMetadata() ([]Metadata, error) {
+	all := []Metadata{}
+	err := s.Select(&all, "SELECT * FROM metadata ORDER BY target")
+	return all, errors.Wrap(err, "GetAllMetadata")
+}
+
+//DeleteMetadata removes the metadata stored for target
+func (s *SQLiteStore) DeleteMetadata(target string) error {
+	_, err := s.Exec("DELETE FROM metadata WHERE target=$1", target)
+	return errors.Wrap(err, "DeleteMetadata")
+}
+
+//snapshotHostsQuery selects the active hosts as they are saved for a run
+const snapshotHostsQuery = `SELECT hostport, version, fingerprint, hostname, reverse_dns, seen_first, seen_last
+	FROM hosts WHERE seen_last >= datetime('now', 'localtime', '-2 day')`
+
+//snapshotVulnsQuery selects the vulnerabilities as they are saved for a run
+const snapshotVulnsQuery = `SELECT hc.hostport, hc.user, hc.password, hc.result, coalesce(e.severity, '') severity
+	FROM host_creds hc
+	JOIN hosts h ON h.hostport = hc.hostport
+	LEFT JOIN host_cred_evidence e ON
+		e.hostport = hc.hostport AND e.user = hc.user AND e.password = hc.password
+	WHERE hc.result != '' AND h.anomaly = ''`
+
+//addRun records a finished run.  Successful runs also save the active hosts
+//and vulnerabilities so later reports can be compared against them.
+//Passwords are saved like host_creds saves them, encrypted when a secret key
+//is configured.
+func (s *SQLiteStore) addRun(r Run) (int64, error) {
+	_, err := s.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "addRun")
+	}
+	defer s.Commit()
+	res, err := s.Exec(`INSERT INTO runs (type, started, finished, status)
+		VALUES ($1, $2, datetime('now', 'localtime'), $3)`, r.Type, r.Started, r.Status)
+	if err != nil {
+		return 0, errors.Wrap(err, "addRun")
+	}
+	id, err := res.LastInsertId()
+	if err != nil || r.Status != RunSuccess {
+		return id, errors.Wrap(err, "addRun")
+	}
+	_, err = s.Exec(`INSERT INTO run_hosts (run_id, hostport, version, fingerprint, hostname, reverse_dns, seen_first, seen_last)
+		SELECT $1, * FROM (`+snapshotHostsQuery+`)`, id)
+	if err != nil {
+		return id, errors.Wrap(err, "addRun")
+	}
+	vulns, err := s.getRunVulnerabilities(0)
+	if err != nil {
+		return id, errors.Wrap(err, "addRun")
+	}
+	for _, v := range vulns {
+		_, err = s.Exec(`INSERT OR REPLACE INTO run_vulns (run_id, hostport, user, password, result, severity)
+			VALUES ($1, $2, $3, $4, $5, $6)`, id, v.Hostport, v.User, s.seal(v.Password), v.Result, v.Severity)
+		if err != nil {
+			return id, errors.Wrap(err, "addRun")
+		}
+	}
+	_, err = s.Exec(`UPDATE runs SET
+		active_hosts=(SELECT count(*) FROM run_hosts WHERE run_id=$1),
+		vulnerabilities=(SELECT count(*) FROM run_vulns WHERE run_id=$1),
+		duplicate_keys=(SELECT count(*) FROM
+			(SELECT fingerprint FROM run_hosts WHERE run_id=$1 GROUP BY fingerprint HAVING count(*) > 1))
+		WHERE id=$1`, id)
+	return id, errors.Wrap(err, "addRun")
+}
+
+//GetRuns returns every recorded run, oldest first
+func (s *SQLiteStore) GetRuns() ([]Run, error) {
+	runs := []Run{}
+	err := s.Select(&runs, "SELECT * FROM runs ORDER BY id")
+	return runs, errors.Wrap(err, "GetRuns")
+}
+
+//GetRun returns the run with the given id
+func (s *SQLiteStore) GetRun(id int64) (Run, error) {
+	var r Run
+	err := s.Get(&r, "SELECT * FROM runs WHERE id=$1", id)
+	if err == sql.ErrNoRows {
+		return r, fmt.Errorf("no run with id %d", id)
+	}
+	return r, errors.Wrap(err, "GetRun")
+}
+
+//getSuccessfulRunBefore returns the last successful run that finished at or
+//before t, or the first one after it if there is none.
+func (s *SQLiteStore) getSuccessfulRunBefore(t string) (Run, error) {
+	var r Run
+	err := s.Get(&r, `SELECT * FROM runs WHERE status=$1 AND pruned=0 AND finished <= $2 ORDER BY finished DESC, id DESC LIMIT 1`, RunSuccess, t)
+	if err == sql.ErrNoRows {
+		err = s.Get(&r, `SELECT * FROM runs WHERE status=$1 AND pruned=0 AND finished > $2 ORDER BY finished, id LIMIT 1`, RunSuccess, t)
+	}
+	if err == sql.ErrNoRows {
+		return r, fmt.Errorf("no successful runs recorded since %s", t)
+	}
+	return r, errors.Wrap(err, "getSuccessfulRunBefore")
+}
+
+//PruneRuns deletes the saved hosts and vulnerabilities of old successful
+//runs: those that finished before olderThan, if it is set, and those that
+//are not among the newest keep, if keep is more than 0.  The runs and their
+//counts are kept for the trend in reports.  It returns the number of runs
+//that were pruned.
+func (s *SQLiteStore) PruneRuns(keep int, olderThan string) (int, error) {
+	_, err := s.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "PruneRuns")
+	}
+	defer s.Commit()
+	ids := []int64{}
+	err = s.Select(&ids, `SELECT id FROM runs WHERE status=$1 AND pruned=0 AND (
+		($2 != '' AND finished < $2) OR
+		($3 > 0 AND id NOT IN (SELECT id FROM runs WHERE status=$1 ORDER BY id DESC LIMIT $3)))`,
+		RunSuccess, olderThan, keep)
+	if err != nil {
+		return 0, errors.Wrap(err, "PruneRuns")
+	}
+	for _, id := range ids {
+		for _, q := range []string{
+			"DELETE FROM run_hosts WHERE run_id=$1",
+			"DELETE FROM run_vulns WHERE run_id=$1",
+			"UPDATE runs SET pruned=1 WHERE id=$1",
+		} {
+			if _, err := s.Exec(q, id); err != nil {
+				return 0, errors.Wrap(err, "PruneRuns")
+			}
+		}
+	}
+	return len(ids), nil
+}
+
+//getRunHosts returns the active hosts saved for a run, or the current ones if
+//runID is 0
+func (s *SQLiteStore) getRunHosts(runID int64) ([]Host, error) {
+	hosts := []Host{}
+	var err error
+	if runID == 0 {
+		err = s.Select(&hosts, snapshotHostsQuery)
+	} else {
+		err = s.Select(&hosts, `SELECT hostport, version, fingerprint, hostname, reverse_dns, seen_first, seen_last
+			FROM run_hosts WHERE run_id=$1`, runID)
+	}
+	return hosts, errors.Wrap(err, "getRunHosts")
+}
+
+//getRunVulnerabilities returns the vulnerabilities saved for a run, or the
+//current ones if runID is 0.  Passwords are decrypted, callers have to
+//redact them before showing them.
+func (s *SQLiteStore) getRunVulnerabilities(runID int64) ([]RunVulnerability, error) {
+	vulns := []RunVulnerability{}
+	var err error
+	if runID != 0 {
+		err = s.Select(&vulns, `SELECT hostport, user, password, result, severity FROM run_vulns WHERE run_id=$1`, runID)
+	} else {
+		err = s.Select(&vulns, snapshotVulnsQuery)
+	}
+	if err != nil {
+		return vulns, errors.Wrap(err, "getRunVulnerabilities")
+	}
+	for i := range vulns {
+		vulns[i].Password, err = s.unseal(vulns[i].Password)
+		if err != nil {
+			return vulns, errors.Wrap(err, "getRunVulnerabilities")
+		}
+		if vulns[i].Severity == "" {
+			_, vulns[i].Severity = ClassifyPrivilege(vulns[i].Result, Evidence{})
+		}
+	}
+	return vulns, nil
+}
+
+//EncryptSecrets encrypts any credential secrets that were stored before a
+//secret key was configured.  It returns the number of rows updated.
+func (s *SQLiteStore) EncryptSecrets() (int, error) {
+	if s.secrets == nil {
+		return 0, ErrNoSecretKey
+	}
+	_, err := s.Begin()
+	if err != nil {
+		return 0, errors.Wrap(err, "EncryptSecrets")
+	}
+	defer s.Commit()
+
+	updated := 0
+	for _, table := range []string{"credentials", "host_creds", "host_cred_evidence"} {
+		passwords := []string{}
+		err = s.Select(&passwords, fmt.Sprintf("SELECT DISTINCT password FROM %s", table))
+		if err != nil {
+			return updated, errors.Wrap(err, "EncryptSecrets")
+		}
+		for _, p := range passwords {
+			if isEncrypted(p) || isKeyReference(p) {
+				continue
+			}
+			//A row with the encrypted password may already exist if the
+			//same credential was added again after a key was configured.
+			//That row is kept and the plaintext one is removed.
+			res, err := s.Exec(fmt.Sprintf("UPDATE OR IGNORE %s SET password=$1 WHERE password=$2", table), s.seal(p), p)
+			if err != nil {
+				return updated, errors.Wrap(err, "EncryptSecrets")
+			}
+			rows, _ := res.RowsAffected()
+			updated += int(rows)
+			_, err = s.Exec(fmt.Sprintf("DELETE FROM %s WHERE password=$1", table), p)
+			if err != nil {
+				return updated, errors.Wrap(err, "EncryptSecrets")
+			}
+		}
+	}
+
+	keys := []KeyCredential{}
+	err = s.Select(&keys, "SELECT * from key_credentials")
+	if err != nil {
+		return updated, errors.Wrap(err, "EncryptSecrets")
+	}
+	for _, k := range keys {
+		if isEncrypted(k.PrivateKey) {
+			continue
+		}
+		_, err = s.Exec("UPDATE key_credentials SET private_key=$1, passphrase=$2 WHERE user=$3 AND fingerprint=$4",
+			s.seal(k.PrivateKey), s.seal(k.Passphrase), k.User, k.Fingerprint)
+		if err != nil {
+			return updated, errors.Wrap(err, "EncryptSecrets")
+		}
+		updated++
+	}
+	return updated, nil
+}
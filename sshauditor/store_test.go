@@ -83,3 +83,297 @@ func TestAddAndDeleteHost(t *testing.T) {
 		t.Fatalf("Expected 0 hosts, got %d", len(knownHosts))
 	}
 }
+
+func TestKeyCredentialQueue(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	err = s.Init()
+	check(err)
+
+	k, err := NewKeyCredential("test", readTestKey(t), nil, nil, "test key", 1)
+	check(err)
+	added, err := s.AddKeyCredential(k)
+	check(err)
+	if added != true {
+		t.Errorf("Expected added to be true")
+	}
+
+	check(s.addOrUpdateHost(SSHHost{
+		hostport: "192.168.1.1:22",
+		version:  "whatever",
+		keyfp:    "whatever",
+	}))
+	queued, err := s.initHostCreds()
+	check(err)
+	if queued != 1 {
+		t.Fatalf("Expected 1 queued credential, got %d", queued)
+	}
+
+	sc, err := s.getScanQueue()
+	check(err)
+	if len(sc) != 1 || len(sc[0].credentials) != 1 {
+		t.Fatalf("Expected 1 scan request with 1 credential, got %#v", sc)
+	}
+	cred := sc[0].credentials[0]
+	if cred.Password != k.reference() {
+		t.Errorf("Expected password %q, got %q", k.reference(), cred.Password)
+	}
+	if cred.Key == nil || cred.Key.PrivateKey != k.PrivateKey {
+		t.Errorf("Expected key to be attached to the queued credential")
+	}
+}
+
+func TestEncryptedCredentials(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	err = s.Init()
+	check(err)
+
+	_, err = s.AddCredential(Credential{User: "plain", Password: "plain", ScanInterval: 1})
+	check(err)
+
+	box, err := NewSecretBox([]byte("0123456789abcdef0123456789abcdef"))
+	check(err)
+	updated, err := s.SetSecretBox(box)
+	check(err)
+	if updated != 1 {
+		t.Errorf("Expected 1 row encrypted, got %d", updated)
+	}
+
+	_, err = s.AddCredential(Credential{User: "root", Password: "hunter2", ScanInterval: 1})
+	check(err)
+	updated, err = s.EncryptSecrets()
+	check(err)
+	if updated != 0 {
+		t.Errorf("Expected nothing left to encrypt, got %d rows", updated)
+	}
+
+	var stored []string
+	check(s.Select(&stored, "SELECT password FROM credentials"))
+	for _, p := range stored {
+		if !isEncrypted(p) {
+			t.Errorf("Expected stored password to be encrypted, got %q", p)
+		}
+	}
+
+	check(s.addOrUpdateHost(SSHHost{hostport: "192.168.1.1:22", version: "whatever", keyfp: "whatever"}))
+	_, err = s.initHostCreds()
+	check(err)
+	sc, err := s.getScanQueue()
+	check(err)
+	passwords := make(map[string]bool)
+	for _, cred := range sc[0].credentials {
+		passwords[cred.Password] = true
+	}
+	if !passwords["hunter2"] || !passwords["plain"] {
+		t.Errorf("Expected decrypted passwords in scan queue, got %v", passwords)
+	}
+
+	//The same credential added before and after the key was configured.
+	//The key is set directly so the plaintext row is left for EncryptSecrets.
+	s.SetSecretBox(nil)
+	_, err = s.AddCredential(Credential{User: "dup", Password: "dup", ScanInterval: 1})
+	check(err)
+	s.secrets = box
+	_, err = s.AddCredential(Credential{User: "dup", Password: "dup", ScanInterval: 1})
+	check(err)
+	_, err = s.EncryptSecrets()
+	check(err)
+	var dups []string
+	check(s.Select(&dups, "SELECT password FROM credentials WHERE user='dup'"))
+	if len(dups) != 1 || !isEncrypted(dups[0]) {
+		t.Errorf("Expected one encrypted dup credential, got %v", dups)
+	}
+
+	err = s.updateBruteResult(BruteForceResult{
+		hostport: "192.168.1.1:22",
+		cred:     Credential{User: "root", Password: "hunter2"},
+		result:   "exec",
+	})
+	check(err)
+	vulns, err := s.GetVulnerabilities()
+	check(err)
+	if len(vulns) != 1 || vulns[0].Password != "hunter2" {
+		t.Fatalf("Expected 1 decrypted vulnerability, got %#v", vulns)
+	}
+
+	s.SetSecretBox(nil)
+	if _, err := s.GetVulnerabilities(); err == nil {
+		t.Errorf("Expected an error reading encrypted credentials without a key")
+	}
+}
+
+func TestSecretBoxEncryptsExistingResults(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	check(s.Init())
+	_, err = s.AddCredential(Credential{User: "root", Password: "hunter2", ScanInterval: 1})
+	check(err)
+	check(s.addOrUpdateHost(SSHHost{hostport: "192.168.1.1:22", version: "whatever", keyfp: "whatever"}))
+	_, err = s.initHostCreds()
+	check(err)
+
+	//The host_creds row was written in plaintext, results found after the
+	//key is configured must still be recorded on it
+	box, err := NewSecretBox([]byte("0123456789abcdef0123456789abcdef"))
+	check(err)
+	_, err = s.SetSecretBox(box)
+	check(err)
+	err = s.updateBruteResult(BruteForceResult{
+		hostport: "192.168.1.1:22",
+		cred:     Credential{User: "root", Password: "hunter2"},
+		result:   "exec",
+	})
+	check(err)
+	vulns, err := s.GetVulnerabilities()
+	check(err)
+	if len(vulns) != 1 || vulns[0].Password != "hunter2" {
+		t.Fatalf("Expected 1 decrypted vulnerability, got %#v", vulns)
+	}
+}
+
+func TestVulnerabilityEvidence(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	err = s.Init()
+	check(err)
+
+	_, err = s.AddCredential(Credential{User: "root", Password: "root", ScanInterval: 1})
+	check(err)
+	check(s.addOrUpdateHost(SSHHost{hostport: "192.168.1.1:22", version: "whatever", keyfp: "whatever"}))
+	_, err = s.initHostCreds()
+	check(err)
+
+	evidence := Evidence{
+		AuthMethod:         "keyboard-interactive",
+		Version:            "SSH-2.0-OpenSSH_7.4",
+		Banner:             "Authorized use only\n",
+		HostKeyFingerprint: "SHA256:whatever",
+		IDOutput:           "uid=0(root) gid=0(root) groups=0(root)\n",
+		Privilege:          "root",
+		Severity:           SeverityCritical,
+	}
+	err = s.updateBruteResult(BruteForceResult{
+		hostport: "192.168.1.1:22",
+		cred:     Credential{User: "root", Password: "root"},
+		result:   "exec",
+		evidence: evidence,
+	})
+	check(err)
+	vulns, err := s.GetVulnerabilities()
+	check(err)
+	if len(vulns) != 1 {
+		t.Fatalf("Expected 1 vulnerability, got %#v", vulns)
+	}
+	got := vulns[0].Evidence
+	if got.Collected == "" {
+		t.Errorf("Expected evidence collection time to be set")
+	}
+	got.Collected = ""
+	if got != evidence {
+		t.Errorf("Expected evidence %#v, got %#v", evidence, got)
+	}
+}
+
+func TestMigrateColumns(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	//The evidence table as it was before privilege classification
+	_, err = s.conn.Exec(`CREATE TABLE host_cred_evidence (
+		hostport character varying,
+		user character varying,
+		password character varying,
+		collected REAL,
+		auth_method character varying,
+		version character varying,
+		banner character varying,
+		host_key_fingerprint character varying,
+		id_output character varying,
+		tunnel_destination character varying,
+
+		PRIMARY KEY (hostport, user, password)
+	)`)
+	check(err)
+	_, err = s.conn.Exec(`INSERT INTO host_cred_evidence (hostport, user, password) VALUES ('192.168.1.1:22', 'root', 'root')`)
+	check(err)
+	check(s.Init())
+	//Init must be safe to run again on a migrated database
+	check(s.Init())
+
+	var severity string
+	check(s.Get(&severity, "SELECT severity FROM host_cred_evidence"))
+	if severity != "" {
+		t.Errorf("Expected blank severity for an old row, got %q", severity)
+	}
+}
+
+func TestAnomalousHostsExcluded(t *testing.T) {
+	check := func(e error) {
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	s, err := NewSQLiteStore(":memory:")
+	check(err)
+	err = s.Init()
+	check(err)
+
+	_, err = s.AddCredential(Credential{User: "root", Password: "root", ScanInterval: 1})
+	check(err)
+	for _, hp := range []string{"192.168.1.1:22", "192.168.1.2:22"} {
+		check(s.addOrUpdateHost(SSHHost{hostport: hp, version: "whatever", keyfp: "whatever"}))
+	}
+	_, err = s.initHostCreds()
+	check(err)
+	for _, hp := range []string{"192.168.1.1:22", "192.168.1.2:22"} {
+		check(s.updateBruteResult(BruteForceResult{
+			hostport: hp,
+			cred:     Credential{User: "root", Password: "root"},
+			result:   "auth",
+		}))
+	}
+	check(s.setHostAnomaly("192.168.1.2:22", AnomalyAcceptsAnything, "accepted a random user and password"))
+
+	vulns, err := s.GetVulnerabilities()
+	check(err)
+	if len(vulns) != 1 || vulns[0].Host.Hostport != "192.168.1.1:22" {
+		t.Errorf("Expected only the normal host to be vulnerable, got %#v", vulns)
+	}
+	anomalous, err := s.GetAnomalousHosts()
+	check(err)
+	if len(anomalous) != 1 || anomalous[0].Anomaly != AnomalyAcceptsAnything {
+		t.Errorf("Expected 1 anomalous host, got %#v", anomalous)
+	}
+
+	check(s.clearHostAnomaly("192.168.1.2:22", AnomalyAcceptsAnything))
+	vulns, err = s.GetVulnerabilities()
+	check(err)
+	if len(vulns) != 2 {
+		t.Errorf("Expected 2 vulnerabilities after clearing the anomaly, got %d", len(vulns))
+	}
+}
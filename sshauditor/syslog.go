@@ -0,0 +1,268 @@
+package sshauditor
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+//SIEM message formats
+const (
+	SIEMFormatCEF  = "cef"
+	SIEMFormatLEEF = "leef"
+)
+
+//syslogFacility is local4, the facility usually set aside for security
+//tools
+const syslogFacility = 20
+
+//eventSeverity maps a severity to the 0-10 scale used by CEF and LEEF and to
+//a syslog severity
+func eventSeverity(severity string) (siem int, syslog int) {
+	switch severity {
+	case SeverityCritical:
+		return 10, 2
+	case SeverityHigh:
+		return 8, 3
+	case SeverityMedium:
+		return 5, 4
+	case SeverityLow:
+		return 3, 5
+	}
+	return 1, 6
+}
+
+//eventFields returns the CEF extension fields for e.  LEEF uses the same
+//values under its own names.
+func eventFields(e Event) map[string]string {
+	fields := map[string]string{
+		"rt":  strconv.FormatInt(e.Time.UnixNano()/int64(time.Millisecond), 10),
+		"msg": e.Summary,
+		"cat": e.Type,
+	}
+	if host, port, err := net.SplitHostPort(e.Hostport); err == nil {
+		fields["dst"] = host
+		fields["dpt"] = port
+	}
+	set := func(k, v string) {
+		if v != "" {
+			fields[k] = v
+		}
+	}
+	set("dhost", e.Hostname)
+	set("duser", e.User)
+	set("outcome", e.Result)
+	if e.Privilege != "" {
+		fields["cs1Label"] = "privilege"
+		fields["cs1"] = e.Privilege
+	}
+	if e.Classification != "" {
+		fields["cs2Label"] = "classification"
+		fields["cs2"] = e.Classification
+	}
+	if fp := e.Fingerprint + e.NewFingerprint; fp != "" {
+		fields["cs3Label"] = "fingerprint"
+		fields["cs3"] = fp
+	}
+	if e.OldFingerprint != "" {
+		fields["cs4Label"] = "oldFingerprint"
+		fields["cs4"] = e.OldFingerprint
+	}
+	if v := e.Version + e.NewVersion; v != "" {
+		fields["cs5Label"] = "version"
+		fields["cs5"] = v
+	}
+	return fields
+}
+
+//leefNames maps the CEF field names to LEEF attribute names
+var leefNames = map[string]string{
+	"dst":     "dst",
+	"dpt":     "dstPort",
+	"dhost":   "dstHostname",
+	"duser":   "usrName",
+	"cat":     "cat",
+	"msg":     "msg",
+	"outcome": "outcome",
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+var cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+var leefValueEscaper = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+//FormatCEF returns e as an ArcSight Common Event Format message
+func FormatCEF(e Event, version string) string {
+	severity, _ := eventSeverity(e.Severity)
+	header := []string{"CEF:0", "NCSA", "ssh-auditor", version, e.Type, e.Summary, strconv.Itoa(severity)}
+	for i := 1; i < len(header); i++ {
+		header[i] = cefHeaderEscaper.Replace(header[i])
+	}
+	fields := eventFields(e)
+	var ext []string
+	for _, k := range sortedKeys(fields) {
+		ext = append(ext, k+"="+cefValueEscaper.Replace(fields[k]))
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(ext, " ")
+}
+
+//FormatLEEF returns e as a QRadar Log Event Extended Format 1.0 message
+func FormatLEEF(e Event, version string) string {
+	severity, _ := eventSeverity(e.Severity)
+	attrs := map[string]string{
+		"sev":           strconv.Itoa(severity),
+		"devTime":       e.Time.Format("Jan 02 2006 15:04:05"),
+		"devTimeFormat": "MMM dd yyyy HH:mm:ss",
+	}
+	fields := eventFields(e)
+	for k, v := range fields {
+		if k == "rt" || strings.HasSuffix(k, "Label") {
+			continue
+		}
+		if name, ok := leefNames[k]; ok {
+			k = name
+		} else if label, ok := fields[k+"Label"]; ok {
+			k = label
+		}
+		attrs[k] = v
+	}
+	var ext []string
+	for _, k := range sortedKeys(attrs) {
+		ext = append(ext, k+"="+leefValueEscaper.Replace(attrs[k]))
+	}
+	header := []string{"LEEF:1.0", "NCSA", "ssh-auditor", version, e.Type}
+	for i := 1; i < len(header); i++ {
+		header[i] = strings.Replace(header[i], "|", " ", -1)
+	}
+	return strings.Join(header, "|") + "|" + strings.Join(ext, "\t")
+}
+
+//SyslogNotifier sends events to a SIEM as RFC 5424 syslog messages with a CEF
+//or LEEF payload.  TCP and TLS use octet counted framing (RFC 6587).
+type SyslogNotifier struct {
+	//Network is udp, tcp or tls
+	Network string
+	Address string
+	Format  string
+	//Version is the product version in the CEF or LEEF header
+	Version   string
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+
+	hostname string
+	mu       sync.Mutex
+	conn     net.Conn
+}
+
+//NewSyslogNotifier returns a SyslogNotifier for address
+func NewSyslogNotifier(network, address, format, version string) (*SyslogNotifier, error) {
+	switch network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", network)
+	}
+	switch format {
+	case SIEMFormatCEF, SIEMFormatLEEF:
+	default:
+		return nil, fmt.Errorf("unknown SIEM format %q", format)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogNotifier{
+		Network:  network,
+		Address:  address,
+		Format:   format,
+		Version:  version,
+		Timeout:  10 * time.Second,
+		hostname: hostname,
+	}, nil
+}
+
+//message returns e as an RFC 5424 syslog message
+func (s *SyslogNotifier) message(e Event) string {
+	_, severity := eventSeverity(e.Severity)
+	var msg string
+	if s.Format == SIEMFormatLEEF {
+		msg = FormatLEEF(e, s.Version)
+	} else {
+		msg = FormatCEF(e, s.Version)
+	}
+	msgid := strings.Replace(e.Type, " ", "-", -1)
+	return fmt.Sprintf("<%d>1 %s %s ssh-auditor %d %s - %s",
+		syslogFacility*8+severity, e.Time.Format(time.RFC3339Nano), s.hostname, os.Getpid(), msgid, msg)
+}
+
+func (s *SyslogNotifier) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	if s.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.Address, s.TLSConfig)
+	}
+	return dialer.Dial(s.Network, s.Address)
+}
+
+//write sends one message, connecting first if needed
+func (s *SyslogNotifier) write(msg string) error {
+	if s.conn == nil {
+		conn, err := s.dial()
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	frame := msg
+	if s.Network != "udp" {
+		frame = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+	_, err := s.conn.Write([]byte(frame))
+	if err != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	return err
+}
+
+//Notify sends e, reconnecting once if the connection was lost
+func (s *SyslogNotifier) Notify(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	msg := s.message(e)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.write(msg)
+	if err != nil {
+		err = s.write(msg)
+	}
+	return errors.Wrapf(err, "Notify %s", s.Address)
+}
+
+//Close closes the connection to the syslog server
+func (s *SyslogNotifier) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
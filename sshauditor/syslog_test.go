@@ -0,0 +1,169 @@
+package sshauditor
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEvent = Event{
+	Type:      EventFinding,
+	Time:      time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+	Hostport:  "192.0.2.1:2222",
+	Summary:   "high severity exec login as root on 192.0.2.1:2222",
+	Severity:  SeverityHigh,
+	User:      "root",
+	Result:    "exec",
+	Privilege: "root=equivalent|a",
+}
+
+func TestFormatCEF(t *testing.T) {
+	got := FormatCEF(testEvent, "1.0")
+	expected := `CEF:0|NCSA|ssh-auditor|1.0|finding|high severity exec login as root on 192.0.2.1:2222|8|` +
+		`cat=finding cs1=root\=equivalent|a cs1Label=privilege dpt=2222 dst=192.0.2.1 duser=root ` +
+		`msg=high severity exec login as root on 192.0.2.1:2222 outcome=exec rt=1583064000000`
+	if got != expected {
+		t.Errorf("FormatCEF =>\n%s\nwant\n%s", got, expected)
+	}
+	e := testEvent
+	e.Summary = `a|b\c`
+	if got := FormatCEF(e, "1.0"); !strings.Contains(got, `|a\|b\\c|`) {
+		t.Errorf("FormatCEF did not escape the header: %s", got)
+	}
+}
+
+func TestFormatLEEF(t *testing.T) {
+	got := FormatLEEF(testEvent, "1.0")
+	if !strings.HasPrefix(got, "LEEF:1.0|NCSA|ssh-auditor|1.0|finding|") {
+		t.Errorf("FormatLEEF header => %s", got)
+	}
+	attrs := make(map[string]string)
+	for _, kv := range strings.Split(strings.SplitN(got, "|", 6)[5], "\t") {
+		parts := strings.SplitN(kv, "=", 2)
+		attrs[parts[0]] = parts[1]
+	}
+	expected := map[string]string{
+		"sev":       "8",
+		"dst":       "192.0.2.1",
+		"dstPort":   "2222",
+		"usrName":   "root",
+		"privilege": "root=equivalent|a",
+		"devTime":   "Mar 01 2020 12:00:00",
+	}
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("FormatLEEF %s => %q, want %q", k, attrs[k], v)
+		}
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	n, err := NewSyslogNotifier("udp", pc.LocalAddr().String(), SIEMFormatCEF, "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Close()
+	if err := n.Notify(testEvent); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:size])
+	//local4.err
+	if !strings.HasPrefix(msg, "<163>1 2020-03-01T12:00:00Z ") || !strings.Contains(msg, " ssh-auditor ") ||
+		!strings.Contains(msg, " finding - CEF:0|") {
+		t.Errorf("received %q", msg)
+	}
+}
+
+//readSyslogFrames reads count octet counted messages from l
+func readSyslogFrames(t *testing.T, l net.Listener, count int) chan []string {
+	result := make(chan []string, 1)
+	go func() {
+		var msgs []string
+		c, err := l.Accept()
+		if err != nil {
+			result <- msgs
+			return
+		}
+		defer c.Close()
+		r := bufio.NewReader(c)
+		for len(msgs) < count {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(length))
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				break
+			}
+			msgs = append(msgs, string(buf))
+		}
+		result <- msgs
+	}()
+	return result
+}
+
+func TestSyslogTCPAndTLS(t *testing.T) {
+	//Borrow the test certificate from httptest
+	https := httptest.NewUnstartedServer(http.NotFoundHandler())
+	https.StartTLS()
+	serverConfig := &tls.Config{Certificates: https.TLS.Certificates}
+	clientConfig := https.Client().Transport.(*http.Transport).TLSClientConfig
+	https.Close()
+
+	tlsListener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tlsListener.Close()
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcpListener.Close()
+
+	for network, l := range map[string]net.Listener{"tcp": tcpListener, "tls": tlsListener} {
+		received := readSyslogFrames(t, l, 2)
+		n, err := NewSyslogNotifier(network, l.Addr().String(), SIEMFormatLEEF, "1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.TLSConfig = clientConfig
+		for i := 0; i < 2; i++ {
+			if err := n.Notify(testEvent); err != nil {
+				t.Fatalf("%s: %v", network, err)
+			}
+		}
+		n.Close()
+		select {
+		case msgs := <-received:
+			if len(msgs) != 2 || !strings.Contains(msgs[1], " finding - LEEF:1.0|") {
+				t.Errorf("%s received %q", network, msgs)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s: timed out waiting for messages", network)
+		}
+	}
+
+	if _, err := NewSyslogNotifier("http", "localhost:514", SIEMFormatCEF, ""); err == nil {
+		t.Errorf("NewSyslogNotifier accepted an unknown network")
+	}
+}
@@ -0,0 +1,197 @@
+package sshauditor
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+//ReportGroup is one group of items returned by the groupBy template function
+type ReportGroup struct {
+	Key   string
+	Items []interface{}
+	Count int
+}
+
+//ReportCount is one key counted by the countBy template function
+type ReportCount struct {
+	Key   string
+	Count int
+}
+
+//fieldValue returns the value at path in v.  path is a dotted list of
+//struct fields, map keys or methods without arguments, like
+//Host.Metadata.Owner or Host.Names.
+func fieldValue(v interface{}, path string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for _, name := range strings.Split(path, ".") {
+		for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				return nil, nil
+			}
+			rv = rv.Elem()
+		}
+		if m := rv.MethodByName(name); m.IsValid() && m.Type().NumIn() == 0 && m.Type().NumOut() == 1 {
+			rv = m.Call(nil)[0]
+			continue
+		}
+		switch rv.Kind() {
+		case reflect.Struct:
+			f := rv.FieldByName(name)
+			if !f.IsValid() {
+				return nil, fmt.Errorf("%s has no field %s", rv.Type(), name)
+			}
+			rv = f
+		case reflect.Map:
+			f := rv.MapIndex(reflect.ValueOf(name))
+			if !f.IsValid() {
+				return nil, nil
+			}
+			rv = f
+		default:
+			return nil, fmt.Errorf("can't get %s from %s", name, rv.Type())
+		}
+	}
+	return rv.Interface(), nil
+}
+
+//items returns the elements of a slice, or the values of a map in key order
+func items(list interface{}) ([]interface{}, error) {
+	rv := reflect.ValueOf(list)
+	var result []interface{}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			result = append(result, rv.Index(i).Interface())
+		}
+	case reflect.Map:
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			result = append(result, rv.MapIndex(k).Interface())
+		}
+	case reflect.Invalid:
+	default:
+		return nil, fmt.Errorf("can't range over %s", rv.Type())
+	}
+	return result, nil
+}
+
+//groupBy groups the items of list by the value at path, sorted by key
+func groupBy(path string, list interface{}) ([]ReportGroup, error) {
+	all, err := items(list)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	var groups []ReportGroup
+	for _, item := range all {
+		v, err := fieldValue(item, path)
+		if err != nil {
+			return nil, err
+		}
+		key := fmt.Sprint(v)
+		if v == nil {
+			key = ""
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, ReportGroup{Key: key})
+		}
+		groups[i].Items = append(groups[i].Items, item)
+		groups[i].Count++
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	return groups, nil
+}
+
+//countBy counts the items of list by the value at path, most common first
+func countBy(path string, list interface{}) ([]ReportCount, error) {
+	groups, err := groupBy(path, list)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]ReportCount, len(groups))
+	for i, g := range groups {
+		counts[i] = ReportCount{Key: g.Key, Count: g.Count}
+	}
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	return counts, nil
+}
+
+//sortBy returns the items of list sorted by the value at path
+func sortBy(path string, list interface{}) ([]interface{}, error) {
+	all, err := items(list)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(all))
+	for i, item := range all {
+		v, err := fieldValue(item, path)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = fmt.Sprint(v)
+	}
+	idx := make([]int, len(all))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return keys[idx[i]] < keys[idx[j]] })
+	sorted := make([]interface{}, len(all))
+	for i, j := range idx {
+		sorted[i] = all[j]
+	}
+	return sorted, nil
+}
+
+//formatDate formats a time, or a time string as written by the store, with
+//a Go time layout.  Strings that aren't a time are returned as is.
+func formatDate(layout string, value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout)
+	case string:
+		for _, l := range []string{storeTimeFormat, time.RFC3339Nano, "2006-01-02"} {
+			if t, err := time.ParseInLocation(l, v, time.Local); err == nil {
+				return t.Format(layout)
+			}
+		}
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+//ReportTemplateFuncs are the functions available to report templates, in
+//addition to the text/template builtins:
+//
+//	groupBy "Host.Metadata.Owner" .Vulnerabilities  groups, each with .Key, .Items and .Count
+//	countBy "Result" .Vulnerabilities               keys with .Key and .Count, most common first
+//	sortBy "Host.Hostport" .Vulnerabilities         items sorted by a field
+//	date "Jan 2, 2006" .LastTested                  formats a time
+//	now                                             the current time
+//	redact .Password                                a masked secret
+//	join ", " .List, lower, upper                   string helpers
+//	percent .Count .Total                           a whole percentage
+//	severityRank .Evidence.Severity                 0 for critical, 1 for high and so on
+func ReportTemplateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"groupBy":      groupBy,
+		"countBy":      countBy,
+		"sortBy":       sortBy,
+		"date":         formatDate,
+		"now":          time.Now,
+		"redact":       RedactSecret,
+		"join":         func(sep string, list []string) string { return strings.Join(list, sep) },
+		"lower":        strings.ToLower,
+		"upper":        strings.ToUpper,
+		"percent":      percent,
+		"severityRank": SeverityRank,
+	}
+}
@@ -0,0 +1,45 @@
+package sshauditor
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestReportTemplateFuncs(t *testing.T) {
+	var tests = []struct {
+		template string
+		expected string
+	}{
+		{`{{range countBy "Result" .Vulnerabilities}}{{.Key}}={{.Count}} {{end}}`, "exec=2 tunnel=1 "},
+		{`{{range groupBy "Host.Metadata.Owner" .Vulnerabilities}}[{{.Key}}]{{range .Items}} {{.User}}{{end}}{{end}}`, "[] git pi[web@example.com] root"},
+		{`{{range sortBy "User" .Vulnerabilities}}{{.User}} {{end}}`, "git pi root "},
+		{`{{range groupBy "Fingerprint" .ActiveHosts}}{{.Key}}:{{.Count}} {{end}}`, "a:2 b:1 "},
+		{`{{range .DuplicateKeys}}{{range sortBy "Names" .}}{{.Hostport}} {{end}}{{end}}`, "10.0.0.1:22 10.1.0.1:22 "},
+		{`{{date "Jan 2, 2006" "2020-03-01 12:00:00"}} {{date "2006" "not a date"}}`, "Mar 1, 2020 not a date"},
+		{`{{redact "test"}} {{upper "a"}} {{join "," (index .ActiveHosts 0).Metadata.TagList}}`, RedactSecret("test") + " A prod,web"},
+	}
+	for _, tt := range tests {
+		tmpl, err := template.New("test").Funcs(ReportTemplateFuncs()).Parse(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, testFilterReport()); err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		if buf.String() != tt.expected {
+			t.Errorf("%s => %q, want %q", tt.template, buf.String(), tt.expected)
+		}
+	}
+
+	tmpl := template.Must(template.New("test").Funcs(ReportTemplateFuncs()).Parse(`{{groupBy "Nope" .Vulnerabilities}}`))
+	if err := tmpl.Execute(&bytes.Buffer{}, testFilterReport()); err == nil {
+		t.Errorf("groupBy on a missing field succeeded")
+	}
+	if got := formatDate("2006", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)); got != "2020" {
+		t.Errorf("formatDate(time) => %q", got)
+	}
+}